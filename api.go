@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/modules"
+)
+
+// adminAPI serves the JSON API to inspect the running modules and to
+// trigger their cron jobs. The API is disabled while no admin token
+// is configured.
+type adminAPI struct {
+	mgr   *modules.Manager
+	store *modules.MetaStore
+	token atomic.Pointer[string]
+}
+
+func newAdminAPI(mgr *modules.Manager, store *modules.MetaStore, token string) *adminAPI {
+	a := &adminAPI{mgr: mgr, store: store}
+	a.setToken(token)
+	return a
+}
+
+func (a *adminAPI) handleGetModuleStore(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("id") == modules.TwitchUserTokenStoreID {
+		// Contains the access and refresh tokens of the Twitch users
+		a.writeError(w, http.StatusForbidden, "store contains credentials")
+		return
+	}
+
+	var attrs attributestore.ModuleAttributeStore
+
+	if err := a.store.ReadWithLock(r.PathValue("id"), func(m attributestore.ModuleAttributeStore) error {
+		attrs = m
+		return nil
+	}); err != nil {
+		logrus.WithError(err).Error("reading module store for API")
+		a.writeError(w, http.StatusInternalServerError, "reading store failed")
+		return
+	}
+
+	a.writeJSON(w, http.StatusOK, attrs)
+}
+
+func (a *adminAPI) handleListModules(w http.ResponseWriter, _ *http.Request) {
+	a.writeJSON(w, http.StatusOK, a.mgr.Modules())
+}
+
+func (a *adminAPI) handleTriggerCronJob(w http.ResponseWriter, r *http.Request) {
+	idx, err := strconv.Atoi(r.PathValue("idx"))
+	if err != nil {
+		a.writeError(w, http.StatusBadRequest, "invalid cron job index")
+		return
+	}
+
+	switch err = a.mgr.TriggerCronJob(r.PathValue("id"), idx); {
+	case err == nil:
+		a.writeJSON(w, http.StatusAccepted, map[string]string{"status": "triggered"})
+
+	case errors.Is(err, modules.ErrModuleNotFound), errors.Is(err, modules.ErrCronJobNotFound):
+		a.writeError(w, http.StatusNotFound, err.Error())
+
+	case errors.Is(err, modules.ErrCronJobRunning):
+		a.writeError(w, http.StatusConflict, err.Error())
+
+	default:
+		logrus.WithError(err).Error("triggering cron job for API")
+		a.writeError(w, http.StatusInternalServerError, "triggering cron job failed")
+	}
+}
+
+// register adds the API routes to the given mux
+func (a *adminAPI) register(mux *http.ServeMux) {
+	mux.Handle("GET /api/modules", a.requireToken(a.handleListModules))
+	mux.Handle("GET /api/modules/{id}/store", a.requireToken(a.handleGetModuleStore))
+	mux.Handle("POST /api/modules/{id}/cron/{idx}/trigger", a.requireToken(a.handleTriggerCronJob))
+}
+
+func (a *adminAPI) requireToken(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := *a.token.Load()
+		if token == "" {
+			// API is disabled
+			http.NotFound(w, r)
+			return
+		}
+
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			a.writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// setToken updates the token required to access the API, an empty
+// token disables the API
+func (a *adminAPI) setToken(token string) {
+	a.token.Store(&token)
+}
+
+func (a *adminAPI) writeError(w http.ResponseWriter, status int, msg string) {
+	a.writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func (*adminAPI) writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logrus.WithError(err).Error("encoding API response")
+	}
+}
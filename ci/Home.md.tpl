@@ -16,6 +16,16 @@
 - Put the text shown below ("Config format") into it
 - Adjust the `module_configs`
 
+Changes to the `module_configs` are applied while the bot is running when the config file is saved or the bot receives a `SIGHUP`: only modules with changed configuration are re-initialized. Changes to `bot_token`, `guild_id` and `store_location` require a restart.
+
+## Validate the config
+
+The bot can check the config for unknown attributes, missing required attributes and values of wrong type before you start it:
+
+```console
+# discord-community --config=config.yaml --validate-config
+```
+
 ## Start the bot
 
 ### Using Docker
@@ -44,8 +54,35 @@
 bot_token: '...'
 # ID of your Discord "server" (internally named "guild")
 guild_id: '...'
-# File location to store a persistent state for the modules
+# Location to store a persistent state for the modules (see below)
 store_location: /path/to/storage.json
+# Token to access the admin API (see below), API is disabled when empty
+admin_token: '...'
+# Twitch app used by all modules and Twitch features not setting their
+# own client_id / client_secret (see below)
+twitch:
+  client_id: '...'
+  client_secret: '...'
+  # Timeout of a single request and number of retries of requests
+  # failing temporarily (optional)
+  request_timeout: 2s
+  retries: 4
+# Let Twitch users authorize the bot to act on their behalf (see below),
+# authorization is disabled when redirect_url is empty
+twitch_auth:
+  redirect_url: 'https://bot.example.com/twitch/auth/callback'
+# Receive Twitch EventSub notifications through a webhook (see below),
+# webhook is disabled when callback_url is empty
+twitch_eventsub:
+  callback_url: 'https://bot.example.com/twitch/eventsub'
+  secret: '...'
+# YouTube and Kick used by the live streaming modules besides Twitch
+# (see below), platforms without credentials are disabled
+youtube:
+  api_key: '...'
+kick:
+  client_id: '...'
+  client_secret: '...'
 
 module_configs:
   - id: 'unique id for the module instance (i.e. UUID)'
@@ -56,19 +93,92 @@ module_configs:
 ...
 ```
 
+## Store location
+
+The `store_location` selects the backend used to persist the state of the modules:
+
+- `/path/to/storage.json` or `file:///path/to/storage.json` - JSON file which is replaced atomically on every change
+- `bolt:///path/to/storage.db` - Embedded [bbolt](https://github.com/etcd-io/bbolt) database
+
+To switch an existing JSON store to bbolt add the `migrate_from` parameter: `bolt:///path/to/storage.db?migrate_from=/path/to/storage.json`. The JSON store is imported once when the database is still empty and left untouched afterwards.
+
+## Inspect and modify the store
+
+The `store` command operates on the store configured in the config file, for example to remove a stale `message_id` and force a module to re-post its managed message or to move the state to another host:
+
+```console
+# discord-community --config=config.yaml store list
+# discord-community --config=config.yaml store get <module-id> [key]
+# discord-community --config=config.yaml store set <module-id> <key> <value>
+# discord-community --config=config.yaml store delete <module-id> [key]
+# discord-community --config=config.yaml store export backup.json
+# discord-community --config=config.yaml store import backup.json
+```
+
+`store get` redacts the tokens of the Twitch users stored under `twitch-user-tokens`, the export contains them to be restored with the import: keep the backup as secret as the store itself.
+
+Stop the bot before modifying the store: a running bot holds a lock on a bbolt database and overwrites changes made to a JSON store with its own state on the next change.
+
+## Admin API
+
+When an `admin_token` is configured, a JSON API is served on the `--listen` address. Every request needs the token in an `Authorization: Bearer <admin_token>` header.
+
+- `GET /api/modules` - List active modules with their type and the last run, last error and next run of their cron jobs
+- `GET /api/modules/<module-id>/store` - Show the attributes stored by the module (refused for the Twitch user tokens in `twitch-user-tokens`)
+- `POST /api/modules/<module-id>/cron/<index>/trigger` - Run the cron job with the given index (see module list) immediately, for example to force a schedule refresh
+- `POST /api/twitch/authorize` - Create a Twitch authorization link for the scopes given as `{"scopes": [...]}` (see below)
+- `GET /api/twitch/tokens` - List the Twitch users who authorized the bot with their scopes
+- `DELETE /api/twitch/tokens/<login>` - Revoke and remove the token of the Twitch user
+
+## Metrics
+
+Prometheus metrics are served on `/metrics` on the `--listen` address (prefixed with `discord_community_`): Twitch and Discord API requests, Twitch EventSub messages, gateway events handled per module, duration and failures of cron jobs per module, store write latency and the number of live streamers seen by the `liveposting` and `liverole` modules.
+
+## Health checks
+
+For orchestrators the bot serves two endpoints on the `--listen` address, returning `200` when all checks pass and `503` otherwise. The JSON body details the result of each check.
+
+- `GET /healthz` - The Discord gateway is connected and received `READY`
+- `GET /readyz` - Additionally the configured guild was found, all modules were initialized and set up, the last run of every cron job succeeded and the last Twitch token fetch succeeded
+
+## Twitch app
+
+The modules using Twitch and the `twitch_auth` and `twitch_eventsub` sections share the app configured in the `twitch` section so they share the app access token and the rate limit of the app. Each of them can use another app by setting its own `client_id` and `client_secret` (`twitch_client_id` and `twitch_client_secret` for modules), the `request_timeout` and `retries` still apply. Changes to this section require a restart.
+
+## Twitch EventSub
+
+The `liveposting` and `liverole` modules can be notified instantly about streams going live or offline through [Twitch EventSub](https://dev.twitch.tv/docs/eventsub/) using a WebSocket connection. This requires a user access token issued for the Twitch app to be set in `twitch_user_token`, the module opens one connection per instance. Polling (`liveposting`) and the Discord presence (`liverole`) are used for streamers without an active subscription.
+
+Twitch limits the number of connections per user token to 3 and the number of subscriptions for streamers who did not authorize the app, so large lists of streamers might need to fall back to polling.
+
+Instead of WebSocket connections the notifications can be received through a webhook configured in the `twitch_eventsub` section of the config. The bot then serves `POST /twitch/eventsub` on the `--listen` address which needs to be reachable through the HTTPS `callback_url` (port 443, e.g. using a reverse proxy). The subscriptions for all streamers of the `liveposting` and `liverole` modules are created using an app access token of the given client and deleted when no longer needed during the setup of the modules. The `secret` (10-100 characters) is used to sign the messages sent by Twitch. Changes to this section require a restart.
+
+## YouTube and Kick
+
+The `liveposting` and `liverole` modules track streams on YouTube and Kick besides Twitch when the `youtube` (API key of a Google Cloud project with the YouTube Data API enabled) or `kick` (client ID and secret of a Kick app) section of the config is set. Channels on these platforms are referenced with a platform prefix (`youtube:<channel ID>`, `kick:<slug>`), references without prefix are Twitch logins. The Discord streaming presence is matched against the URLs of all configured platforms. EventSub and the VOD summary of ended streams are only available for Twitch. Changes to these sections require a restart.
+
+## Twitch user authorization
+
+Some Twitch endpoints (e.g. followers) need a token of the broadcaster instead of the app. When `twitch_auth` is configured, the bot serves `GET /twitch/auth/callback` on the `--listen` address which needs to be reachable through the `redirect_url` registered as OAuth redirect URL of the Twitch app.
+
+To let a broadcaster authorize the bot, create an authorization link for the required scopes through the admin API and send it to the broadcaster. The link can be used once within an hour. After the authorization the tokens are stored in the store (module ID `twitch-user-tokens`) and refreshed automatically. Changes to this section require a restart.
+
+```console
+# curl -H 'Authorization: Bearer <admin_token>' -d '{"scopes": ["moderator:read:followers"]}' http://localhost:3000/api/twitch/authorize
+```
+
 # Modules
 
-{% for module in modules -%}
-## Type: `{{ module.type }}`
+{{ range .Modules -}}
+## Type: `{{ .Type }}`
 
-{{ module.description }}
+{{ .Schema.Description }}
 
 | Attribute | Req. | Type | Default Value | Description |
 | --------- | :--: | ---- | ------------- | ----------- |
-{%- for attr in module.attributes %}
-| `{{ attr.name }}` | {% if attr.required == 'required' %}✅{% endif %} | {{ attr.type }} | {% if attr.default != "" %}`{{ attr.default }}`{% endif %} | {{ attr.description }} |
-{%- endfor %}
-
-{% endfor %}
+{{- range .Schema.SortedAttributes }}
+| `{{ .Name }}` | {{ if .Required }}✅{{ end }} | {{ .Type }} | {{ if ne .Default "" }}`{{ .Default }}`{{ end }} | {{ .Description }} |
+{{- end }}
 
+{{ end }}
 <!-- vim: set ft=markdown : -->
@@ -0,0 +1,46 @@
+// Generates the Wiki documentation from the registered module schemas
+package main
+
+import (
+	"os"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/modules"
+
+	// Keep in sync with module.load.go to document all modules
+	_ "github.com/Luzifer/discord-community/pkg/modules/clearchannel"
+	_ "github.com/Luzifer/discord-community/pkg/modules/liveposting"
+	_ "github.com/Luzifer/discord-community/pkg/modules/liverole"
+	_ "github.com/Luzifer/discord-community/pkg/modules/presence"
+	_ "github.com/Luzifer/discord-community/pkg/modules/reactionrole"
+	_ "github.com/Luzifer/discord-community/pkg/modules/streamschedule"
+)
+
+type moduleDoc struct {
+	Type   string
+	Schema modules.Schema
+}
+
+func main() {
+	tplFile := "ci/Home.md.tpl"
+	if len(os.Args) > 1 {
+		tplFile = os.Args[1]
+	}
+
+	tpl, err := template.ParseFiles(tplFile)
+	if err != nil {
+		logrus.WithError(err).Fatal("parsing template")
+	}
+
+	var docs []moduleDoc
+	for _, name := range modules.GetModuleNames() {
+		schema, _ := modules.GetModuleSchema(name)
+		docs = append(docs, moduleDoc{Type: name, Schema: schema})
+	}
+
+	if err = tpl.Execute(os.Stdout, map[string]any{"Modules": docs}); err != nil {
+		logrus.WithError(err).Fatal("rendering template")
+	}
+}
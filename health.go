@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/modules"
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+type (
+	// healthCheck contains the result of a single check
+	healthCheck struct {
+		OK      bool   `json:"ok"`
+		Message string `json:"message,omitempty"`
+	}
+
+	// healthResponse is returned by the health endpoints
+	healthResponse struct {
+		Status string                 `json:"status"`
+		Checks map[string]healthCheck `json:"checks"`
+	}
+
+	// healthState collects the state of the Discord connection and
+	// evaluates the health and readiness checks
+	healthState struct {
+		connected  atomic.Bool
+		guildFound atomic.Bool
+		mgr        *modules.Manager
+		ready      atomic.Bool
+	}
+)
+
+// newHealthState creates a healthState and registers the handlers
+// tracking the gateway state. It needs to be called before the
+// session is opened.
+func newHealthState(discord *discordgo.Session, mgr *modules.Manager) *healthState {
+	h := &healthState{mgr: mgr}
+
+	discord.AddHandler(func(_ *discordgo.Session, _ *discordgo.Connect) { h.connected.Store(true) })
+	discord.AddHandler(func(_ *discordgo.Session, _ *discordgo.Disconnect) {
+		h.connected.Store(false)
+		h.ready.Store(false)
+	})
+	discord.AddHandler(func(_ *discordgo.Session, _ *discordgo.Ready) { h.ready.Store(true) })
+	discord.AddHandler(func(_ *discordgo.Session, _ *discordgo.Resumed) { h.ready.Store(true) })
+
+	return h
+}
+
+func (h *healthState) checkCronJobs() healthCheck {
+	var failing []string
+
+	for _, mod := range h.mgr.Modules() {
+		for _, job := range mod.CronJobs {
+			if job.LastError != "" {
+				failing = append(failing, fmt.Sprintf("%s#%d: %s", mod.ID, job.Index, job.LastError))
+			}
+		}
+	}
+
+	if len(failing) > 0 {
+		return healthCheck{Message: strings.Join(failing, "; ")}
+	}
+
+	return healthCheck{OK: true}
+}
+
+func (h *healthState) checkGateway() healthCheck {
+	switch {
+	case !h.connected.Load():
+		return healthCheck{Message: "gateway not connected"}
+	case !h.ready.Load():
+		return healthCheck{Message: "gateway connected, waiting for READY"}
+	}
+
+	return healthCheck{OK: true}
+}
+
+func (h *healthState) checkGuild() healthCheck {
+	if !h.guildFound.Load() {
+		return healthCheck{Message: "configured guild not found"}
+	}
+
+	return healthCheck{OK: true}
+}
+
+func (h *healthState) checkModules() healthCheck {
+	problems := h.mgr.Problems()
+	if len(problems) == 0 {
+		return healthCheck{OK: true}
+	}
+
+	var msgs []string
+	for id, msg := range problems {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", id, msg))
+	}
+	slices.Sort(msgs)
+
+	return healthCheck{Message: strings.Join(msgs, "; ")}
+}
+
+func (*healthState) checkTwitchToken() healthCheck {
+	lastFetch, err := twitch.TokenFetchStatus()
+	switch {
+	case lastFetch.IsZero():
+		return healthCheck{OK: true, Message: "no token fetched yet"}
+	case err != nil:
+		return healthCheck{Message: err.Error()}
+	}
+
+	return healthCheck{OK: true}
+}
+
+func (h *healthState) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	h.respond(w, map[string]healthCheck{
+		"gateway": h.checkGateway(),
+	})
+}
+
+func (h *healthState) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	h.respond(w, map[string]healthCheck{
+		"cron_jobs":    h.checkCronJobs(),
+		"gateway":      h.checkGateway(),
+		"guild":        h.checkGuild(),
+		"modules":      h.checkModules(),
+		"twitch_token": h.checkTwitchToken(),
+	})
+}
+
+// register adds the health routes to the given mux
+func (h *healthState) register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.handleHealthz)
+	mux.HandleFunc("GET /readyz", h.handleReadyz)
+}
+
+func (*healthState) respond(w http.ResponseWriter, checks map[string]healthCheck) {
+	resp := healthResponse{Status: "ok", Checks: checks}
+	status := http.StatusOK
+
+	for _, c := range checks {
+		if !c.OK {
+			resp.Status = "failing"
+			status = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logrus.WithError(err).Error("encoding health response")
+	}
+}
@@ -2,33 +2,46 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
-	"slices"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	httphelpers "github.com/Luzifer/go_helpers/http"
 	"github.com/Luzifer/rconfig/v2"
 	"github.com/bwmarrin/discordgo"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/robfig/cron/v3"
 	"github.com/sirupsen/logrus"
 
 	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/metrics"
 	"github.com/Luzifer/discord-community/pkg/modules"
+	"github.com/Luzifer/discord-community/pkg/twitch"
 )
 
+// twitchEventSubWebhookPath is the path the Twitch EventSub webhook
+// callbacks are served on
+const twitchEventSubWebhookPath = "/twitch/eventsub"
+
 var (
 	cfg = struct {
-		Config         string `flag:"config,c" default:"config.yaml" description:"Path to config file"`
-		Listen         string `flag:"listen" default:":3000" description:"Port/IP to listen on"`
-		LogLevel       string `flag:"log-level" default:"info" description:"Log level (debug, info, warn, error, fatal)"`
-		VersionAndExit bool   `flag:"version" default:"false" description:"Prints current version and exits"`
+		Config          string        `flag:"config,c" default:"config.yaml" description:"Path to config file"`
+		Listen          string        `flag:"listen" default:":3000" description:"Port/IP to listen on"`
+		LogLevel        string        `flag:"log-level" default:"info" description:"Log level (debug, info, warn, error, fatal)"`
+		ShutdownTimeout time.Duration `flag:"shutdown-timeout" default:"30s" description:"How long to wait for running jobs and modules to finish on shutdown"`
+		ValidateConfig  bool          `flag:"validate-config" default:"false" description:"Validate the config file, print a report and exit (non-zero on problems)"`
+		VersionAndExit  bool          `flag:"version" default:"false" description:"Prints current version and exits"`
+		WatchConfig     bool          `flag:"watch-config" default:"true" description:"Reload modules when the config file changes"`
 	}{}
 
-	confFile *config.File
-	store    *modules.MetaStore
+	store *modules.MetaStore
 
 	version = "dev"
 )
@@ -48,15 +61,18 @@ func initApp() (err error) {
 	return nil
 }
 
-//nolint:funlen,gocyclo // setup, makes sense to keep together
+//nolint:funlen // setup, makes sense to keep together
 func main() {
 	var (
-		crontab       = cron.New()
-		discord       *discordgo.Session
-		err           error
-		activeModules []modules.Module
+		confFile *config.File
+		crontab  = cron.New()
+		discord  *discordgo.Session
+		err      error
 	)
 
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
 	if err = initApp(); err != nil {
 		logrus.WithError(err).Fatal("initializing app")
 	}
@@ -66,6 +82,34 @@ func main() {
 		os.Exit(0)
 	}
 
+	if cfg.ValidateConfig {
+		valid, err := validateConfigFile(os.Stdout, cfg.Config)
+		if err != nil {
+			logrus.WithError(err).Fatal("validating config")
+		}
+		if !valid {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	// First argument is the program name
+	if args := rconfig.Args()[1:]; len(args) > 0 {
+		if args[0] != "store" {
+			logrus.Fatalf("unknown command %q", args[0])
+		}
+
+		err = runStoreCommand(os.Stdin, os.Stdout, cfg.Config, args[1:])
+		switch {
+		case errors.Is(err, errStoreUsage):
+			fmt.Fprint(os.Stderr, storeUsage)
+			os.Exit(1)
+		case err != nil:
+			logrus.WithError(err).Fatal("executing store command")
+		}
+		os.Exit(0)
+	}
+
 	if confFile, err = config.NewFromFile(cfg.Config); err != nil {
 		logrus.WithError(err).Fatal("loading config file")
 	}
@@ -74,7 +118,7 @@ func main() {
 		logrus.Fatal("config contains no store location")
 	}
 
-	if store, err = modules.NewMetaStoreFromDisk(confFile.StoreLocation); err != nil {
+	if store, err = modules.NewMetaStore(confFile.StoreLocation); err != nil {
 		logrus.WithError(err).Fatal("loading store")
 	}
 
@@ -84,57 +128,73 @@ func main() {
 	}
 
 	discord.Identify.Intents = discordgo.IntentsAll
+	discord.Client.Transport = metrics.DiscordTransport{Next: discord.Client.Transport}
 
-	var activeIDs []string
-	for i, mc := range confFile.ModuleConfigs {
-		logger := logrus.WithFields(logrus.Fields{
-			"id":     mc.ID,
-			"idx":    i,
-			"module": mc.Type,
-		})
-
-		if slices.Contains(activeIDs, mc.ID) {
-			logger.Error("found duplicate module ID, module will be disabled")
-			continue
-		}
+	mgr := modules.NewManager(crontab, discord, store)
+	health := newHealthState(discord, mgr)
 
-		if mc.ID == "" {
-			logger.Error("module contains no ID and will be disabled")
-			continue
-		}
+	mgr.SetTwitchConfig(confFile.Twitch)
+	mgr.SetStreamingConfig(confFile.YouTube, confFile.Kick)
 
-		mod := modules.GetModuleByName(mc.Type)
-		if mod == nil {
-			logger.Fatal("found configuration for unsupported module")
+	if es := confFile.TwitchEventSub; es.CallbackURL != "" {
+		adapter, err := mgr.TwitchAdapter(es.ClientID, es.ClientSecret)
+		if err != nil {
+			logrus.WithError(err).Fatal("creating twitch adapter for twitch_eventsub")
 		}
+		webhook := twitch.NewEventSubWebhook(adapter, es.CallbackURL, es.Secret, mgr.HandleTwitchEventSub)
+		mgr.SetTwitchEventSubWebhook(adapter, webhook)
+		http.Handle("POST "+twitchEventSubWebhookPath, webhook)
+	}
 
-		if err = mod.Initialize(modules.ModuleInitArgs{
-			ID:    mc.ID,
-			Attrs: mc.Attributes,
-
-			Crontab: crontab,
-			Discord: discord,
-			Config:  confFile,
-			Store:   store,
-		}); err != nil {
-			logger.WithError(err).Fatal("initializing module")
+	var auth *twitchAuth
+	if ta := confFile.TwitchAuth; ta.RedirectURL != "" {
+		adapter, err := mgr.TwitchAdapter(ta.ClientID, ta.ClientSecret)
+		if err != nil {
+			logrus.WithError(err).Fatal("creating twitch adapter for twitch_auth")
 		}
+		auth = newTwitchAuth(adapter, ta.RedirectURL, store)
+		mgr.SetTwitchUserAuth(adapter)
+	}
 
-		activeModules = append(activeModules, mod)
-		activeIDs = append(activeIDs, mc.ID)
-
-		logger.Debug("enabled module")
+	if err = mgr.Apply(ctx, confFile); err != nil {
+		logrus.WithError(err).Fatal("initializing modules")
 	}
 
-	if len(activeModules) == 0 {
+	if mgr.Len() == 0 {
 		logrus.Warn("no modules were enabled, quitting now")
 		return
 	}
 
+	// Run HTTP server
+	api := newAdminAPI(mgr, store, confFile.AdminToken)
+	api.register(http.DefaultServeMux)
+	if auth != nil {
+		auth.register(http.DefaultServeMux, api)
+	}
+	health.register(http.DefaultServeMux)
+
+	// Compression is done by the GzipHandler below
+	http.Handle("GET /metrics", promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{DisableCompression: true}))
+
+	var h http.Handler = http.DefaultServeMux
+	h = httphelpers.GzipHandler(h)
+	h = httphelpers.NewHTTPLogHandler(h)
+
+	server := &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           h,
+		ReadHeaderTimeout: time.Second,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Fatal("listening for HTTP traffic")
+		}
+	}()
+
 	if err = discord.Open(); err != nil {
 		logrus.WithError(err).Fatal("connecting discord client")
 	}
-	defer discord.Close() //nolint:errcheck // Will be closed by program exit
 	logrus.Debug("discord connected")
 
 	guild, err := discord.Guild(confFile.GuildID)
@@ -142,33 +202,57 @@ func main() {
 		logrus.WithError(err).Fatal("getting guild for given guild-id in config: is the bot added and the ID correct?")
 	}
 	logrus.WithField("name", guild.Name).Info("found specified guild for operation")
+	health.guildFound.Store(true)
 
 	// Run Crontab
 	crontab.Start()
-	defer crontab.Stop()
 	logrus.Debug("crontab started")
 
 	// Execute Setup methods now after we're connected
-	for i, mod := range activeModules {
-		if err = mod.Setup(); err != nil {
-			logrus.WithError(err).WithField("idx", i).Fatal("running setup for module")
-		}
+	if err = mgr.Setup(ctx); err != nil {
+		logrus.WithError(err).Fatal("running setup for modules")
 	}
 
-	// Run HTTP server
-	var h http.Handler = http.DefaultServeMux
-	h = httphelpers.GzipHandler(h)
-	h = httphelpers.NewHTTPLogHandler(h)
-
-	server := &http.Server{
-		Addr:              cfg.Listen,
-		Handler:           h,
-		ReadHeaderTimeout: time.Second,
+	reloader := newConfigReloader(confFile, mgr, api)
+	if cfg.WatchConfig {
+		go watchConfig(ctx, cfg.Config, func() { reloader.reload(ctx) })
 	}
+	go handleSIGHUP(ctx, func() { reloader.reload(ctx) })
 
 	logrus.WithField("version", version).Info("bot setup done, bot is now running")
 
-	if err = server.ListenAndServe(); err != nil {
-		logrus.WithError(err).Fatal("listening for HTTP traffic")
+	<-ctx.Done()
+	logrus.Info("received shutdown signal, shutting down")
+
+	shutdown(server, crontab, discord, mgr)
+}
+
+// shutdown stops all components in reverse order of their startup and
+// waits for them to finish until the shutdown timeout is reached
+func shutdown(server *http.Server, crontab *cron.Cron, discord *discordgo.Session, mgr *modules.Manager) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logrus.WithError(err).Error("shutting down HTTP server")
+	}
+
+	select {
+	case <-crontab.Stop().Done():
+		logrus.Debug("crontab stopped")
+	case <-ctx.Done():
+		logrus.Warn("timeout waiting for running cron jobs to finish")
+	}
+
+	mgr.Shutdown(ctx)
+
+	if err := discord.Close(); err != nil {
+		logrus.WithError(err).Error("closing discord connection")
 	}
+
+	if err := store.Close(); err != nil {
+		logrus.WithError(err).Error("closing store")
+	}
+
+	logrus.Info("shutdown complete")
 }
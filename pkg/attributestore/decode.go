@@ -0,0 +1,516 @@
+package attributestore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+type (
+	// AttributeError describes a problem with a specific attribute
+	// encountered while decoding
+	AttributeError struct {
+		Name string
+		Err  error
+	}
+
+	// Color represents a color given as integer (`0x2ECC71`) or as
+	// hex string (`"#2ECC71"`)
+	Color int
+
+	// Field describes an attribute derived from a struct field used
+	// as Decode target
+	Field struct {
+		Name     string
+		Type     string
+		Required bool
+		Default  string
+	}
+
+	// Unmarshaler can be implemented by types which need custom
+	// decoding from the raw attribute value. Maps are passed as
+	// map[string]any regardless of the YAML decoder's representation.
+	Unmarshaler interface {
+		UnmarshalAttribute(value any) error
+	}
+)
+
+var (
+	colorType      = reflect.TypeFor[Color]()
+	durationType   = reflect.TypeFor[time.Duration]()
+	locationType   = reflect.TypeFor[time.Location]()
+	unmarshalerTyp = reflect.TypeFor[Unmarshaler]()
+)
+
+// Decode fills the struct the target points to with the attributes
+// stored in the ModuleAttributeStore. Struct fields are mapped using
+// the `attr` tag (`attr:"post_text"`), fields without `attr` tag are
+// left untouched. Missing attributes are filled from the `default`
+// tag or reported when the `required:"true"` tag is present.
+//
+// A placeholder in curly braces at the end of the attribute name
+// (`attr:"post_text_{username}"`) collects all attributes having the
+// prefix into a map field using the remainder as map key.
+//
+// Supported types are strings, bools, integers, floats, uint64
+// (snowflakes, also given as string), time.Duration, *time.Location,
+// Color and slices, string-keyed maps, structs and pointers of those.
+// Types implementing Unmarshaler can decode themselves.
+//
+// All problems found are returned joined as AttributeError.
+func (m ModuleAttributeStore) Decode(target any) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("decode target must be a non-nil pointer to a struct")
+	}
+
+	return errors.Join(decodeStruct(m, rv.Elem(), "")...)
+}
+
+// Fields returns the description of all attributes the given struct
+// (or pointer to struct) will be filled with by Decode
+func Fields(target any) []Field {
+	rt := reflect.TypeOf(target)
+	if rt.Kind() == reflect.Pointer {
+		rt = rt.Elem()
+	}
+
+	var out []Field
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+		name, ok := sf.Tag.Lookup("attr")
+		if !ok || name == "-" {
+			continue
+		}
+
+		ft := sf.Type
+		if strings.HasSuffix(name, "}") && ft.Kind() == reflect.Map {
+			// Placeholder attributes are documented by their value type
+			ft = ft.Elem()
+		}
+
+		out = append(out, Field{
+			Name:     name,
+			Type:     typeName(ft),
+			Required: sf.Tag.Get("required") == "true",
+			Default:  sf.Tag.Get("default"),
+		})
+	}
+
+	return out
+}
+
+// UnmarshalAttribute implements the Unmarshaler interface
+func (c *Color) UnmarshalAttribute(value any) error {
+	if sv, ok := value.(string); ok {
+		sv = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(sv), "#"), "0x")
+		iv, err := strconv.ParseInt(sv, 16, 32)
+		if err != nil {
+			return fmt.Errorf("parsing color: %w", err)
+		}
+		*c = Color(iv)
+		return nil
+	}
+
+	var iv int64
+	if err := decodeValue(value, reflect.ValueOf(&iv).Elem()); err != nil {
+		return err
+	}
+
+	*c = Color(iv)
+	return nil
+}
+
+func (a AttributeError) Error() string { return fmt.Sprintf("attribute %q: %s", a.Name, a.Err) }
+
+func (a AttributeError) Unwrap() error { return a.Err }
+
+//nolint:funlen,gocognit,gocyclo // Type switch, splitting makes it harder to read
+func decodeValue(value any, rv reflect.Value) error {
+	if rv.CanAddr() && rv.Addr().Type().Implements(unmarshalerTyp) {
+		if mv, ok := toMap(value); ok {
+			value = mv
+		}
+		if err := rv.Addr().Interface().(Unmarshaler).UnmarshalAttribute(value); err != nil {
+			return fmt.Errorf("unmarshalling %s: %w", rv.Type(), err)
+		}
+		return nil
+	}
+
+	switch rv.Type() {
+	case durationType:
+		sv, ok := value.(string)
+		if !ok {
+			return ErrValueMismatch
+		}
+		d, err := time.ParseDuration(sv)
+		if err != nil {
+			return fmt.Errorf("parsing duration: %w", err)
+		}
+		rv.SetInt(int64(d))
+		return nil
+
+	case reflect.PointerTo(locationType):
+		sv, ok := value.(string)
+		if !ok {
+			return ErrValueMismatch
+		}
+		loc, err := time.LoadLocation(sv)
+		if err != nil {
+			return fmt.Errorf("loading location: %w", err)
+		}
+		rv.Set(reflect.ValueOf(loc))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			rv.SetBool(v)
+		case string:
+			bv, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("parsing string to bool: %w", err)
+			}
+			rv.SetBool(bv)
+		default:
+			return ErrValueMismatch
+		}
+
+	case reflect.Float32, reflect.Float64:
+		fv, ok := toFloat(value)
+		if !ok {
+			return ErrValueMismatch
+		}
+		rv.SetFloat(fv)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// Converted without floats as they lose precision above 2^53
+		// (e.g. snowflakes)
+		if sv, ok := toNumberString(value); ok {
+			iv, err := strconv.ParseInt(sv, 10, 64)
+			if err != nil {
+				return fmt.Errorf("parsing string to int: %w", err)
+			}
+			value = iv
+		}
+		iv, ok := toInt(value)
+		if !ok {
+			return ErrValueMismatch
+		}
+		if rv.OverflowInt(iv) {
+			return fmt.Errorf("value %v overflows %s", value, rv.Type())
+		}
+		rv.SetInt(iv)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// Snowflakes are commonly given as strings as they exceed the
+		// precision of floats when transported through JSON
+		if sv, ok := toNumberString(value); ok {
+			uv, err := strconv.ParseUint(sv, 10, 64)
+			if err != nil {
+				return fmt.Errorf("parsing string to uint: %w", err)
+			}
+			value = uv
+		}
+		uv, ok := toUint(value)
+		if !ok {
+			return ErrValueMismatch
+		}
+		if rv.OverflowUint(uv) {
+			return fmt.Errorf("value %v overflows %s", value, rv.Type())
+		}
+		rv.SetUint(uv)
+
+	case reflect.Interface:
+		if value != nil {
+			rv.Set(reflect.ValueOf(value))
+		}
+
+	case reflect.Map:
+		return decodeMap(value, rv)
+
+	case reflect.Pointer:
+		if value == nil {
+			rv.SetZero()
+			return nil
+		}
+		nv := reflect.New(rv.Type().Elem())
+		if err := decodeValue(value, nv.Elem()); err != nil {
+			return err
+		}
+		rv.Set(nv)
+
+	case reflect.Slice:
+		return decodeSlice(value, rv)
+
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			rv.SetString(v)
+		case fmt.Stringer:
+			rv.SetString(v.String())
+		default:
+			return ErrValueMismatch
+		}
+
+	case reflect.Struct:
+		src, ok := toMap(value)
+		if !ok {
+			return ErrValueMismatch
+		}
+		return errors.Join(decodeStruct(src, rv, "")...)
+
+	default:
+		return fmt.Errorf("unsupported target type %s", rv.Type())
+	}
+
+	return nil
+}
+
+func decodeDefault(def string, rv reflect.Value) error {
+	if rv.Kind() == reflect.String {
+		rv.SetString(def)
+		return nil
+	}
+
+	var value any
+	if err := yaml.Unmarshal([]byte(def), &value); err != nil {
+		return fmt.Errorf("parsing default value: %w", err)
+	}
+
+	return decodeValue(value, rv)
+}
+
+func decodeMap(value any, rv reflect.Value) error {
+	src, ok := toMap(value)
+	if !ok || rv.Type().Key().Kind() != reflect.String {
+		return ErrValueMismatch
+	}
+
+	out := reflect.MakeMapWithSize(rv.Type(), len(src))
+
+	var errs []error
+	for k, v := range src {
+		nv := reflect.New(rv.Type().Elem()).Elem()
+		if err := decodeValue(v, nv); err != nil {
+			errs = append(errs, AttributeError{Name: k, Err: err})
+			continue
+		}
+		out.SetMapIndex(reflect.ValueOf(k).Convert(rv.Type().Key()), nv)
+	}
+
+	rv.Set(out)
+	return errors.Join(errs...)
+}
+
+func decodeSlice(value any, rv reflect.Value) error {
+	src := reflect.ValueOf(value)
+	if value == nil || src.Kind() != reflect.Slice {
+		return ErrValueMismatch
+	}
+
+	out := reflect.MakeSlice(rv.Type(), src.Len(), src.Len())
+
+	var errs []error
+	for i := range src.Len() {
+		if err := decodeValue(src.Index(i).Interface(), out.Index(i)); err != nil {
+			errs = append(errs, AttributeError{Name: strconv.Itoa(i), Err: err})
+		}
+	}
+
+	rv.Set(out)
+	return errors.Join(errs...)
+}
+
+func decodeStruct(src map[string]any, rv reflect.Value, prefix string) (errs []error) {
+	rt := rv.Type()
+
+	for i := range rt.NumField() {
+		sf := rt.Field(i)
+		name, ok := sf.Tag.Lookup("attr")
+		if !ok || name == "-" {
+			continue
+		}
+
+		fv := rv.Field(i)
+
+		if wcPrefix, _, ok := strings.Cut(name, "{"); ok && strings.HasSuffix(name, "}") {
+			collected := make(map[string]any)
+			for k, v := range src {
+				if strings.HasPrefix(k, wcPrefix) && len(k) > len(wcPrefix) {
+					collected[strings.TrimPrefix(k, wcPrefix)] = v
+				}
+			}
+
+			if err := decodeMap(collected, fv); err != nil {
+				errs = append(errs, AttributeError{Name: prefix + name, Err: err})
+			}
+			continue
+		}
+
+		value, ok := src[name]
+		switch {
+		case ok:
+			if err := decodeValue(value, fv); err != nil {
+				errs = append(errs, AttributeError{Name: prefix + name, Err: err})
+			}
+
+		case sf.Tag.Get("default") != "":
+			if err := decodeDefault(sf.Tag.Get("default"), fv); err != nil {
+				errs = append(errs, AttributeError{Name: prefix + name, Err: err})
+			}
+
+		case sf.Tag.Get("required") == "true":
+			errs = append(errs, AttributeError{Name: prefix + name, Err: ErrValueNotSet})
+		}
+	}
+
+	return errs
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		fv, err := v.Float64()
+		return fv, err == nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	}
+
+	return 0, false
+}
+
+func toInt(value any) (int64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Uint()), true
+
+	case reflect.Float32, reflect.Float64:
+		// Larger values than 2^53 need to be given as integer, string
+		// or json.Number to be exact
+		if rv.Float() != math.Trunc(rv.Float()) || rv.Float() < math.MinInt64 || rv.Float() >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(rv.Float()), true
+	}
+
+	return 0, false
+}
+
+func toMap(value any) (map[string]any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		return v, true
+
+	case ModuleAttributeStore:
+		return v, true
+
+	case map[any]any:
+		out := make(map[string]any, len(v))
+		for k, iv := range v {
+			sk, ok := k.(string)
+			if !ok {
+				return nil, false
+			}
+			out[sk] = iv
+		}
+		return out, true
+	}
+
+	return nil, false
+}
+
+// toNumberString returns the textual representation of numbers given
+// as string or json.Number to be parsed without loss of precision
+func toNumberString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	}
+
+	return "", false
+}
+
+func toUint(value any) (uint64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return 0, false
+		}
+		return uint64(rv.Int()), true
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rv.Uint(), true
+
+	case reflect.Float32, reflect.Float64:
+		if rv.Float() < 0 || rv.Float() != math.Trunc(rv.Float()) {
+			return 0, false
+		}
+		return uint64(rv.Float()), true
+	}
+
+	return 0, false
+}
+
+func typeName(rt reflect.Type) string {
+	switch rt {
+	case colorType:
+		return "color"
+	case durationType:
+		return "duration"
+	case locationType:
+		return "timezone"
+	}
+
+	switch rt.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Float32, reflect.Float64:
+		return "float64"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int64"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "snowflake"
+	case reflect.Map:
+		return "map[string]" + typeName(rt.Elem())
+	case reflect.Pointer:
+		return typeName(rt.Elem())
+	case reflect.Slice:
+		return "[]" + typeName(rt.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Struct:
+		return "object"
+	}
+
+	return rt.String()
+}
@@ -0,0 +1,211 @@
+package attributestore
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+type (
+	testDecodeConfig struct {
+		Channel   uint64            `attr:"channel"`
+		Color     Color             `attr:"color"`
+		Embed     *testDecodeEmbed  `attr:"embed"`
+		Enabled   bool              `attr:"enabled" default:"true"`
+		Interval  time.Duration     `attr:"interval" default:"5m"`
+		Limit     int64             `attr:"limit"`
+		Names     []string          `attr:"names"`
+		Overrides map[string]string `attr:"text_{username}"`
+		Required  string            `attr:"required" required:"true"`
+		Timezone  *time.Location    `attr:"timezone"`
+		Untagged  string
+	}
+
+	testDecodeEmbed struct {
+		Title  string           `attr:"title" default:"Live!"`
+		Fields []testDecodeItem `attr:"fields"`
+	}
+
+	testDecodeItem struct {
+		Name  string `attr:"name" required:"true"`
+		Value int64  `attr:"value"`
+	}
+)
+
+func TestDecode(t *testing.T) {
+	attrs := testAttrs(t, `
+channel: "1234567890123456789"
+color: "#2ECC71"
+embed:
+  fields:
+    - name: viewers
+      value: 12
+interval: 1m30s
+limit: 10
+names: [a, b]
+required: "set"
+text_luziferus: "Hello"
+text_other: "World"
+timezone: Europe/Berlin
+`)
+
+	var cfg testDecodeConfig
+	cfg.Untagged = "untouched"
+
+	if err := attrs.Decode(&cfg); err != nil {
+		t.Fatalf("decoding: %s", err)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("loading location: %s", err)
+	}
+
+	expected := testDecodeConfig{
+		Channel: 1234567890123456789,
+		Color:   0x2ECC71,
+		Embed: &testDecodeEmbed{
+			Title:  "Live!",
+			Fields: []testDecodeItem{{Name: "viewers", Value: 12}},
+		},
+		Enabled:   true,
+		Interval:  90 * time.Second,
+		Limit:     10,
+		Names:     []string{"a", "b"},
+		Overrides: map[string]string{"luziferus": "Hello", "other": "World"},
+		Required:  "set",
+		Timezone:  berlin,
+		Untagged:  "untouched",
+	}
+
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("unexpected result:\n got %+v\nwant %+v", cfg, expected)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		attrs    string
+		expected []string
+	}{
+		"missing required": {
+			attrs:    `limit: 1`,
+			expected: []string{"required"},
+		},
+		"wrong types": {
+			attrs:    "required: x\nlimit: 1.5\ninterval: 5\nnames: a\nenabled: maybe",
+			expected: []string{"enabled", "interval", "limit", "names"},
+		},
+		"nested": {
+			attrs:    "required: x\nembed:\n  fields:\n    - value: 1",
+			expected: []string{"embed"},
+		},
+		"negative snowflake": {
+			attrs:    "required: x\nchannel: -1",
+			expected: []string{"channel"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var cfg testDecodeConfig
+			err := testAttrs(t, tc.attrs).Decode(&cfg)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+
+			var names []string
+			for _, e := range err.(interface{ Unwrap() []error }).Unwrap() { //nolint:errorlint,forcetypeassert // Decode joins the errors
+				var aErr AttributeError
+				if !errors.As(e, &aErr) {
+					t.Fatalf("expected AttributeError, got %T", e)
+				}
+				names = append(names, aErr.Name)
+			}
+
+			slices.Sort(names)
+			if !slices.Equal(names, tc.expected) {
+				t.Errorf("expected errors for %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestDecodeLargeIntegers(t *testing.T) {
+	const snowflake = int64(1234567890123456789) // Above 2^53
+
+	for name, value := range map[string]any{
+		"int64":       snowflake,
+		"uint64":      uint64(snowflake),
+		"string":      "1234567890123456789",
+		"json.Number": json.Number("1234567890123456789"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var cfg testDecodeConfig
+			if err := (ModuleAttributeStore{"required": "x", "limit": value, "channel": value}).Decode(&cfg); err != nil {
+				t.Fatalf("decoding: %s", err)
+			}
+
+			if cfg.Limit != snowflake || cfg.Channel != uint64(snowflake) {
+				t.Errorf("expected %d, got limit %d and channel %d", snowflake, cfg.Limit, cfg.Channel)
+			}
+		})
+	}
+
+	// Values parsed from YAML are not converted through floats
+	var cfg testDecodeConfig
+	if err := testAttrs(t, "required: x\nlimit: 1234567890123456789").Decode(&cfg); err != nil || cfg.Limit != snowflake {
+		t.Errorf("expected %d, got %d (%v)", snowflake, cfg.Limit, err)
+	}
+
+	for _, value := range []any{uint64(1 << 63), "12.5", json.Number("1.5"), 1e19} {
+		if err := (ModuleAttributeStore{"required": "x", "limit": value}).Decode(&cfg); err == nil {
+			t.Errorf("expected %v (%T) to be rejected", value, value)
+		}
+	}
+}
+
+func TestDecodeMissingRequiredIsValueNotSet(t *testing.T) {
+	var cfg testDecodeConfig
+	if err := (ModuleAttributeStore{}).Decode(&cfg); !errors.Is(err, ErrValueNotSet) {
+		t.Errorf("expected ErrValueNotSet, got %v", err)
+	}
+}
+
+func TestFields(t *testing.T) {
+	types := make(map[string]string)
+	for _, f := range Fields(testDecodeConfig{}) {
+		types[f.Name] = f.Type
+	}
+
+	expected := map[string]string{
+		"channel":         "snowflake",
+		"color":           "color",
+		"embed":           "object",
+		"enabled":         "bool",
+		"interval":        "duration",
+		"limit":           "int64",
+		"names":           "[]string",
+		"text_{username}": "string",
+		"required":        "string",
+		"timezone":        "timezone",
+	}
+
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("unexpected fields:\n got %v\nwant %v", types, expected)
+	}
+}
+
+func testAttrs(t *testing.T, src string) ModuleAttributeStore {
+	t.Helper()
+
+	var attrs ModuleAttributeStore
+	if err := yaml.Unmarshal([]byte(src), &attrs); err != nil {
+		t.Fatalf("parsing attributes: %s", err)
+	}
+
+	return attrs
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"text/template"
+	"time"
 
 	korvike "github.com/Luzifer/korvike/functions"
 	"github.com/sirupsen/logrus"
@@ -18,19 +19,66 @@ import (
 type (
 	// File represents the contents of a config file
 	File struct {
+		AdminToken    string `yaml:"admin_token"`
 		BotToken      string `yaml:"bot_token"`
 		GuildID       string `yaml:"guild_id"`
 		StoreLocation string `yaml:"store_location"`
 
+		Kick           KickConfig           `yaml:"kick"`
+		Twitch         TwitchConfig         `yaml:"twitch"`
+		TwitchAuth     TwitchAuthConfig     `yaml:"twitch_auth"`
+		TwitchEventSub TwitchEventSubConfig `yaml:"twitch_eventsub"`
+		YouTube        YouTubeConfig        `yaml:"youtube"`
+
 		ModuleConfigs []ModuleConfig `yaml:"module_configs"`
 	}
 
+	// KickConfig contains the Kick app used to look up channels and
+	// streams on Kick
+	KickConfig struct {
+		ClientID     string `yaml:"client_id"`
+		ClientSecret string `yaml:"client_secret"`
+	}
+
 	// ModuleConfig contains the configuration for a module
 	ModuleConfig struct {
 		ID         string                              `yaml:"id"`
 		Type       string                              `yaml:"type"`
 		Attributes attributestore.ModuleAttributeStore `yaml:"attributes"`
 	}
+
+	// TwitchConfig contains the Twitch app used by all modules and
+	// Twitch features not overriding the credentials and the settings
+	// of the Twitch client
+	TwitchConfig struct {
+		ClientID       string        `yaml:"client_id"`
+		ClientSecret   string        `yaml:"client_secret"`
+		RequestTimeout time.Duration `yaml:"request_timeout"`
+		Retries        *int          `yaml:"retries"`
+	}
+
+	// TwitchAuthConfig contains the Twitch app users authorize to let
+	// modules make requests using their tokens
+	TwitchAuthConfig struct {
+		ClientID     string `yaml:"client_id"`
+		ClientSecret string `yaml:"client_secret"`
+		RedirectURL  string `yaml:"redirect_url"`
+	}
+
+	// TwitchEventSubConfig contains the settings to receive Twitch
+	// EventSub notifications through webhooks
+	TwitchEventSubConfig struct {
+		CallbackURL  string `yaml:"callback_url"`
+		ClientID     string `yaml:"client_id"`
+		ClientSecret string `yaml:"client_secret"`
+		Secret       string `yaml:"secret"`
+	}
+
+	// YouTubeConfig contains the API key used to look up channels and
+	// streams on YouTube
+	YouTubeConfig struct {
+		APIKey string `yaml:"api_key"`
+	}
 )
 
 // NewFromFile reads the configuration from the given file
@@ -1,3 +1,4 @@
+// Package helpers contains shared helper functions.
 package helpers
 
 import (
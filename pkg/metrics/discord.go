@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DiscordTransport instruments the requests to the Discord REST API
+// before passing them to the wrapped RoundTripper
+type DiscordTransport struct {
+	Next http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (d DiscordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := d.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	route := discordRoute(req.URL.Path)
+	start := time.Now()
+
+	resp, err := next.RoundTrip(req)
+
+	DiscordRequestDuration.WithLabelValues(req.Method, route).Observe(time.Since(start).Seconds())
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	DiscordRequests.WithLabelValues(req.Method, route, status).Inc()
+
+	return resp, err //nolint:wrapcheck // Transport must pass errors unchanged
+}
+
+// discordRoute removes IDs, emojis and tokens from the request path
+// to keep the number of label values low
+func discordRoute(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	// Strip `api/v9` prefix
+	if len(parts) >= 2 && parts[0] == "api" && strings.HasPrefix(parts[1], "v") {
+		parts = parts[2:]
+	}
+
+	for i, p := range parts {
+		switch {
+		case i > 0 && parts[i-1] == "reactions":
+			parts[i] = ":emoji"
+
+		case i > 0 && (parts[i-1] == "interactions" || parts[i-1] == "webhooks") && i+1 < len(parts):
+			// Interaction and webhook tokens follow their ID
+			parts[i+1] = ":token"
+			if _, err := strconv.ParseUint(p, 10, 64); err == nil {
+				parts[i] = ":id"
+			}
+
+		case p == ":token":
+			// Already replaced
+
+		default:
+			if _, err := strconv.ParseUint(p, 10, 64); err == nil {
+				parts[i] = ":id"
+			}
+		}
+	}
+
+	return "/" + strings.Join(parts, "/")
+}
@@ -0,0 +1,35 @@
+package metrics
+
+import "sync"
+
+// LiveTracker keeps track of the streamers a module has seen live and
+// reports their number through the LiveStreamers gauge
+type LiveTracker struct {
+	live     map[string]struct{}
+	lock     sync.Mutex
+	moduleID string
+}
+
+// NewLiveTracker creates a LiveTracker for the given module ID
+func NewLiveTracker(moduleID string) *LiveTracker {
+	return &LiveTracker{
+		live:     make(map[string]struct{}),
+		moduleID: moduleID,
+	}
+}
+
+// SetLive records whether the streamer is currently live
+//
+//revive:disable-next-line:flag-parameter // Not a flag but the state to record
+func (l *LiveTracker) SetLive(streamer string, live bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if live {
+		l.live[streamer] = struct{}{}
+	} else {
+		delete(l.live, streamer)
+	}
+
+	LiveStreamers.WithLabelValues(l.moduleID).Set(float64(len(l.live)))
+}
@@ -0,0 +1,118 @@
+// Package metrics contains the Prometheus collectors exposed by the bot
+// and helpers to feed them.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "discord_community"
+
+var (
+	// CronJobDuration observes the execution time of module cron jobs
+	CronJobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cron_job_duration_seconds",
+		Help:      "Execution time of module cron jobs",
+	}, []string{"module"})
+
+	// CronJobFailures counts module cron jobs returning an error
+	CronJobFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cron_job_failures_total",
+		Help:      "Number of module cron job executions returning an error",
+	}, []string{"module"})
+
+	// DiscordRequestDuration observes the duration of Discord REST calls
+	DiscordRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "discord_request_duration_seconds",
+		Help:      "Duration of Discord REST API requests",
+	}, []string{"method", "route"})
+
+	// DiscordRequests counts Discord REST calls by route and status
+	DiscordRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "discord_requests_total",
+		Help:      "Number of Discord REST API requests",
+	}, []string{"method", "route", "status"})
+
+	// GatewayEvents counts the gateway events handled by modules
+	GatewayEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gateway_events_handled_total",
+		Help:      "Number of Discord gateway events passed to module handlers",
+	}, []string{"module", "event"})
+
+	// LiveStreamers holds the number of streamers currently seen live
+	LiveStreamers = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "live_streamers",
+		Help:      "Number of streamers currently seen live by a module",
+	}, []string{"module"})
+
+	// StoreWriteDuration observes the latency of persisting changes
+	// to the MetaStore
+	StoreWriteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_write_duration_seconds",
+		Help:      "Latency of persisting changes to the module store",
+	}, []string{"operation"})
+
+	// StreamingRequestDuration observes the duration of requests
+	// against the APIs of the streaming platforms besides Twitch
+	StreamingRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "streaming_request_duration_seconds",
+		Help:      "Duration of YouTube and Kick API requests",
+	}, []string{"platform", "endpoint"})
+
+	// StreamingRequests counts requests against the APIs of the
+	// streaming platforms besides Twitch by endpoint and status
+	StreamingRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "streaming_requests_total",
+		Help:      "Number of YouTube and Kick API requests",
+	}, []string{"platform", "endpoint", "status"})
+
+	// TwitchEventSubMessages counts the messages received through
+	// EventSub WebSocket sessions by message type
+	TwitchEventSubMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "twitch_eventsub_messages_total",
+		Help:      "Number of messages received through Twitch EventSub WebSocket sessions",
+	}, []string{"message_type"})
+
+	// TwitchRequestDuration observes the duration of Twitch API calls
+	TwitchRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "twitch_request_duration_seconds",
+		Help:      "Duration of Twitch API requests",
+	}, []string{"endpoint"})
+
+	// TwitchRequests counts Twitch API calls by endpoint and status
+	TwitchRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "twitch_requests_total",
+		Help:      "Number of Twitch API requests",
+	}, []string{"endpoint", "status"})
+
+	// TwitchRetries counts retried Twitch API calls
+	TwitchRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "twitch_request_retries_total",
+		Help:      "Number of Twitch API requests retried after a failure",
+	}, []string{"endpoint"})
+)
+
+// RemoveModule deletes all series labeled with the given module ID,
+// used when a module instance is torn down
+func RemoveModule(moduleID string) {
+	labels := prometheus.Labels{"module": moduleID}
+
+	CronJobDuration.DeletePartialMatch(labels)
+	CronJobFailures.DeletePartialMatch(labels)
+	GatewayEvents.DeletePartialMatch(labels)
+	LiveStreamers.DeletePartialMatch(labels)
+}
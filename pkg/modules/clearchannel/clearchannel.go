@@ -9,47 +9,57 @@ import (
 	"time"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/sirupsen/logrus"
 
-	"github.com/Luzifer/discord-community/pkg/attributestore"
 	"github.com/Luzifer/discord-community/pkg/modules"
 )
 
-/*
- * @module clearchannel
- * @module_desc Cleans up old messages from a channel (for example announcement channel) which are older than the retention time
- */
-
 const (
 	clearChannelNumberOfMessagesToLoad = 100
 )
 
-type modClearChannel struct {
-	attrs   attributestore.ModuleAttributeStore
-	discord *discordgo.Session
-	id      string
-}
+type (
+	modClearChannel struct {
+		cfg     moduleConfig
+		discord *discordgo.Session
+		id      string
+	}
+
+	moduleConfig struct {
+		ChannelID    string        `attr:"discord_channel_id" required:"true"`
+		Cron         string        `attr:"cron" default:"0 * * * *"`
+		OnlyUsers    []string      `attr:"only_users" default:"[]"`
+		ProtectUsers []string      `attr:"protect_users" default:"[]"`
+		Retention    time.Duration `attr:"retention" required:"true"`
+	}
+)
+
+var moduleSchema = modules.NewSchema(
+	"Cleans up old messages from a channel (for example announcement channel) which are older than the retention time",
+	moduleConfig{},
+	map[string]string{
+		"cron":               "When to execute the cleaner",
+		"discord_channel_id": "ID of the Discord channel to clean up",
+		"only_users":         "When this list contains user IDs, only posts authored by those IDs will be deleted",
+		"protect_users":      "When this list contains user IDs, posts authored by those IDs will not be deleted",
+		"retention":          "How long to keep messages in this channel",
+	},
+)
 
 func init() {
-	modules.RegisterModule("clearchannel", func() modules.Module { return &modClearChannel{} })
+	modules.RegisterModule("clearchannel", func() modules.Module { return &modClearChannel{} }, moduleSchema)
 }
 
 func (m modClearChannel) ID() string { return m.id }
 
 func (m *modClearChannel) Initialize(args modules.ModuleInitArgs) error {
-	m.attrs = args.Attrs
 	m.discord = args.Discord
 	m.id = args.ID
 
-	if err := args.Attrs.Expect(
-		"discord_channel_id",
-		"retention",
-	); err != nil {
-		return fmt.Errorf("validating attributes: %w", err)
+	if err := args.Attrs.Decode(&m.cfg); err != nil {
+		return fmt.Errorf("decoding attributes: %w", err)
 	}
 
-	// @attr cron optional string "0 * * * *" When to execute the cleaner
-	if _, err := args.Crontab.AddFunc(args.Attrs.MustString("cron", new("0 * * * *")), m.cronClearChannel); err != nil {
+	if err := args.AddCronFunc(m.cfg.Cron, m.cronClearChannel); err != nil {
 		return fmt.Errorf("adding cron function: %w", err)
 	}
 
@@ -58,44 +68,16 @@ func (m *modClearChannel) Initialize(args modules.ModuleInitArgs) error {
 
 func (modClearChannel) Setup() error { return nil }
 
-func (m modClearChannel) cronClearChannel() {
+func (m modClearChannel) cronClearChannel() error {
 	var (
-		after        = "0"
-		err          error
-		onlyUsers    []string
-		protectUsers []string
-
-		// @attr discord_channel_id required string "" ID of the Discord channel to clean up
-		channelID = m.attrs.MustString("discord_channel_id", nil)
-		// @attr retention required duration "" How long to keep messages in this channel
-		retention = m.attrs.MustDuration("retention", nil)
+		after     = "0"
+		channelID = m.cfg.ChannelID
 	)
 
-	// @attr only_users optional []string "[]" When this list contains user IDs, only posts authored by those IDs will be deleted
-	onlyUsers, err = m.attrs.StringSlice("only_users")
-	switch err {
-	case nil, attributestore.ErrValueNotSet:
-		// This is fine
-	default:
-		logrus.WithError(err).Error("Unable to load value for only_users")
-		return
-	}
-
-	// @attr protect_users optional []string "[]" When this list contains user IDs, posts authored by those IDs will not be deleted
-	protectUsers, err = m.attrs.StringSlice("protect_users")
-	switch err {
-	case nil, attributestore.ErrValueNotSet:
-		// This is fine
-	default:
-		logrus.WithError(err).Error("Unable to load value for protect_users")
-		return
-	}
-
 	for {
 		msgs, err := m.discord.ChannelMessages(channelID, clearChannelNumberOfMessagesToLoad, "", after, "")
 		if err != nil {
-			logrus.WithError(err).Error("Unable to fetch announcement channel messages")
-			return
+			return fmt.Errorf("fetching channel messages: %w", err)
 		}
 
 		sort.Slice(msgs, func(i, j int) bool {
@@ -109,27 +91,28 @@ func (m modClearChannel) cronClearChannel() {
 		}
 
 		for _, msg := range msgs {
-			if time.Since(msg.Timestamp) < retention {
+			if time.Since(msg.Timestamp) < m.cfg.Retention {
 				// We got to the first message within the retention time, we can end now
 				break
 			}
 
-			if len(onlyUsers) > 0 && !slices.Contains(onlyUsers, msg.Author.ID) {
+			if len(m.cfg.OnlyUsers) > 0 && !slices.Contains(m.cfg.OnlyUsers, msg.Author.ID) {
 				// Is not written by one of the users we may purge
 				continue
 			}
 
-			if len(protectUsers) > 0 && slices.Contains(protectUsers, msg.Author.ID) {
+			if len(m.cfg.ProtectUsers) > 0 && slices.Contains(m.cfg.ProtectUsers, msg.Author.ID) {
 				// Is written by protected user, we may not purge
 				continue
 			}
 
 			if err = m.discord.ChannelMessageDelete(channelID, msg.ID); err != nil {
-				logrus.WithError(err).Error("Unable to delete messages")
-				return
+				return fmt.Errorf("deleting message: %w", err)
 			}
 
 			after = msg.ID
 		}
 	}
+
+	return nil
 }
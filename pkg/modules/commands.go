@@ -0,0 +1,230 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/metrics"
+)
+
+const componentCustomIDSeparator = ":"
+
+type (
+	// InteractionHandler is called for interactions targeting a
+	// command or message component registered by the module
+	InteractionHandler func(s *discordgo.Session, i *discordgo.InteractionCreate)
+
+	interactionRoute struct {
+		handler   InteractionHandler
+		moduleID  string
+		resources *instanceResources
+	}
+
+	registeredCommand struct {
+		command *discordgo.ApplicationCommand
+		handler InteractionHandler
+	}
+)
+
+// AddCommand registers an application command (including its options,
+// sub-commands and default member permissions) which is synced to the
+// configured guild during Setup. Interactions for the command and its
+// autocompletion are passed to the handler. Command names must be
+// unique across all module instances. The command is removed from the
+// guild on the next Setup after the module was torn down.
+func (a ModuleInitArgs) AddCommand(cmd *discordgo.ApplicationCommand, handler InteractionHandler) error {
+	if cmd == nil || cmd.Name == "" {
+		return errors.New("command has no name")
+	}
+
+	if slices.ContainsFunc(a.resources.commands, func(c registeredCommand) bool { return c.command.Name == cmd.Name }) {
+		return fmt.Errorf("command %q registered twice", cmd.Name)
+	}
+
+	a.resources.commands = append(a.resources.commands, registeredCommand{command: cmd, handler: handler})
+	return nil
+}
+
+// AddComponentHandler registers the handler for message component and
+// modal submit interactions whose custom ID was created using
+// ComponentCustomID with the ID of the module instance
+func (a ModuleInitArgs) AddComponentHandler(handler InteractionHandler) {
+	a.resources.componentHandler = handler
+}
+
+// ComponentCustomID creates a custom ID for message components and
+// modals which routes their interactions to the component handler of
+// the given module instance
+func ComponentCustomID(moduleID, id string) string {
+	return strings.Join([]string{moduleID, id}, componentCustomIDSeparator)
+}
+
+// ParseComponentCustomID returns the module specific part of a custom
+// ID created using ComponentCustomID
+func ParseComponentCustomID(customID string) string {
+	_, id, _ := strings.Cut(customID, componentCustomIDSeparator)
+	return id
+}
+
+// addRoutes makes the commands and the component handler of the
+// instance available for interaction dispatching and the EventSub
+// handler available for notification dispatching
+func (m *Manager) addRoutes(inst *instance) error {
+	m.routeLock.Lock()
+	defer m.routeLock.Unlock()
+
+	for _, cmd := range inst.resources.commands {
+		if r, ok := m.commandRoutes[cmd.command.Name]; ok && r.moduleID != inst.config.ID {
+			return fmt.Errorf("command %q already registered by module %q", cmd.command.Name, r.moduleID)
+		}
+	}
+
+	for _, cmd := range inst.resources.commands {
+		m.commandRoutes[cmd.command.Name] = interactionRoute{
+			handler:   cmd.handler,
+			moduleID:  inst.config.ID,
+			resources: inst.resources,
+		}
+	}
+
+	if inst.resources.componentHandler != nil {
+		m.componentRoutes[inst.config.ID] = interactionRoute{
+			handler:   inst.resources.componentHandler,
+			moduleID:  inst.config.ID,
+			resources: inst.resources,
+		}
+	}
+
+	if h := inst.resources.eventSubHandler; h != nil {
+		m.eventSubRoutes[inst.config.ID] = eventSubRoute{
+			handler:   h.handler,
+			logins:    h.logins,
+			resources: inst.resources,
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) handleInteraction(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var (
+		route interactionRoute
+		ok    bool
+	)
+
+	m.routeLock.RLock()
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		route, ok = m.commandRoutes[i.ApplicationCommandData().Name]
+
+	case discordgo.InteractionMessageComponent:
+		moduleID, _, _ := strings.Cut(i.MessageComponentData().CustomID, componentCustomIDSeparator)
+		route, ok = m.componentRoutes[moduleID]
+
+	case discordgo.InteractionModalSubmit:
+		moduleID, _, _ := strings.Cut(i.ModalSubmitData().CustomID, componentCustomIDSeparator)
+		route, ok = m.componentRoutes[moduleID]
+
+	case discordgo.InteractionPing:
+		// Only used for HTTP interactions, nothing to dispatch
+	}
+	if ok {
+		// Must happen before releasing the lock: teardown removes the
+		// routes before waiting for running handlers
+		route.resources.running.Add(1)
+	}
+	m.routeLock.RUnlock()
+
+	if !ok {
+		logrus.WithFields(logrus.Fields{
+			"id":   i.ID,
+			"type": i.Type.String(),
+		}).Debug("received interaction without registered handler")
+		return
+	}
+
+	defer route.resources.running.Done()
+
+	metrics.GatewayEvents.WithLabelValues(route.moduleID, "InteractionCreate").Inc()
+
+	route.handler(s, i)
+}
+
+// removeRoutes removes all routes pointing to the given instance
+func (m *Manager) removeRoutes(inst *instance) {
+	m.routeLock.Lock()
+	defer m.routeLock.Unlock()
+
+	for name, r := range m.commandRoutes {
+		if r.resources == inst.resources {
+			delete(m.commandRoutes, name)
+		}
+	}
+
+	if r, ok := m.componentRoutes[inst.config.ID]; ok && r.resources == inst.resources {
+		delete(m.componentRoutes, inst.config.ID)
+	}
+
+	if r, ok := m.eventSubRoutes[inst.config.ID]; ok && r.resources == inst.resources {
+		delete(m.eventSubRoutes, inst.config.ID)
+	}
+}
+
+// syncCommands registers the commands of all active instances in the
+// guild and removes commands no longer registered by any instance
+// (including the ones of earlier runs). As long as no instance
+// registered a command the guild is only overwritten when it still
+// contains commands, so bots never having used commands do not need
+// the applications.commands scope for updates.
+func (m *Manager) syncCommands() error {
+	cmds := []*discordgo.ApplicationCommand{}
+	for _, inst := range m.instances {
+		for _, cmd := range inst.resources.commands {
+			cmds = append(cmds, cmd.command)
+		}
+	}
+
+	if m.commandsSynced && reflect.DeepEqual(cmds, m.syncedCommands) {
+		// Nothing changed since last sync
+		return nil
+	}
+
+	if m.applicationID == "" {
+		app, err := m.discord.Application("@me")
+		if err != nil {
+			return fmt.Errorf("fetching application: %w", err)
+		}
+		m.applicationID = app.ID
+	}
+
+	if !m.commandsSynced && len(cmds) == 0 {
+		registered, err := m.discord.ApplicationCommands(m.applicationID, m.guildID)
+		if err != nil {
+			return fmt.Errorf("fetching guild commands: %w", err)
+		}
+
+		if len(registered) == 0 {
+			// Nothing registered locally or in the guild, nothing to remove
+			m.commandsSynced = true
+			m.syncedCommands = cmds
+			return nil
+		}
+	}
+
+	if _, err := m.discord.ApplicationCommandBulkOverwrite(m.applicationID, m.guildID, cmds); err != nil {
+		return fmt.Errorf("overwriting guild commands: %w", err)
+	}
+
+	logrus.WithField("commands", len(cmds)).Debug("synced application commands")
+
+	m.commandsSynced = true
+	m.syncedCommands = cmds
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron/v3"
+
+	"github.com/Luzifer/discord-community/pkg/config"
+)
+
+type (
+	roundTripperFunc func(*http.Request) (*http.Response, error)
+
+	testCommandModule struct {
+		id string
+	}
+)
+
+// testHandlerFn is called by the handlers of the testCommandModule
+var testHandlerFn InteractionHandler
+
+func init() {
+	RegisterModule("test-commands", func() Module { return &testCommandModule{} }, NewSchema("Test module", struct{}{}, nil))
+}
+
+func (r roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return r(req) }
+
+func (t *testCommandModule) ID() string { return t.id }
+
+func (t *testCommandModule) Initialize(args ModuleInitArgs) error {
+	t.id = args.ID
+
+	args.AddComponentHandler(testHandler)
+	return args.AddCommand(&discordgo.ApplicationCommand{Name: "ping"}, testHandler)
+}
+
+func (*testCommandModule) Setup() error { return nil }
+
+func TestInteractionDispatchAndTeardown(t *testing.T) {
+	mgr := NewManager(cron.New(), &discordgo.Session{}, nil)
+	cfg := &config.File{ModuleConfigs: []config.ModuleConfig{{ID: "cmds", Type: "test-commands"}}}
+
+	if err := mgr.Apply(context.Background(), cfg); err != nil {
+		t.Fatalf("applying config: %s", err)
+	}
+
+	var (
+		called   = make(chan string, 1)
+		release  = make(chan struct{})
+		teardown = make(chan struct{})
+	)
+
+	testHandlerFn = func(_ *discordgo.Session, i *discordgo.InteractionCreate) {
+		called <- i.ID
+		<-release
+	}
+
+	go mgr.handleInteraction(nil, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:   "cmd",
+		Type: discordgo.InteractionApplicationCommand,
+		Data: discordgo.ApplicationCommandInteractionData{Name: "ping"},
+	}})
+
+	if id := <-called; id != "cmd" {
+		t.Fatalf("unexpected interaction %q dispatched", id)
+	}
+
+	go func() {
+		mgr.Shutdown(context.Background())
+		close(teardown)
+	}()
+
+	select {
+	case <-teardown:
+		t.Fatal("teardown finished while the handler was running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	<-teardown
+
+	// Routes are removed after the teardown
+	mgr.handleInteraction(nil, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:   "component",
+		Type: discordgo.InteractionMessageComponent,
+		Data: discordgo.MessageComponentInteractionData{CustomID: ComponentCustomID("cmds", "button")},
+	}})
+
+	select {
+	case id := <-called:
+		t.Fatalf("interaction %q dispatched after teardown", id)
+	default:
+	}
+}
+
+func TestSetupLogsCommandSyncFailure(t *testing.T) {
+	var requests int
+	discord := newTestSession(t, func(*http.Request) (*http.Response, error) {
+		requests++
+		return nil, errors.New("missing scope")
+	})
+
+	mgr := NewManager(cron.New(), discord, nil)
+	cfg := &config.File{ModuleConfigs: []config.ModuleConfig{{ID: "cmds", Type: "test-commands"}}}
+
+	if err := mgr.Apply(context.Background(), cfg); err != nil {
+		t.Fatalf("applying config: %s", err)
+	}
+	defer mgr.Shutdown(context.Background())
+
+	if err := mgr.Setup(context.Background()); err != nil {
+		t.Fatalf("expected failed command sync not to fail setup: %s", err)
+	}
+
+	if requests == 0 {
+		t.Error("expected commands to be synced")
+	}
+
+	if p := mgr.Problems(); len(p) > 0 {
+		t.Errorf("expected module to be set up, got problems %v", p)
+	}
+}
+
+func TestComponentDispatch(t *testing.T) {
+	mgr := NewManager(cron.New(), &discordgo.Session{}, nil)
+	cfg := &config.File{ModuleConfigs: []config.ModuleConfig{{ID: "cmds", Type: "test-commands"}}}
+
+	if err := mgr.Apply(context.Background(), cfg); err != nil {
+		t.Fatalf("applying config: %s", err)
+	}
+	defer mgr.Shutdown(context.Background())
+
+	var customID string
+	testHandlerFn = func(_ *discordgo.Session, i *discordgo.InteractionCreate) {
+		customID = ParseComponentCustomID(i.MessageComponentData().CustomID)
+	}
+
+	for _, target := range []string{"other", "cmds"} {
+		mgr.handleInteraction(nil, &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type: discordgo.InteractionMessageComponent,
+			Data: discordgo.MessageComponentInteractionData{CustomID: ComponentCustomID(target, "button")},
+		}})
+	}
+
+	if customID != "button" {
+		t.Errorf("expected component handler to receive %q, got %q", "button", customID)
+	}
+}
+
+func TestSetupClearsStaleCommands(t *testing.T) {
+	for name, tc := range map[string]struct {
+		registered string
+		expectPut  bool
+	}{
+		"stale commands": {registered: `[{"id":"1","name":"stale"}]`, expectPut: true},
+		"no commands":    {registered: `[]`, expectPut: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var overwrites []string
+			discord := newTestSession(t, func(req *http.Request) (*http.Response, error) {
+				switch {
+				case req.Method == http.MethodGet && strings.HasSuffix(req.URL.Path, "/oauth2/applications/@me"):
+					return jsonResponse(`{"id":"app"}`), nil
+
+				case strings.HasSuffix(req.URL.Path, "/applications/app/guilds/guild/commands"):
+					if req.Method == http.MethodGet {
+						return jsonResponse(tc.registered), nil
+					}
+
+					body, err := io.ReadAll(req.Body)
+					if err != nil {
+						return nil, fmt.Errorf("reading body: %w", err)
+					}
+					overwrites = append(overwrites, strings.TrimSpace(string(body)))
+					return jsonResponse(`[]`), nil
+				}
+
+				t.Errorf("unexpected request %s %s", req.Method, req.URL)
+				return nil, errors.New("unexpected request")
+			})
+
+			mgr := NewManager(cron.New(), discord, nil)
+
+			if err := mgr.Apply(context.Background(), &config.File{GuildID: "guild"}); err != nil {
+				t.Fatalf("applying config: %s", err)
+			}
+
+			if err := mgr.Setup(context.Background()); err != nil {
+				t.Fatalf("running setup: %s", err)
+			}
+
+			switch {
+			case tc.expectPut && (len(overwrites) != 1 || overwrites[0] != "[]"):
+				t.Errorf("expected guild commands to be overwritten with an empty list, got %v", overwrites)
+			case !tc.expectPut && len(overwrites) > 0:
+				t.Errorf("expected guild not to be touched, got overwrites %v", overwrites)
+			}
+		})
+	}
+}
+
+// jsonResponse creates a successful response carrying the given body
+func jsonResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// newTestSession creates a Discord session passing all requests to
+// the given function instead of the Discord API
+func newTestSession(t *testing.T, fn roundTripperFunc) *discordgo.Session {
+	t.Helper()
+
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatalf("creating session: %s", err)
+	}
+
+	s.MaxRestRetries = 0
+	s.Client = &http.Client{Transport: fn}
+
+	return s
+}
+
+func testHandler(s *discordgo.Session, i *discordgo.InteractionCreate) { testHandlerFn(s, i) }
@@ -0,0 +1,181 @@
+package modules
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/metrics"
+)
+
+type (
+	// CronJobStatus describes the state of a cron job registered by a
+	// module instance
+	CronJobStatus struct {
+		Index     int       `json:"index"`
+		Spec      string    `json:"spec"`
+		Running   bool      `json:"running"`
+		LastRun   time.Time `json:"last_run,omitzero"`
+		LastError string    `json:"last_error,omitempty"`
+		NextRun   time.Time `json:"next_run,omitzero"`
+	}
+
+	// ModuleStatus describes an active module instance
+	ModuleStatus struct {
+		ID       string          `json:"id"`
+		Type     string          `json:"type"`
+		CronJobs []CronJobStatus `json:"cron_jobs"`
+	}
+
+	cronJob struct {
+		cmd       func() error
+		entryID   cron.EntryID
+		moduleID  string
+		resources *instanceResources
+		spec      string
+
+		lastError error
+		lastRun   time.Time
+		lock      sync.Mutex
+		runLock   sync.Mutex
+		running   bool
+	}
+)
+
+var (
+	// ErrCronJobNotFound signals the module has no cron job with the
+	// given index
+	ErrCronJobNotFound = errors.New("cron job not found")
+
+	// ErrCronJobRunning signals the cron job is already running and
+	// cannot be triggered again until it finished
+	ErrCronJobRunning = errors.New("cron job is already running")
+
+	// ErrModuleNotFound signals there is no active module instance
+	// with the given ID
+	ErrModuleNotFound = errors.New("module not found")
+)
+
+// Modules returns the status of all active module instances
+func (m *Manager) Modules() []ModuleStatus {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	out := make([]ModuleStatus, 0, len(m.instances))
+	for _, inst := range m.instances {
+		ms := ModuleStatus{
+			ID:       inst.config.ID,
+			Type:     inst.config.Type,
+			CronJobs: make([]CronJobStatus, 0, len(inst.resources.cronJobs)),
+		}
+
+		for i, job := range inst.resources.cronJobs {
+			ms.CronJobs = append(ms.CronJobs, job.status(i, m.crontab.Entry(job.entryID).Next))
+		}
+
+		out = append(out, ms)
+	}
+
+	return out
+}
+
+// TriggerCronJob starts the cron job with the given index of the
+// module instance immediately without waiting for its schedule. The
+// job is executed in the background.
+func (m *Manager) TriggerCronJob(moduleID string, idx int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	inst := m.getInstance(moduleID)
+	if inst == nil {
+		return ErrModuleNotFound
+	}
+
+	if idx < 0 || idx >= len(inst.resources.cronJobs) {
+		return ErrCronJobNotFound
+	}
+
+	job := inst.resources.cronJobs[idx]
+	if !job.tryStart() {
+		return ErrCronJobRunning
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"id":  moduleID,
+		"job": idx,
+	}).Info("cron job triggered manually")
+
+	go job.execute()
+
+	return nil
+}
+
+// execute runs the job and records its result, the job must have been
+// started using tryStart before
+func (j *cronJob) execute() {
+	defer j.resources.running.Done()
+	defer j.runLock.Unlock()
+
+	start := time.Now()
+
+	j.lock.Lock()
+	j.running = true
+	j.lastRun = start
+	j.lock.Unlock()
+
+	err := j.cmd()
+	metrics.CronJobDuration.WithLabelValues(j.moduleID).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.CronJobFailures.WithLabelValues(j.moduleID).Inc()
+		logrus.WithError(err).WithField("id", j.moduleID).Error("executing cron job")
+	}
+
+	j.lock.Lock()
+	j.running = false
+	j.lastError = err
+	j.lock.Unlock()
+}
+
+// run is executed by the crontab and skips the execution if the job
+// is still running (e.g. because it was triggered manually)
+func (j *cronJob) run() {
+	if !j.tryStart() {
+		logrus.WithField("id", j.moduleID).Warn("cron job still running, skipping execution")
+		return
+	}
+
+	j.execute()
+}
+
+func (j *cronJob) status(idx int, next time.Time) CronJobStatus {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	s := CronJobStatus{
+		Index:   idx,
+		Spec:    j.spec,
+		Running: j.running,
+		LastRun: j.lastRun,
+		NextRun: next,
+	}
+
+	if j.lastError != nil {
+		s.LastError = j.lastError.Error()
+	}
+
+	return s
+}
+
+// tryStart marks the job as running, returns false if the job is
+// already running
+func (j *cronJob) tryStart() bool {
+	if !j.runLock.TryLock() {
+		return false
+	}
+
+	j.resources.running.Add(1)
+	return true
+}
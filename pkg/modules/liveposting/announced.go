@@ -0,0 +1,98 @@
+package liveposting
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+)
+
+// livePostingAnnouncedKeyPrefix prefixes the keys of the announced
+// streams in the MetaStore, the values contain the expiry
+const livePostingAnnouncedKeyPrefix = "announced_"
+
+// isAnnounced checks whether a post was already sent for the stream
+// and the record did not expire yet
+func (m *modLivePosting) isAnnounced(streamID string) (bool, error) {
+	var announced bool
+
+	if err := m.store.ReadWithLock(m.id, func(a attributestore.ModuleAttributeStore) error {
+		raw, err := a.String(livePostingAnnouncedKeyPrefix + streamID)
+		if errors.Is(err, attributestore.ErrValueNotSet) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("getting announced stream: %w", err)
+		}
+
+		expiry, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return fmt.Errorf("parsing expiry: %w", err)
+		}
+
+		announced = time.Now().Before(expiry)
+		return nil
+	}); err != nil {
+		return false, fmt.Errorf("reading announced stream: %w", err)
+	}
+
+	return announced, nil
+}
+
+// markAnnounced records the stream as announced until the retention
+// of the module passed
+func (m *modLivePosting) markAnnounced(streamID string) error {
+	// Stored encoded as the backends do not preserve the type
+	expiry := time.Now().Add(m.cfg.AnnouncedRetention).UTC().Format(time.RFC3339)
+
+	if err := m.store.Set(m.id, livePostingAnnouncedKeyPrefix+streamID, expiry); err != nil {
+		return fmt.Errorf("storing announced stream: %w", err)
+	}
+
+	return nil
+}
+
+// purgeAnnounced removes the expired records of announced streams
+func (m *modLivePosting) purgeAnnounced() error {
+	var expired []string
+
+	if err := m.store.ReadWithLock(m.id, func(a attributestore.ModuleAttributeStore) error {
+		for key := range a {
+			if !strings.HasPrefix(key, livePostingAnnouncedKeyPrefix) {
+				continue
+			}
+
+			raw, err := a.String(key)
+			if err != nil {
+				return fmt.Errorf("getting announced stream: %w", err)
+			}
+
+			if expiry, err := time.Parse(time.RFC3339, raw); err == nil && time.Now().Before(expiry) {
+				continue
+			}
+
+			// Expired or unreadable records are of no use anymore
+			expired = append(expired, key)
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("reading announced streams: %w", err)
+	}
+
+	for _, key := range expired {
+		if err := m.store.Delete(m.id, key); err != nil {
+			return fmt.Errorf("deleting announced stream: %w", err)
+		}
+	}
+
+	if len(expired) > 0 {
+		logrus.WithField("count", len(expired)).Debug("Purged expired announced streams")
+	}
+
+	return nil
+}
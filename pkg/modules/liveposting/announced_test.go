@@ -0,0 +1,114 @@
+package liveposting
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/modules"
+	"github.com/Luzifer/discord-community/pkg/streaming"
+)
+
+func TestSendLivePostAnnouncedOnce(t *testing.T) {
+	var posts int
+	m := newTestModule(t, moduleAttrs(nil), func(req *http.Request) (*http.Response, error) {
+		if req.Method != http.MethodPost || !strings.HasSuffix(req.URL.Path, "/channels/chan/messages") {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+			return nil, errors.New("unexpected request")
+		}
+
+		posts++
+		return jsonResponse(t, discordgo.Message{ID: fmt.Sprintf("msg-%d", posts), ChannelID: "chan"}), nil
+	})
+
+	// The store is reopened to simulate a restart
+	location := filepath.Join(t.TempDir(), "store.yaml")
+	m.store = openTestStore(t, location)
+
+	var (
+		user   = streaming.User{ID: "1", Platform: streaming.PlatformTwitch, Login: "luziferus", DisplayName: "Luziferus"}
+		stream = streaming.Stream{ID: "10", Platform: streaming.PlatformTwitch, UserID: "1", UserLogin: "luziferus", StartedAt: time.Now()}
+	)
+
+	send := func(m *modLivePosting, expectedPosts int) {
+		t.Helper()
+
+		if err := m.sendLivePost(user, stream); err != nil {
+			t.Fatalf("sending post: %s", err)
+		}
+		if posts != expectedPosts {
+			t.Fatalf("expected %d posts, got %d", expectedPosts, posts)
+		}
+	}
+
+	send(m, 1)
+
+	// The stream went offline and the post was finished: going live
+	// again with the same stream must not create another post
+	if err := m.deleteLivePost(user.Key()); err != nil {
+		t.Fatalf("deleting post: %s", err)
+	}
+	send(m, 1)
+
+	if err := m.store.Close(); err != nil {
+		t.Fatalf("closing store: %s", err)
+	}
+
+	restarted := &modLivePosting{cfg: m.cfg, discord: m.discord, id: m.id, store: openTestStore(t, location)}
+	send(restarted, 1)
+
+	// Expired and unreadable records are purged, the stream is
+	// announced again once its record expired
+	expired := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	for key, value := range map[string]string{
+		livePostingAnnouncedKeyPrefix + stream.Key(): expired,
+		livePostingAnnouncedKeyPrefix + "old":        expired,
+		livePostingAnnouncedKeyPrefix + "broken":     "yesterday",
+	} {
+		if err := restarted.store.Set(restarted.id, key, value); err != nil {
+			t.Fatalf("storing record: %s", err)
+		}
+	}
+
+	send(restarted, 2)
+
+	if err := restarted.store.ReadWithLock(restarted.id, func(a attributestore.ModuleAttributeStore) error {
+		for _, key := range []string{"old", "broken"} {
+			if _, ok := a[livePostingAnnouncedKeyPrefix+key]; ok {
+				t.Errorf("expected record %q to be purged", key)
+			}
+		}
+		return nil
+	}); err != nil {
+		t.Fatalf("reading store: %s", err)
+	}
+
+	announced, err := restarted.isAnnounced(stream.Key())
+	if err != nil {
+		t.Fatalf("checking announced: %s", err)
+	}
+	if !announced {
+		t.Error("expected stream to be recorded as announced again")
+	}
+}
+
+// openTestStore opens the store at the location and closes it when
+// the test ends
+func openTestStore(t *testing.T, location string) *modules.MetaStore {
+	t.Helper()
+
+	store, err := modules.NewMetaStore(location)
+	if err != nil {
+		t.Fatalf("opening store: %s", err)
+	}
+	t.Cleanup(func() { store.Close() }) //nolint:errcheck,gosec // Might already be closed by the test
+
+	return store
+}
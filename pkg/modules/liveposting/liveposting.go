@@ -1,13 +1,12 @@
-// Package liveposting implements a module for announcing Twitch live streams.
+// Package liveposting implements a module for announcing live streams on Twitch, YouTube and Kick.
 package liveposting
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"net/url"
 	"slices"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,124 +16,301 @@ import (
 
 	"github.com/Luzifer/discord-community/pkg/attributestore"
 	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/metrics"
 	"github.com/Luzifer/discord-community/pkg/modules"
+	"github.com/Luzifer/discord-community/pkg/streaming"
 	"github.com/Luzifer/discord-community/pkg/twitch"
 )
 
-/*
- * @module liveposting
- * @module_desc Announces stream live status based on Discord streaming status
- */
-
 const (
-	livePostingDefaultStreamFreshness = 5 * time.Minute
-	livePostingDiscordProfileHeight   = 300
-	livePostingDiscordProfileWidth    = 300
 	livePostingNumberOfMessagesToLoad = 100
-	livePostingPreviewHeight          = 720
-	livePostingPreviewWidth           = 1280
-	livePostingTwitchColor            = 0x6441a5
+	livePostingStreamLookupAttempts   = 6
+	livePostingStreamLookupDelay      = 10 * time.Second
 )
 
-type modLivePosting struct {
-	attrs   attributestore.ModuleAttributeStore
-	discord *discordgo.Session
-	id      string
+type (
+	modLivePosting struct {
+		cfg     moduleConfig
+		discord *discordgo.Session
+		id      string
 
-	config *config.File
+		config        *config.File
+		eventSub      *twitch.EventSubClient
+		live          *metrics.LiveTracker
+		providers     streaming.Providers
+		store         *modules.MetaStore
+		twitch        *twitch.Adapter
+		webhookActive func(login string) bool
 
-	lock sync.Mutex
-}
+		lock sync.Mutex
+
+		presenceLock    sync.Mutex
+		presenceStreams map[string]string
+
+		userIDs     map[string]string
+		userIDsLock sync.RWMutex
+	}
+
+	moduleConfig struct {
+		AnnouncedRetention   time.Duration           `attr:"announced_retention" default:"72h"`
+		AutoPublish          bool                    `attr:"auto_publish" default:"false"`
+		ChannelID            string                  `attr:"discord_channel_id" required:"true"`
+		Cron                 string                  `attr:"cron" default:"*/5 * * * *"`
+		DisablePresence      bool                    `attr:"disable_presence" default:"false"`
+		EmbedAuthorIcon      string                  `attr:"embed_author_icon" default:"{{ .User.ProfileImageURL }}"`
+		EmbedAuthorName      string                  `attr:"embed_author_name" default:"{{ .User.DisplayName }}"`
+		EmbedColor           attributestore.Color    `attr:"embed_color" default:"0x6441a5"`
+		EmbedDescription     string                  `attr:"embed_description"`
+		EmbedFields          []embedField            `attr:"embed_fields" default:"[{name: Game, value: '{{ .Stream.GameName }}'}, {name: Viewers, value: '{{ .Stream.ViewerCount }}', inline: true}, {name: Uptime, value: '{{ .Uptime }}', inline: true}]"`
+		EmbedFooter          string                  `attr:"embed_footer"`
+		EmbedImage           string                  `attr:"embed_image" default:"{{ .PreviewURL }}"`
+		EmbedImageHeight     int64                   `attr:"embed_image_height" default:"720"`
+		EmbedImageWidth      int64                   `attr:"embed_image_width" default:"1280"`
+		EmbedThumbnail       string                  `attr:"embed_thumbnail" default:"{{ .User.ProfileImageURL }}"`
+		EmbedThumbnailHeight int64                   `attr:"embed_thumbnail_height" default:"300"`
+		EmbedThumbnailWidth  int64                   `attr:"embed_thumbnail_width" default:"300"`
+		EmbedTitle           string                  `attr:"embed_title" default:"{{ .Stream.Title }}"`
+		EmbedURL             string                  `attr:"embed_url" default:"{{ .User.URL }}"`
+		MentionRoleID        string                  `attr:"mention_role_id"`
+		OfflineAction        string                  `attr:"offline_action" default:"edit"`
+		OfflineDurationText  string                  `attr:"offline_duration_text" default:"Was live for"`
+		OfflineGameLabel     string                  `attr:"offline_game_label" default:"Game"`
+		OfflineText          string                  `attr:"offline_text"`
+		OfflineVODLabel      string                  `attr:"offline_vod_label" default:"VOD"`
+		PollUsernames        []string                `attr:"poll_usernames" default:"[]"`
+		PostText             string                  `attr:"post_text" required:"true"`
+		PostTextOverrides    map[string]string       `attr:"post_text_{username}"`
+		PreserveProxy        string                  `attr:"preserve_proxy"`
+		QuietHours           string                  `attr:"quiet_hours"`
+		RemoveOld            bool                    `attr:"remove_old" default:"false"`
+		Routes               []liveRoute             `attr:"routes" default:"[]"`
+		StreamFreshness      time.Duration           `attr:"stream_freshness" default:"5m"`
+		Templates            map[string]postTemplate `attr:"template_{username}"`
+		ThreadID             string                  `attr:"discord_thread_id"`
+		Timezone             *time.Location          `attr:"timezone" default:"UTC"`
+		TwitchClientID       string                  `attr:"twitch_client_id"`
+		TwitchClientSecret   string                  `attr:"twitch_client_secret"`
+		TwitchUserToken      string                  `attr:"twitch_user_token"`
+		UpdateCron           string                  `attr:"update_cron" default:"*/5 * * * *"`
+		WhitelistedRole      string                  `attr:"whitelisted_role"`
+	}
+)
+
+var moduleSchema = modules.NewSchema(
+	"Announces stream live status based on Discord streaming status",
+	moduleConfig{},
+	map[string]string{
+		"announced_retention":    "How long to remember a stream was announced to prevent duplicate posts (keep this above the maximum stream length)",
+		"auto_publish":           "Automatically publish (crosspost) the message to followers of the channel",
+		"cron":                   "Fetch live status of `poll_usernames` and check for ended streams not covered by EventSub or seen through Discord presence (set to empty string to disable): keep this below `stream_freshness` or you might miss streams",
+		"disable_presence":       "Disable posting live-postings for discord presence changes",
+		"embed_author_icon":      "Template for the icon URL of the embed author",
+		"embed_author_name":      "Template for the name of the embed author (no author when empty)",
+		"embed_color":            "Integer / HEX representation of the color for the embed (`0x6441a5` or `\"#6441a5\"`)",
+		"embed_description":      "Template for the description of the embed",
+		"embed_fields":           "List of objects with `name` and `value` templates and optional `inline` flag to add as fields to the embed (fields rendering empty are skipped)",
+		"embed_footer":           "Template for the footer text of the embed",
+		"embed_image":            "Template for the image URL of the embed (no image when empty)",
+		"embed_image_height":     "Height of the embed image, also used as height of the stream preview",
+		"embed_image_width":      "Width of the embed image, also used as width of the stream preview",
+		"embed_thumbnail":        "Template for the thumbnail URL of the embed (no thumbnail when empty)",
+		"embed_thumbnail_height": "Height of the thumbnail",
+		"embed_thumbnail_width":  "Width of the thumbnail",
+		"embed_title":            "Template for the title of the embed",
+		"embed_url":              "Template for the URL the embed title links to",
+		"discord_channel_id":     "ID of the Discord channel to post the message to when no route matches",
+		"discord_thread_id":      "ID of a thread in `discord_channel_id` to post the message into instead (messages in threads cannot be published)",
+		"mention_role_id":        "ID of the role to ping with the message, no other mentions are allowed to ping",
+		"offline_action":         "What to do with the post when the stream ended: `edit` it into a summary with duration, final title and category and the VOD, `delete` it or `keep` it unchanged",
+		"offline_duration_text":  "Text in front of the duration in the summary of the ended stream",
+		"offline_game_label":     "Name of the field containing the final category in the summary of the ended stream",
+		"offline_text":           "Template for the message to replace the `post_text` with when editing the post after the stream ended (keeps the text when empty): gets the same data as `post_text` with the `.Stream` reduced to the final title and category (Twitch only), the `.Uptime` containing the duration of the stream and the `.VODURL` (Twitch only, empty without VOD)",
+		"offline_vod_label":      "Name of the field containing the VOD link in the summary of the ended stream",
+		"poll_usernames":         "Check these channels for active streams through EventSub (Twitch only) or when executing the `cron`: Twitch logins or `youtube:<channel ID>` / `kick:<slug>` when the top-level `youtube` / `kick` section is configured",
+		"post_text":              "Template for the message to post to the channel: all templates get the `.Stream`, the `.User` (both having a `.Platform` and a `.URL`), the `.PreviewURL` and the `.Uptime` passed and can use [sprig](https://masterminds.github.io/sprig/) functions, `${displayname}` and `${username}` are still replaced",
+		"post_text_{username}":   "Override the default `post_text` with this one (e.g. `post_text_luziferus: \"${displayName} is now live\"`)",
+		"quiet_hours":            "Time range (`HH:MM-HH:MM` in `timezone`, may span midnight) during which the role is not pinged",
+		"preserve_proxy":         "URL prefix of a Luzifer/preserve proxy to cache stream preview for longer",
+		"routes":                 "List of routes sending the posts for streams matching the `users` (Twitch logins, `youtube:<channel ID>` or `kick:<slug>`) and / or `games` (names or IDs) to another `discord_channel_id` or `discord_thread_id` with their own `mention_role_id` and `quiet_hours`: the first matching route is used, missing keys are taken from the module attributes",
+		"remove_old":             "If set to `true` older messages in the channel with the same content as the new post will be deleted",
+		"stream_freshness":       "How long after stream start to post shoutout",
+		"template_{username}":    "Object overriding the templates for this user (e.g. `template_luziferus: {post_text: \"...\", embed_color: 0xff0000}`): accepts the `post_text`, `embed_*` templates, `embed_color` and `embed_fields`, missing keys are taken from the module attributes",
+		"timezone":               "Timezone of the `quiet_hours` (e.g. `Europe/Berlin`)",
+		"twitch_client_id":       "Twitch client ID overriding the one of the top-level `twitch` section",
+		"twitch_client_secret":   "Secret for the Twitch app identified with twitch_client_id (required when overriding twitch_client_id)",
+		"twitch_user_token":      "User access token issued for the Twitch app: enables instant notifications for `poll_usernames` through EventSub WebSocket (not required when `twitch_eventsub` webhook is configured), `cron` is used as fallback",
+		"update_cron":            "When to refresh the posts of running streams with the current title, game, viewer count, uptime and preview (set to empty string to disable): title and game are also refreshed instantly for channels tracked through EventSub",
+		"whitelisted_role":       "Only post for members of this role ID",
+	},
+)
 
 func init() {
-	modules.RegisterModule("liveposting", func() modules.Module { return &modLivePosting{} })
+	modules.RegisterModule("liveposting", func() modules.Module { return &modLivePosting{} }, moduleSchema)
 }
 
 func (m *modLivePosting) ID() string { return m.id }
 
 func (m *modLivePosting) Initialize(args modules.ModuleInitArgs) error {
-	m.attrs = args.Attrs
 	m.discord = args.Discord
 	m.id = args.ID
 	m.config = args.Config
+	m.live = metrics.NewLiveTracker(args.ID)
+	m.presenceStreams = make(map[string]string)
+	m.store = args.Store
+
+	if err := args.Attrs.Decode(&m.cfg); err != nil {
+		return fmt.Errorf("decoding attributes: %w", err)
+	}
+
+	if !slices.Contains([]string{offlineActionDelete, offlineActionEdit, offlineActionKeep}, m.cfg.OfflineAction) {
+		return fmt.Errorf("invalid offline_action %q", m.cfg.OfflineAction)
+	}
+
+	if err := m.validateTemplates(); err != nil {
+		return fmt.Errorf("validating templates: %w", err)
+	}
+
+	if err := m.validateRoutes(); err != nil {
+		return fmt.Errorf("validating routes: %w", err)
+	}
+
+	var err error
+	if m.twitch, err = args.TwitchAdapter(m.cfg.TwitchClientID, m.cfg.TwitchClientSecret); err != nil {
+		return fmt.Errorf("getting twitch adapter: %w", err)
+	}
 
-	if err := args.Attrs.Expect(
-		"discord_channel_id",
-		"post_text",
-		"twitch_client_id",
-		"twitch_client_secret",
-	); err != nil {
-		return fmt.Errorf("validating attributes: %w", err)
+	m.providers = args.StreamingProviders(m.twitch)
+	if err = m.providers.Validate(m.cfg.PollUsernames...); err != nil {
+		return fmt.Errorf("validating poll_usernames: %w", err)
 	}
 
-	// @attr disable_presence optional bool "false" Disable posting live-postings for discord presence changes
-	if !m.attrs.MustBool("disable_presence", new(false)) {
-		m.discord.AddHandler(m.handlePresenceUpdate)
+	if !m.cfg.DisablePresence {
+		args.AddHandler(m.handlePresenceUpdate)
 	}
 
-	// @attr cron optional string "*/5 * * * *" Fetch live status of `poll_usernames` (set to empty string to disable): keep this below `stream_freshness` or you might miss streams
-	if cronDirective := args.Attrs.MustString("cron", new("*/5 * * * *")); cronDirective != "" {
-		if _, err := args.Crontab.AddFunc(cronDirective, m.cronFetchChannelStatus); err != nil {
+	if m.cfg.Cron != "" {
+		if err := args.AddCronFunc(m.cfg.Cron, m.cronFetchChannelStatus); err != nil {
 			return fmt.Errorf("adding cron function: %w", err)
 		}
 	}
 
+	if m.cfg.UpdateCron != "" {
+		if err := args.AddCronFunc(m.cfg.UpdateCron, m.cronUpdateLivePosts); err != nil {
+			return fmt.Errorf("adding update cron function: %w", err)
+		}
+	}
+
+	m.webhookActive = args.TwitchEventSubActive
+	args.AddTwitchEventSubHandler(m.twitchUsernames(), m.handleEventSubNotification)
+
+	if m.cfg.TwitchUserToken != "" && len(m.twitchUsernames()) > 0 {
+		m.eventSub = twitch.NewEventSubClient(
+			m.twitch.With(twitch.WithToken(m.cfg.TwitchUserToken)),
+			m.eventSubSubscriptions,
+			m.handleEventSubNotification,
+		)
+	}
+
 	return nil
 }
 
-func (*modLivePosting) Setup() error { return nil }
+func (m *modLivePosting) Setup() error {
+	if m.eventSub == nil {
+		return nil
+	}
 
-func (m *modLivePosting) cronFetchChannelStatus() {
-	// @attr poll_usernames optional []string "[]" Check these usernames for active streams when executing the `cron` (at most 100 users can be checked)
-	usernames, err := m.attrs.StringSlice("poll_usernames")
-	switch err {
-	case nil:
-		// We got a list of users
-	case attributestore.ErrValueNotSet:
-		// There is no list of users
-		return
-	default:
-		logrus.WithError(err).Error("Unable to get poll_usernames list")
-		return
+	if err := m.eventSub.Start(); err != nil {
+		return fmt.Errorf("starting EventSub client: %w", err)
 	}
 
-	logrus.WithField("entries", len(usernames)).Trace("Fetching streams for users (cron)")
+	return nil
+}
 
-	if err = m.fetchAndPostForUsername(usernames...); err != nil {
-		logrus.WithError(err).Error("Unable to post status for users")
+func (m *modLivePosting) Shutdown(ctx context.Context) error {
+	if m.eventSub == nil {
+		return nil
 	}
+
+	if err := m.eventSub.Stop(ctx); err != nil {
+		return fmt.Errorf("stopping EventSub client: %w", err)
+	}
+
+	return nil
 }
 
-func (m *modLivePosting) fetchAndPostForUsername(usernames ...string) error {
-	t := twitch.New(
-		// @attr twitch_client_id required string "" Twitch client ID the token was issued for
-		m.attrs.MustString("twitch_client_id", nil),
-		// @attr twitch_client_secret required string "" Secret for the Twitch app identified with twitch_client_id
-		m.attrs.MustString("twitch_client_secret", nil),
-		"", // No User-Token used
-	)
+func (m *modLivePosting) cronFetchChannelStatus() error {
+	if usernames := m.pollUsernames(); len(usernames) > 0 {
+		logrus.WithField("entries", len(usernames)).Trace("Fetching streams for users (cron)")
+
+		if err := m.fetchAndPostForChannels(usernames...); err != nil {
+			return fmt.Errorf("posting status for users: %w", err)
+		}
+	}
+
+	if err := m.refreshPresenceStreams(context.Background()); err != nil {
+		return fmt.Errorf("refreshing streams seen through presence: %w", err)
+	}
+
+	if err := m.finishEndedStreams(context.Background()); err != nil {
+		return fmt.Errorf("finishing posts of ended streams: %w", err)
+	}
 
-	users, err := t.GetUserByUsername(context.Background(), usernames...)
+	return nil
+}
+
+// eventSubSubscriptions resolves the IDs of the polled users and
+// returns the subscriptions to track their streams and channel
+// information
+func (m *modLivePosting) eventSubSubscriptions(ctx context.Context) ([]twitch.EventSubSubscription, error) {
+	users, err := m.twitch.GetUserByUsername(ctx, m.twitchUsernames()...)
 	if err != nil {
-		return fmt.Errorf("fetching twitch user details: %w", err)
+		return nil, fmt.Errorf("fetching twitch user details: %w", err)
 	}
 
-	streams, err := t.GetStreamsForUser(context.Background(), usernames...)
+	var (
+		subs    []twitch.EventSubSubscription
+		userIDs = make(map[string]string)
+	)
+
+	for _, user := range users.Data {
+		userIDs[strings.ToLower(user.Login)] = user.ID
+		subs = append(subs,
+			twitch.ChannelUpdateSubscription(user.ID),
+			twitch.StreamOfflineSubscription(user.ID),
+			twitch.StreamOnlineSubscription(user.ID),
+		)
+	}
+
+	m.userIDsLock.Lock()
+	defer m.userIDsLock.Unlock()
+
+	m.userIDs = userIDs
+
+	return subs, nil
+}
+
+// fetchAndPostForChannels looks up the referenced channels (see
+// streaming.ParseChannel) and posts their fresh streams
+func (m *modLivePosting) fetchAndPostForChannels(refs ...string) error {
+	users, streams, err := m.providers.Lookup(context.Background(), refs...)
 	if err != nil {
-		return fmt.Errorf("fetching streams for user: %w", err)
+		return fmt.Errorf("fetching channels: %w", err)
 	}
 
 	logrus.WithFields(logrus.Fields{
-		"streams": len(streams.Data),
-		"users":   len(users.Data),
+		"streams": len(streams),
+		"users":   len(users),
 	}).Trace("Found active streams from users")
 
-	// @attr stream_freshness optional duration "5m" How long after stream start to post shoutout
-	streamFreshness := m.attrs.MustDuration("stream_freshness", new(livePostingDefaultStreamFreshness))
+	for _, user := range users {
+		m.live.SetLive(user.Key(), slices.ContainsFunc(streams, func(s streaming.Stream) bool { return s.UserKey() == user.Key() }))
+	}
+
+	streamFreshness := m.cfg.StreamFreshness
 
-	for _, stream := range streams.Data {
-		for _, user := range users.Data {
-			if user.ID != stream.UserID {
+	for _, stream := range streams {
+		for _, user := range users {
+			if user.Key() != stream.UserKey() {
 				continue
 			}
 
@@ -151,14 +327,7 @@ func (m *modLivePosting) fetchAndPostForUsername(usernames ...string) error {
 				continue
 			}
 
-			if err = m.sendLivePost(
-				user.Login,
-				user.DisplayName,
-				stream.Title,
-				stream.GameName,
-				stream.ThumbnailURL,
-				user.ProfileImageURL,
-			); err != nil {
+			if err = m.sendLivePost(user, stream); err != nil {
 				return fmt.Errorf("sending post: %w", err)
 			}
 		}
@@ -167,6 +336,65 @@ func (m *modLivePosting) fetchAndPostForUsername(usernames ...string) error {
 	return nil
 }
 
+func (m *modLivePosting) handleEventSubNotification(ctx context.Context, n twitch.EventSubNotification) {
+	logger := logrus.WithFields(logrus.Fields{
+		"id":   m.id,
+		"type": n.Subscription.Type,
+	})
+
+	switch n.Subscription.Type {
+	case twitch.EventSubTypeChannelUpdate:
+		var evt twitch.ChannelUpdateEvent
+		if err := n.Decode(&evt); err != nil {
+			logger.WithError(err).Error("Unable to decode EventSub notification")
+			return
+		}
+
+		if err := m.updateChannelInformation(ctx, evt); err != nil {
+			logger.WithError(err).WithField("user", evt.BroadcasterUserLogin).Error("Unable to update live posting")
+			return
+		}
+
+	case twitch.EventSubTypeStreamOffline:
+		var evt twitch.StreamOfflineEvent
+		if err := n.Decode(&evt); err != nil {
+			logger.WithError(err).Error("Unable to decode EventSub notification")
+			return
+		}
+
+		m.live.SetLive(evt.BroadcasterUserID, false)
+
+		if err := m.finishStream(ctx, evt.BroadcasterUserID); err != nil {
+			logger.WithError(err).WithField("user", evt.BroadcasterUserLogin).Error("Unable to finish live posting")
+			return
+		}
+
+	case twitch.EventSubTypeStreamOnline:
+		var evt twitch.StreamOnlineEvent
+		if err := n.Decode(&evt); err != nil {
+			logger.WithError(err).Error("Unable to decode EventSub notification")
+			return
+		}
+
+		if evt.Type != "live" {
+			// Reruns and premieres are not announced
+			return
+		}
+
+		logger = logger.WithField("user", evt.BroadcasterUserLogin)
+
+		if err := m.waitForStream(ctx, evt.BroadcasterUserLogin); err != nil {
+			logger.WithError(err).Error("Unable to fetch stream for online notification")
+			return
+		}
+
+		if err := m.fetchAndPostForChannels(evt.BroadcasterUserLogin); err != nil {
+			logger.WithError(err).Error("Unable to fetch info / post live posting")
+			return
+		}
+	}
+}
+
 func (m *modLivePosting) handlePresenceUpdate(d *discordgo.Session, p *discordgo.PresenceUpdate) {
 	if p.User == nil {
 		// The frick? Non-user presence?
@@ -188,8 +416,7 @@ func (m *modLivePosting) handlePresenceUpdate(d *discordgo.Session, p *discordgo
 		return
 	}
 
-	// @attr whitelisted_role optional string "" Only post for members of this role ID
-	whitelistedRole := m.attrs.MustString("whitelisted_role", new(""))
+	whitelistedRole := m.cfg.WhitelistedRole
 	if whitelistedRole != "" && !slices.Contains(member.Roles, whitelistedRole) {
 		// User is not allowed for this config
 		return
@@ -205,7 +432,10 @@ func (m *modLivePosting) handlePresenceUpdate(d *discordgo.Session, p *discordgo
 	}
 
 	if activity == nil {
-		// No streaming activity: Do nothing
+		// No streaming activity: A stream seen before might have ended
+		if err = m.refreshPresenceStreams(context.Background(), p.User.ID); err != nil {
+			logger.WithError(err).Error("Unable to refresh live status of stream seen through presence")
+		}
 		return
 	}
 
@@ -215,132 +445,159 @@ func (m *modLivePosting) handlePresenceUpdate(d *discordgo.Session, p *discordgo
 		return
 	}
 
-	if u.Host != "www.twitch.tv" {
-		logger.WithError(err).WithField("url", activity.URL).Debug("Activity is not on Twitch")
+	platform, channel, ok, err := m.providers.ChannelFromURL(context.Background(), u)
+	if err != nil {
+		logger.WithError(err).WithField("url", activity.URL).Warning("Unable to resolve channel of activity URL")
+		return
+	}
+
+	if !ok {
+		logger.WithField("url", activity.URL).Debug("Activity is not on a configured streaming platform")
 		return
 	}
 
-	twitchUsername := strings.TrimLeft(u.Path, "/")
+	ref := streaming.Key(platform, channel)
+	m.trackPresenceStream(p.User.ID, ref)
 
-	if err = m.fetchAndPostForUsername(twitchUsername); err != nil {
+	if err = m.fetchAndPostForChannels(ref); err != nil {
 		logger.WithError(err).WithField("url", activity.URL).Error("Unable to fetch info / post live posting")
 		return
 	}
 }
 
-//nolint:funlen // Makes no sense to split just for 2 lines
-func (m *modLivePosting) sendLivePost(username, displayName, title, game, previewImage, profileImage string) error {
-	m.lock.Lock()
-	defer m.lock.Unlock()
-
-	logger := logrus.WithFields(logrus.Fields{
-		"user": username,
-		"game": game,
-	})
+// pollUsernames returns the configured channels which are not
+// tracked through active EventSub subscriptions of the WebSocket
+// session or the webhook
+func (m *modLivePosting) pollUsernames() []string {
+	m.userIDsLock.RLock()
+	defer m.userIDsLock.RUnlock()
+
+	var refs []string
+	for _, ref := range m.cfg.PollUsernames {
+		if platform, username := streaming.ParseChannel(ref); platform == streaming.PlatformTwitch {
+			if m.webhookActive(username) {
+				continue
+			}
 
-	// @attr post_text required string "" Message to post to channel use `${displayname}` and `${username}` as placeholders
-	postTemplateDefault := m.attrs.MustString("post_text", nil)
+			id, ok := m.userIDs[strings.ToLower(username)]
+			if ok && m.eventSub != nil && m.eventSub.IsActive(twitch.StreamOnlineSubscription(id)) && m.eventSub.IsActive(twitch.StreamOfflineSubscription(id)) {
+				continue
+			}
+		}
 
-	postText := strings.NewReplacer(
-		"${displayname}", displayName,
-		"${username}", username,
-	).Replace(
-		// @attr post_text_{username} optional string "" Override the default `post_text` with this one (e.g. `post_text_luziferus: "${displayName} is now live"`)
-		m.attrs.MustString(fmt.Sprintf("post_text_%s", strings.ToLower(username)), &postTemplateDefault),
-	)
+		refs = append(refs, ref)
+	}
 
-	// @attr discord_channel_id required string "" ID of the Discord channel to post the message to
-	channelID := m.attrs.MustString("discord_channel_id", nil)
+	return refs
+}
 
+// removeOldPosts deletes the previous messages in the channel having
+// the same content as the new post
+func (m *modLivePosting) removeOldPosts(channelID, content string) error {
 	msgs, err := m.discord.ChannelMessages(channelID, livePostingNumberOfMessagesToLoad, "", "", "")
 	if err != nil {
 		return fmt.Errorf("fetching previous messages: %w", err)
 	}
 
-	ignoreTime := m.attrs.MustDuration("stream_freshness", new(livePostingDefaultStreamFreshness))
 	for _, msg := range msgs {
-		if msg.Content != postText {
+		if msg.Content != content {
 			// Post is for another channel / is another message
 			continue
 		}
 
-		if time.Since(msg.Timestamp) < ignoreTime {
-			// Message is still fresh
-			logger.Debug("Not creating live-post, it's already there")
-			return nil
+		if err = m.discord.ChannelMessageDelete(channelID, msg.ID); err != nil {
+			return fmt.Errorf("deleting old message: %w", err)
 		}
+	}
 
-		// @attr remove_old optional bool "false" If set to `true` older message with same content will be deleted
-		if !m.attrs.MustBool("remove_old", new(false)) {
-			// We're not allowed to purge the old message
-			continue
-		}
+	return nil
+}
 
-		// Purge the old message
-		if err = m.discord.ChannelMessageDelete(channelID, msg.ID); err != nil {
-			return fmt.Errorf("deleting old message: %w", err)
+//nolint:funlen // Makes no sense to split just for 2 lines
+func (m *modLivePosting) sendLivePost(user streaming.User, stream streaming.Stream) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	logger := logrus.WithFields(logrus.Fields{
+		"user": user.Ref(),
+		"game": stream.GameName,
+	})
+
+	if err := m.purgeAnnounced(); err != nil {
+		return fmt.Errorf("purging announced streams: %w", err)
+	}
+
+	announced, err := m.isAnnounced(stream.Key())
+	if err != nil {
+		return fmt.Errorf("checking stream was announced: %w", err)
+	}
+
+	prev, err := m.getLivePost(user.Key())
+	if err != nil {
+		return fmt.Errorf("getting previous live-post: %w", err)
+	}
+
+	switch {
+	case announced, prev != nil && prev.StreamID == stream.ID:
+		logger.Debug("Not creating live-post, stream was already announced")
+		return nil
+
+	case prev != nil:
+		// The end of the previous stream was missed
+		if err = m.finishLivePost(context.Background(), *prev); err != nil {
+			return fmt.Errorf("finishing previous live-post: %w", err)
 		}
 	}
 
-	// Discord caches the images and the URLs do not change every time
-	// so we force Discord to load a new image every time
-	previewImageURL, err := url.Parse(
-		strings.NewReplacer(
-			"{width}", strconv.Itoa(livePostingPreviewWidth),
-			"{height}", strconv.Itoa(livePostingPreviewHeight),
-		).Replace(previewImage),
-	)
+	postText, msgEmbed, err := m.assembleLivePost(user, stream)
 	if err != nil {
-		return fmt.Errorf("parsing stream preview URL: %w", err)
-	}
-
-	previewImageQuery := previewImageURL.Query()
-	previewImageQuery.Add("_discordNoCache", time.Now().Format(time.RFC3339))
-	previewImageURL.RawQuery = previewImageQuery.Encode()
-
-	// @attr preserve_proxy optional string "" URL prefix of a Luzifer/preserve proxy to cache stream preview for longer
-	if proxy, err := url.Parse(m.attrs.MustString("preserve_proxy", new(""))); err == nil && proxy.String() != "" {
-		// Discord screws up the plain-text URL format, so we need to use the b64-format
-		proxy.Path = "/b64:" + base64.URLEncoding.EncodeToString([]byte(previewImageURL.String()))
-		previewImageURL = proxy
-	}
-
-	msgEmbed := &discordgo.MessageEmbed{
-		Author: &discordgo.MessageEmbedAuthor{
-			Name:    displayName,
-			IconURL: profileImage,
-		},
-		Color: livePostingTwitchColor,
-		Fields: []*discordgo.MessageEmbedField{
-			{Name: "Game", Value: game},
-		},
-		Image: &discordgo.MessageEmbedImage{
-			URL:    previewImageURL.String(),
-			Width:  livePostingPreviewWidth,
-			Height: livePostingPreviewHeight,
-		},
-		Thumbnail: &discordgo.MessageEmbedThumbnail{
-			URL:    profileImage,
-			Width:  livePostingDiscordProfileWidth,
-			Height: livePostingDiscordProfileHeight,
-		},
-		Title: title,
-		Type:  discordgo.EmbedTypeRich,
-		URL:   strings.Join([]string{"https://www.twitch.tv", username}, "/"),
+		return fmt.Errorf("assembling post: %w", err)
+	}
+
+	target, err := m.postTarget(user, stream, time.Now())
+	if err != nil {
+		return fmt.Errorf("resolving post target: %w", err)
+	}
+
+	postText = mentionContent(target.MentionRoleID, postText)
+	channelID := target.ChannelID
+
+	if m.cfg.RemoveOld {
+		if err = m.removeOldPosts(channelID, postText); err != nil {
+			return fmt.Errorf("removing old posts: %w", err)
+		}
 	}
 
 	logger.Debug("Creating live-post")
 
 	msg, err := m.discord.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-		Content: postText,
-		Embed:   msgEmbed,
+		Content:         postText,
+		Embed:           msgEmbed,
+		AllowedMentions: target.allowedMentions(),
 	})
 	if err != nil {
 		return fmt.Errorf("sending message: %w", err)
 	}
 
-	// @attr auto_publish optional bool "false" Automatically publish (crosspost) the message to followers of the channel
-	if m.attrs.MustBool("auto_publish", new(false)) {
+	if err = m.markAnnounced(stream.Key()); err != nil {
+		return err
+	}
+
+	if err = m.storeLivePost(livePost{
+		ChannelID:     channelID,
+		MessageID:     msg.ID,
+		StreamID:      stream.ID,
+		StartedAt:     stream.StartedAt,
+		UserID:        user.ID,
+		UserLogin:     user.Login,
+		DisplayName:   user.DisplayName,
+		MentionRoleID: target.MentionRoleID,
+		Platform:      user.Platform,
+	}); err != nil {
+		return err
+	}
+
+	if m.cfg.AutoPublish && !target.Thread {
 		logger.Debug("Auto-Publishing live-post")
 		if _, err = m.discord.ChannelMessageCrosspost(channelID, msg.ID); err != nil {
 			return fmt.Errorf("publishing message: %w", err)
@@ -349,3 +606,41 @@ func (m *modLivePosting) sendLivePost(username, displayName, title, game, previe
 
 	return nil
 }
+
+// twitchUsernames returns the Twitch logins among the configured
+// channels
+func (m *modLivePosting) twitchUsernames() []string {
+	var usernames []string
+	for _, ref := range m.cfg.PollUsernames {
+		if platform, username := streaming.ParseChannel(ref); platform == streaming.PlatformTwitch {
+			usernames = append(usernames, username)
+		}
+	}
+
+	return usernames
+}
+
+// waitForStream waits for the stream of the user to be listed by the
+// streams API which lags behind the stream.online notification
+func (m *modLivePosting) waitForStream(ctx context.Context, username string) error {
+	for attempt := 1; ; attempt++ {
+		streams, err := m.twitch.GetStreamsForUser(ctx, username)
+		if err != nil {
+			return fmt.Errorf("fetching streams for user: %w", err)
+		}
+
+		if len(streams.Data) > 0 {
+			return nil
+		}
+
+		if attempt == livePostingStreamLookupAttempts {
+			return errors.New("stream not listed after online notification")
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for stream: %w", ctx.Err())
+		case <-time.After(livePostingStreamLookupDelay):
+		}
+	}
+}
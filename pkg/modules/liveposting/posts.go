@@ -0,0 +1,543 @@
+package liveposting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/helpers"
+	"github.com/Luzifer/discord-community/pkg/streaming"
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+const (
+	livePostingOfflineColor    = 0x99aab5
+	livePostingStoreKeyPrefix  = "live_post_"
+	livePostingVODLookupWindow = 10
+	livePostingVODThumbHeight  = 180
+	livePostingVODThumbWidth   = 320
+
+	offlineActionDelete = "delete"
+	offlineActionEdit   = "edit"
+	offlineActionKeep   = "keep"
+)
+
+type (
+	// livePost references the message posted for a stream so it can
+	// be updated while the stream is live and edited or removed after
+	// the stream ended
+	livePost struct {
+		ChannelID     string    `json:"channel_id"`
+		MessageID     string    `json:"message_id"`
+		StreamID      string    `json:"stream_id"`
+		StartedAt     time.Time `json:"started_at"`
+		UserID        string    `json:"user_id"`
+		UserLogin     string    `json:"user_login"`
+		DisplayName   string    `json:"display_name"`
+		MentionRoleID string    `json:"mention_role_id,omitempty"`
+		Platform      string    `json:"platform,omitempty"`
+	}
+)
+
+// errVODNotFound signals Twitch did not create a VOD for the stream
+var errVODNotFound = errors.New("no VOD found for stream")
+
+// cronUpdateLivePosts refreshes the posts of the streams still live
+// with the current state of the stream
+func (m *modLivePosting) cronUpdateLivePosts() error {
+	posts, err := m.livePosts()
+	if err != nil || len(posts) == 0 {
+		return err
+	}
+
+	refs := make([]string, 0, len(posts))
+	for _, post := range posts {
+		refs = append(refs, post.ref())
+	}
+
+	users, streams, err := m.providers.Lookup(context.Background(), refs...)
+	if err != nil {
+		return fmt.Errorf("fetching channels: %w", err)
+	}
+
+	for _, stream := range streams {
+		for _, user := range users {
+			if user.Key() != stream.UserKey() {
+				continue
+			}
+
+			if err = m.updateLivePost(user, stream); err != nil {
+				return fmt.Errorf("updating post for %s: %w", user.Ref(), err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteLivePost removes the reference to the post for the stream of
+// the user identified by its key (see streaming.Key)
+func (m *modLivePosting) deleteLivePost(userKey string) error {
+	if err := m.store.Delete(m.id, livePostingStoreKeyPrefix+userKey); err != nil {
+		return fmt.Errorf("deleting live-post: %w", err)
+	}
+
+	return nil
+}
+
+// editIntoSummary replaces the live-post by a summary of the ended
+// stream with its duration and for Twitch streams the final title and
+// category and the VOD
+func (m *modLivePosting) editIntoSummary(ctx context.Context, post livePost) error {
+	msg, err := m.discord.ChannelMessage(post.ChannelID, post.MessageID)
+	if err != nil {
+		return fmt.Errorf("fetching live-post: %w", err)
+	}
+
+	var embed discordgo.MessageEmbed
+	if len(msg.Embeds) > 0 {
+		embed = *msg.Embeds[0]
+	}
+
+	var (
+		channel *twitch.ChannelInformation
+		vod     *twitch.Video
+	)
+
+	if post.isTwitch() {
+		// Other platforms do not expose ended streams
+		channels, err := m.twitch.GetChannelInformation(ctx, post.UserID)
+		if err != nil {
+			return fmt.Errorf("fetching channel information: %w", err)
+		}
+
+		if len(channels.Data) > 0 {
+			channel = &channels.Data[0]
+		}
+
+		if vod, err = m.findVOD(ctx, post); err != nil && !errors.Is(err, errVODNotFound) {
+			// Summary without VOD is still better than an outdated post
+			logrus.WithError(err).WithField("user", post.UserLogin).Warn("Unable to fetch VOD for ended stream")
+		}
+	}
+
+	duration := time.Since(post.StartedAt)
+	if vod != nil {
+		if d, err := time.ParseDuration(vod.Duration); err == nil {
+			duration = d
+		}
+	}
+
+	embed.Color = livePostingOfflineColor
+	embed.Description = m.cfg.OfflineDurationText + " " + formatDuration(duration)
+	embed.Fields = nil
+	embed.Image = nil
+
+	if channel != nil {
+		embed.Title = channel.Title
+		embed.Fields = []*discordgo.MessageEmbedField{
+			{Name: m.cfg.OfflineGameLabel, Value: channel.GameName},
+		}
+	}
+
+	if vod != nil {
+		embed.URL = vod.URL
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: m.cfg.OfflineVODLabel, Value: vod.URL})
+
+		if vod.ThumbnailURL != "" {
+			embed.Image = &discordgo.MessageEmbedImage{
+				URL: strings.NewReplacer(
+					"%{width}", strconv.Itoa(livePostingVODThumbWidth),
+					"%{height}", strconv.Itoa(livePostingVODThumbHeight),
+				).Replace(vod.ThumbnailURL),
+				Width:  livePostingVODThumbWidth,
+				Height: livePostingVODThumbHeight,
+			}
+		}
+	}
+
+	content := msg.Content
+	if m.cfg.OfflineText != "" {
+		if content, err = m.renderOfflineText(ctx, post, channel, vod, duration); err != nil {
+			return fmt.Errorf("rendering offline_text: %w", err)
+		}
+	}
+
+	if _, err = m.discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Content: &content,
+		Embeds:  &[]*discordgo.MessageEmbed{&embed},
+
+		ID:      post.MessageID,
+		Channel: post.ChannelID,
+	}); err != nil {
+		return fmt.Errorf("editing live-post: %w", err)
+	}
+
+	return nil
+}
+
+// findVOD returns the archive created from the stream or
+// errVODNotFound when Twitch did not create one (e.g. VODs are
+// disabled)
+func (m *modLivePosting) findVOD(ctx context.Context, post livePost) (*twitch.Video, error) {
+	var checked int
+	for video, err := range m.twitch.Videos(ctx, post.UserID, twitch.VideoTypeArchive) {
+		if err != nil {
+			return nil, fmt.Errorf("fetching videos: %w", err)
+		}
+
+		if video.StreamID == post.StreamID {
+			return &video, nil
+		}
+
+		if checked++; checked == livePostingVODLookupWindow {
+			// Archives are listed newest first, the VOD of the stream
+			// is one of the latest ones if it exists
+			break
+		}
+	}
+
+	return nil, errVODNotFound
+}
+
+// finishEndedStreams checks the users having a live-post whose end is
+// not reported through EventSub and finishes the posts of the streams
+// no longer live
+func (m *modLivePosting) finishEndedStreams(ctx context.Context) error {
+	posts, err := m.livePosts()
+	if err != nil {
+		return err
+	}
+
+	var (
+		pending []livePost
+		refs    []string
+	)
+
+	for _, post := range posts {
+		if post.isTwitch() && (m.webhookActive(post.UserLogin) || (m.eventSub != nil && m.eventSub.IsActive(twitch.StreamOfflineSubscription(post.UserID)))) {
+			// Offline notification will finish the post
+			continue
+		}
+
+		pending = append(pending, post)
+		refs = append(refs, post.ref())
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	streams, err := m.providers.Streams(ctx, refs...)
+	if err != nil {
+		return fmt.Errorf("fetching streams: %w", err)
+	}
+
+	live := make(map[string]string, len(streams))
+	for _, stream := range streams {
+		live[stream.UserKey()] = stream.ID
+	}
+
+	for _, post := range pending {
+		if live[post.key()] == post.StreamID {
+			continue
+		}
+
+		if err = m.finishStream(ctx, post.key()); err != nil {
+			return fmt.Errorf("finishing post for %s: %w", post.UserLogin, err)
+		}
+	}
+
+	return nil
+}
+
+// finishLivePost applies the offline_action to the post of the ended
+// stream and removes its reference. Posts deleted in Discord are
+// dropped silently. Must be called while holding the lock.
+func (m *modLivePosting) finishLivePost(ctx context.Context, post livePost) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"stream": post.StreamID,
+		"user":   post.UserLogin,
+	})
+
+	var err error
+	switch m.cfg.OfflineAction {
+	case offlineActionDelete:
+		logger.Debug("Deleting live-post of ended stream")
+		err = m.discord.ChannelMessageDelete(post.ChannelID, post.MessageID)
+
+	case offlineActionEdit:
+		logger.Debug("Editing live-post of ended stream into summary")
+		err = m.editIntoSummary(ctx, post)
+	}
+
+	if err != nil && !isUnknownMessage(err) {
+		return err
+	}
+
+	return m.deleteLivePost(post.key())
+}
+
+// finishStream finishes the live-post for the stream of the user
+// identified by its key (see streaming.Key) if there is one
+func (m *modLivePosting) finishStream(ctx context.Context, userKey string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	post, err := m.getLivePost(userKey)
+	if err != nil || post == nil {
+		return err
+	}
+
+	return m.finishLivePost(ctx, *post)
+}
+
+// getLivePost returns the reference to the post for the stream of the
+// user identified by its key (see streaming.Key) or nil if there is
+// none
+func (m *modLivePosting) getLivePost(userKey string) (*livePost, error) {
+	var post *livePost
+
+	if err := m.store.ReadWithLock(m.id, func(a attributestore.ModuleAttributeStore) error {
+		raw, err := a.String(livePostingStoreKeyPrefix + userKey)
+		if errors.Is(err, attributestore.ErrValueNotSet) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("getting live-post: %w", err)
+		}
+
+		post = &livePost{}
+		if err = json.Unmarshal([]byte(raw), post); err != nil {
+			return fmt.Errorf("decoding live-post: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("reading live-post: %w", err)
+	}
+
+	return post, nil
+}
+
+// livePosts returns the references to all posts of streams not yet
+// finished
+func (m *modLivePosting) livePosts() ([]livePost, error) {
+	var posts []livePost
+
+	if err := m.store.ReadWithLock(m.id, func(a attributestore.ModuleAttributeStore) error {
+		for key := range a {
+			if !strings.HasPrefix(key, livePostingStoreKeyPrefix) {
+				continue
+			}
+
+			raw, err := a.String(key)
+			if err != nil {
+				return fmt.Errorf("getting live-post: %w", err)
+			}
+
+			var post livePost
+			if err = json.Unmarshal([]byte(raw), &post); err != nil {
+				return fmt.Errorf("decoding live-post: %w", err)
+			}
+
+			posts = append(posts, post)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("reading live-posts: %w", err)
+	}
+
+	return posts, nil
+}
+
+// renderOfflineText renders the offline_text with the details of the
+// ended stream known from the post, the channel information and the VOD
+func (m *modLivePosting) renderOfflineText(ctx context.Context, post livePost, channel *twitch.ChannelInformation, vod *twitch.Video, duration time.Duration) (string, error) {
+	users, err := m.providers.Users(ctx, post.ref())
+	if err != nil {
+		return "", fmt.Errorf("fetching channel: %w", err)
+	}
+
+	user := streaming.User{
+		ID:          post.UserID,
+		Platform:    post.Platform,
+		Login:       post.UserLogin,
+		DisplayName: post.DisplayName,
+	}
+	for _, u := range users {
+		if u.Key() == post.key() {
+			user = u
+		}
+	}
+
+	data := postTemplateData{
+		Stream: streaming.Stream{
+			ID:        post.StreamID,
+			Platform:  user.Platform,
+			UserID:    user.ID,
+			UserLogin: user.Login,
+			UserName:  user.DisplayName,
+			StartedAt: post.StartedAt,
+			URL:       user.URL,
+		},
+		Uptime: formatDuration(duration),
+		User:   user,
+	}
+
+	if channel != nil {
+		data.Stream.GameID, data.Stream.GameName, data.Stream.Title = channel.GameID, channel.GameName, channel.Title
+	}
+
+	if vod != nil {
+		data.VODURL = vod.URL
+	}
+
+	r := &postRenderer{data: data}
+	content := strings.NewReplacer(
+		"${displayname}", user.DisplayName,
+		"${username}", user.Login,
+	).Replace(r.render("offline_text", m.cfg.OfflineText))
+
+	return content, r.err
+}
+
+// storeLivePost stores the reference to the post for the stream
+// replacing the one of the previous stream of the user
+func (m *modLivePosting) storeLivePost(post livePost) error {
+	// Stored encoded as the backends do not preserve the type
+	raw, err := json.Marshal(post)
+	if err != nil {
+		return fmt.Errorf("encoding live-post: %w", err)
+	}
+
+	if err = m.store.Set(m.id, livePostingStoreKeyPrefix+post.key(), string(raw)); err != nil {
+		return fmt.Errorf("storing live-post: %w", err)
+	}
+
+	return nil
+}
+
+// updateChannelInformation refreshes the post of the running stream
+// of the broadcaster with the title and category of the channel.update
+// notification as the streams API lags behind the notification
+func (m *modLivePosting) updateChannelInformation(ctx context.Context, evt twitch.ChannelUpdateEvent) error {
+	post, err := m.getLivePost(evt.BroadcasterUserID)
+	if err != nil || post == nil {
+		return err
+	}
+
+	users, streams, err := m.providers.Lookup(ctx, post.ref())
+	if err != nil {
+		return fmt.Errorf("fetching channel: %w", err)
+	}
+
+	for _, stream := range streams {
+		for _, user := range users {
+			if user.Key() != stream.UserKey() || user.Key() != post.key() {
+				continue
+			}
+
+			stream.GameID, stream.GameName, stream.Title = evt.CategoryID, evt.CategoryName, evt.Title
+
+			return m.updateLivePost(user, stream)
+		}
+	}
+
+	return nil
+}
+
+// updateLivePost edits the post for the stream if the stream changed
+// since the last update. Ended streams are left to the offline
+// handling.
+func (m *modLivePosting) updateLivePost(user streaming.User, stream streaming.Stream) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	post, err := m.getLivePost(stream.UserKey())
+	if err != nil || post == nil || post.StreamID != stream.ID {
+		return err
+	}
+
+	msg, err := m.discord.ChannelMessage(post.ChannelID, post.MessageID)
+	if err != nil {
+		if isUnknownMessage(err) {
+			// Post was removed, nothing to update anymore
+			return m.deleteLivePost(post.key())
+		}
+		return fmt.Errorf("fetching live-post: %w", err)
+	}
+
+	content, embed, err := m.assembleLivePost(user, stream)
+	if err != nil {
+		return fmt.Errorf("assembling post: %w", err)
+	}
+	content = mentionContent(post.MentionRoleID, content)
+
+	if len(msg.Embeds) > 0 && msg.Content == content {
+		// The preview URL changes on every assembly and Discord
+		// reports the real image sizes, so the images are taken from
+		// the post for the comparison
+		cmp := *embed
+		cmp.Image, cmp.Thumbnail = msg.Embeds[0].Image, msg.Embeds[0].Thumbnail
+
+		if helpers.IsDiscordMessageEmbedEqual(msg.Embeds[0], &cmp) {
+			return nil
+		}
+	}
+
+	logrus.WithField("user", post.UserLogin).Debug("Updating live-post")
+
+	if _, err = m.discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		AllowedMentions: postTarget{MentionRoleID: post.MentionRoleID}.allowedMentions(),
+		Content:         &content,
+		Embeds:          &[]*discordgo.MessageEmbed{embed},
+
+		ID:      post.MessageID,
+		Channel: post.ChannelID,
+	}); err != nil {
+		return fmt.Errorf("editing live-post: %w", err)
+	}
+
+	return nil
+}
+
+// isTwitch reports whether the post belongs to a Twitch stream, posts
+// created before other platforms were supported have no platform
+func (p livePost) isTwitch() bool {
+	return p.Platform == "" || p.Platform == streaming.PlatformTwitch
+}
+
+// key returns the key of the user (see streaming.Key) the post is
+// stored with
+func (p livePost) key() string { return streaming.Key(p.Platform, p.UserID) }
+
+// ref returns the channel reference of the user (see
+// streaming.ParseChannel)
+func (p livePost) ref() string { return streaming.Key(p.Platform, p.UserLogin) }
+
+// formatDuration formats the duration as hours and minutes
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60) //nolint:mnd // Minutes per hour
+}
+
+// isUnknownMessage reports whether Discord rejected the request as
+// the message does not exist (anymore)
+func isUnknownMessage(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMessage
+}
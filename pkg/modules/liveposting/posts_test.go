@@ -0,0 +1,205 @@
+package liveposting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/streaming"
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (r roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return r(req) }
+
+func TestRenderOfflineText(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus", DisplayName: "Luziferus"})
+
+	m := &modLivePosting{
+		cfg: moduleConfig{
+			OfflineText: `{{ .User.DisplayName }} played {{ .Stream.GameName }} for {{ .Uptime }}{{ with .VODURL }}: {{ . }}{{ end }} (${username})`,
+		},
+		providers: streaming.Providers{streaming.PlatformTwitch: streaming.NewTwitchProvider(srv.Adapter())},
+	}
+
+	post := livePost{StreamID: "10", UserID: "1", UserLogin: "luziferus", DisplayName: "Luziferus"}
+	channel := &twitch.ChannelInformation{GameName: "Just Chatting", Title: "Chatting"}
+
+	for name, tc := range map[string]struct {
+		vod      *twitch.Video
+		expected string
+	}{
+		"with VOD":    {vod: &twitch.Video{URL: "https://www.twitch.tv/videos/1"}, expected: "Luziferus played Just Chatting for 1h 30m: https://www.twitch.tv/videos/1 (luziferus)"},
+		"without VOD": {expected: "Luziferus played Just Chatting for 1h 30m (luziferus)"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			content, err := m.renderOfflineText(context.Background(), post, channel, tc.vod, 90*time.Minute)
+			if err != nil {
+				t.Fatalf("rendering: %s", err)
+			}
+
+			if content != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, content)
+			}
+		})
+	}
+}
+
+func TestUpdateLivePosts(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus", DisplayName: "Luziferus"})
+
+	stream := twitch.Stream{
+		ID:           "10",
+		UserID:       "1",
+		UserLogin:    "luziferus",
+		UserName:     "Luziferus",
+		Title:        "Coding",
+		GameName:     "Software and Game Development",
+		StartedAt:    time.Now().Add(-30 * time.Minute),
+		ThumbnailURL: "https://static-cdn.jtvnw.net/previews-ttv/live_user_luziferus-{width}x{height}.jpg",
+	}
+	srv.SetStreams(stream)
+
+	var (
+		msg   = &discordgo.Message{ID: "msg", ChannelID: "chan"}
+		edits []discordgo.MessageEdit
+	)
+
+	m := newTestModule(t, moduleAttrs(nil), func(req *http.Request) (*http.Response, error) {
+		if !strings.HasSuffix(req.URL.Path, "/channels/chan/messages/msg") {
+			t.Errorf("unexpected request %s %s", req.Method, req.URL)
+			return nil, errors.New("unexpected request")
+		}
+
+		if req.Method == http.MethodPatch {
+			var edit discordgo.MessageEdit
+			if err := json.NewDecoder(req.Body).Decode(&edit); err != nil {
+				return nil, fmt.Errorf("decoding edit: %w", err)
+			}
+			edits = append(edits, edit)
+
+			// Discord reports the real size of the images
+			msg.Content, msg.Embeds = *edit.Content, *edit.Embeds
+			for _, e := range msg.Embeds {
+				e.Image.Width, e.Image.Height = 1920, 1080
+			}
+		}
+
+		return jsonResponse(t, msg), nil
+	})
+	m.providers = streaming.Providers{streaming.PlatformTwitch: streaming.NewTwitchProvider(srv.Adapter())}
+
+	if err := m.storeLivePost(livePost{ChannelID: "chan", MessageID: "msg", StreamID: "10", UserID: "1", UserLogin: "luziferus"}); err != nil {
+		t.Fatalf("storing post: %s", err)
+	}
+
+	update := func() {
+		t.Helper()
+
+		if err := m.cronUpdateLivePosts(); err != nil {
+			t.Fatalf("updating posts: %s", err)
+		}
+	}
+
+	// The post does not yet match the stream
+	update()
+	if len(edits) != 1 {
+		t.Fatalf("expected post to be edited, got %d edits", len(edits))
+	}
+
+	// Only the cache-busted preview URL differs
+	time.Sleep(time.Second)
+	update()
+	if len(edits) != 1 {
+		t.Errorf("expected unchanged stream not to edit the post, got %d edits", len(edits))
+	}
+
+	stream.Title = "Still coding"
+	srv.SetStreams(stream)
+
+	update()
+	if len(edits) != 2 {
+		t.Fatalf("expected changed title to edit the post, got %d edits", len(edits))
+	}
+	if title := (*edits[1].Embeds)[0].Title; title != "Still coding" {
+		t.Errorf("expected new title in the post, got %q", title)
+	}
+
+	// Posts of previous streams are left to the offline handling
+	stream.ID, stream.Title = "11", "Next stream"
+	srv.SetStreams(stream)
+
+	update()
+	if len(edits) != 2 {
+		t.Errorf("expected post of previous stream not to be edited, got %d edits", len(edits))
+	}
+}
+
+// jsonResponse creates a successful response with the encoded body
+func jsonResponse(t *testing.T, body any) *http.Response {
+	t.Helper()
+
+	raw, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("encoding response: %s", err)
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(raw)),
+	}
+}
+
+// moduleAttrs returns the minimal module attributes with the given
+// attributes applied on top
+func moduleAttrs(attrs attributestore.ModuleAttributeStore) attributestore.ModuleAttributeStore {
+	out := attributestore.ModuleAttributeStore{
+		"discord_channel_id": "chan",
+		"post_text":          "${displayname} is live",
+	}
+	maps.Copy(out, attrs)
+
+	return out
+}
+
+// newTestModule creates a module with the configuration decoded from
+// the attributes, a store in a temporary directory and a Discord
+// session passing all requests to the given function
+func newTestModule(t *testing.T, attrs attributestore.ModuleAttributeStore, discord roundTripperFunc) *modLivePosting {
+	t.Helper()
+
+	s, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatalf("creating session: %s", err)
+	}
+	s.MaxRestRetries = 0
+	s.Client = &http.Client{Transport: discord}
+
+	m := &modLivePosting{discord: s, id: "test", store: openTestStore(t, filepath.Join(t.TempDir(), "store.yaml"))}
+	if err = attrs.Decode(&m.cfg); err != nil {
+		t.Fatalf("decoding config: %s", err)
+	}
+
+	return m
+}
@@ -0,0 +1,69 @@
+package liveposting
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Luzifer/discord-community/pkg/streaming"
+)
+
+// refreshPresenceStreams updates the live state of the channels seen
+// streaming through the Discord presence of the given users (all when
+// none are given) and stops tracking the ones no longer live: the
+// channels might not be covered by the poll_usernames and Discord
+// does not reliably report the end of a stream
+func (m *modLivePosting) refreshPresenceStreams(ctx context.Context, discordUserIDs ...string) error {
+	m.presenceLock.Lock()
+	tracked := make(map[string]string)
+	for discordUserID, ref := range m.presenceStreams {
+		if len(discordUserIDs) == 0 || slices.Contains(discordUserIDs, discordUserID) {
+			tracked[discordUserID] = ref
+		}
+	}
+	m.presenceLock.Unlock()
+
+	if len(tracked) == 0 {
+		return nil
+	}
+
+	var refs []string
+	for _, ref := range tracked {
+		if !slices.Contains(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+
+	users, streams, err := m.providers.Lookup(ctx, refs...)
+	if err != nil {
+		return fmt.Errorf("fetching channels: %w", err)
+	}
+
+	live := make(map[string]bool)
+	for _, user := range users {
+		isLive := slices.ContainsFunc(streams, func(s streaming.Stream) bool { return s.UserKey() == user.Key() })
+		m.live.SetLive(user.Key(), isLive)
+		live[strings.ToLower(user.Ref())] = isLive
+	}
+
+	m.presenceLock.Lock()
+	defer m.presenceLock.Unlock()
+
+	for discordUserID, ref := range tracked {
+		if !live[strings.ToLower(ref)] && m.presenceStreams[discordUserID] == ref {
+			delete(m.presenceStreams, discordUserID)
+		}
+	}
+
+	return nil
+}
+
+// trackPresenceStream remembers the channel the Discord user is
+// streaming on until refreshPresenceStreams sees the stream ended
+func (m *modLivePosting) trackPresenceStream(discordUserID, ref string) {
+	m.presenceLock.Lock()
+	defer m.presenceLock.Unlock()
+
+	m.presenceStreams[discordUserID] = ref
+}
@@ -0,0 +1,67 @@
+package liveposting
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/Luzifer/discord-community/pkg/metrics"
+	"github.com/Luzifer/discord-community/pkg/streaming"
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+func TestRefreshPresenceStreams(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(
+		twitch.User{ID: "1", Login: "luziferus"},
+		twitch.User{ID: "2", Login: "other"},
+	)
+	srv.SetStreams(
+		twitch.Stream{ID: "10", UserID: "1", UserLogin: "luziferus"},
+		twitch.Stream{ID: "20", UserID: "2", UserLogin: "other"},
+	)
+
+	m := &modLivePosting{
+		live:            metrics.NewLiveTracker("test-presence"),
+		presenceStreams: make(map[string]string),
+		providers:       streaming.Providers{streaming.PlatformTwitch: streaming.NewTwitchProvider(srv.Adapter())},
+	}
+
+	m.trackPresenceStream("discord-1", "luziferus")
+	m.trackPresenceStream("discord-2", "other")
+
+	liveStreamers := func() int {
+		return int(testutil.ToFloat64(metrics.LiveStreamers.WithLabelValues("test-presence")))
+	}
+
+	if err := m.refreshPresenceStreams(context.Background()); err != nil {
+		t.Fatalf("refreshing: %s", err)
+	}
+	if n := liveStreamers(); n != 2 {
+		t.Errorf("expected 2 live streamers, got %d", n)
+	}
+
+	// Only the given Discord user is checked
+	srv.SetStreams()
+	if err := m.refreshPresenceStreams(context.Background(), "discord-1"); err != nil {
+		t.Fatalf("refreshing: %s", err)
+	}
+	if n := liveStreamers(); n != 1 {
+		t.Errorf("expected 1 live streamer, got %d", n)
+	}
+	if _, ok := m.presenceStreams["discord-1"]; ok {
+		t.Error("expected ended stream to no longer be tracked")
+	}
+
+	// The cron checks all remaining streams
+	if err := m.refreshPresenceStreams(context.Background()); err != nil {
+		t.Fatalf("refreshing: %s", err)
+	}
+	if n := liveStreamers(); n != 0 || len(m.presenceStreams) != 0 {
+		t.Errorf("expected no live streamers, got %d (tracking %v)", n, m.presenceStreams)
+	}
+}
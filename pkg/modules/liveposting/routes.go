@@ -0,0 +1,193 @@
+package liveposting
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Luzifer/discord-community/pkg/streaming"
+)
+
+const quietHoursClockFormat = "15:04"
+
+type (
+	// liveRoute directs the live-posts of the matching streams to
+	// another channel or thread and configures the role to ping. Empty
+	// values are taken from the module attributes.
+	liveRoute struct {
+		ChannelID     string   `attr:"discord_channel_id"`
+		Games         []string `attr:"games"`
+		MentionRoleID string   `attr:"mention_role_id"`
+		QuietHours    string   `attr:"quiet_hours"`
+		ThreadID      string   `attr:"discord_thread_id"`
+		Users         []string `attr:"users"`
+	}
+
+	// postTarget is the resolved route of a live-post
+	postTarget struct {
+		// ChannelID is the channel or thread to post into
+		ChannelID string
+		// MentionRoleID is the role to ping, empty when no role is
+		// configured or during quiet hours
+		MentionRoleID string
+		// Thread signals the post goes into a thread which cannot be
+		// published
+		Thread bool
+	}
+)
+
+var errQuietHoursFormat = errors.New("expected format HH:MM-HH:MM")
+
+// postTarget resolves the channel to post the stream of the user into
+// and the role to ping at the given time using the first matching
+// route
+func (m *modLivePosting) postTarget(user streaming.User, stream streaming.Stream, now time.Time) (postTarget, error) {
+	route := liveRoute{
+		ChannelID:     m.cfg.ChannelID,
+		MentionRoleID: m.cfg.MentionRoleID,
+		QuietHours:    m.cfg.QuietHours,
+		ThreadID:      m.cfg.ThreadID,
+	}
+
+	for _, r := range m.cfg.Routes {
+		if !r.matches(user, stream) {
+			continue
+		}
+
+		if r.ChannelID != "" {
+			// Thread of the module does not belong to the channel
+			route.ChannelID, route.ThreadID = r.ChannelID, ""
+		}
+
+		for _, o := range []struct {
+			dst *string
+			src string
+		}{
+			{&route.MentionRoleID, r.MentionRoleID},
+			{&route.QuietHours, r.QuietHours},
+			{&route.ThreadID, r.ThreadID},
+		} {
+			if o.src != "" {
+				*o.dst = o.src
+			}
+		}
+
+		break
+	}
+
+	target := postTarget{ChannelID: route.ChannelID, MentionRoleID: route.MentionRoleID}
+
+	if route.ThreadID != "" {
+		target.ChannelID, target.Thread = route.ThreadID, true
+	}
+
+	quiet, err := inQuietHours(route.QuietHours, now.In(m.cfg.Timezone))
+	if err != nil {
+		return target, fmt.Errorf("checking quiet hours: %w", err)
+	}
+
+	if quiet {
+		target.MentionRoleID = ""
+	}
+
+	return target, nil
+}
+
+// validateRoutes checks the routes can be matched and the quiet hours
+// can be parsed
+func (m *modLivePosting) validateRoutes() error {
+	if _, err := inQuietHours(m.cfg.QuietHours, time.Now()); err != nil {
+		return fmt.Errorf("invalid quiet_hours: %w", err)
+	}
+
+	for i, r := range m.cfg.Routes {
+		if len(r.Games) == 0 && len(r.Users) == 0 {
+			return fmt.Errorf("route %d: neither games nor users given", i)
+		}
+
+		if _, err := inQuietHours(r.QuietHours, time.Now()); err != nil {
+			return fmt.Errorf("route %d: invalid quiet_hours: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// matches checks the stream of the user matches all given criteria of
+// the route, users are matched by channel reference and games by name
+// or ID
+func (l liveRoute) matches(user streaming.User, stream streaming.Stream) bool {
+	contains := func(list []string, values ...string) bool {
+		return slices.ContainsFunc(list, func(item string) bool {
+			return slices.ContainsFunc(values, func(v string) bool { return v != "" && strings.EqualFold(item, v) })
+		})
+	}
+
+	if len(l.Users) > 0 && !contains(l.Users, user.Ref()) {
+		return false
+	}
+
+	if len(l.Games) > 0 && !contains(l.Games, stream.GameName, stream.GameID) {
+		return false
+	}
+
+	return true
+}
+
+// allowedMentions restricts the mentions of the post to the role of
+// the target so templates cannot ping anyone else
+func (p postTarget) allowedMentions() *discordgo.MessageAllowedMentions {
+	allowed := &discordgo.MessageAllowedMentions{Parse: []discordgo.AllowedMentionType{}}
+	if p.MentionRoleID != "" {
+		allowed.Roles = []string{p.MentionRoleID}
+	}
+
+	return allowed
+}
+
+// inQuietHours checks whether the time is within the quiet hours given
+// as `HH:MM-HH:MM` which may span midnight. Empty quiet hours never
+// match.
+func inQuietHours(spec string, t time.Time) (bool, error) {
+	if spec == "" {
+		return false, nil
+	}
+
+	startStr, endStr, ok := strings.Cut(spec, "-")
+	if !ok {
+		return false, errQuietHoursFormat
+	}
+
+	start, err := time.Parse(quietHoursClockFormat, strings.TrimSpace(startStr))
+	if err != nil {
+		return false, fmt.Errorf("parsing start: %w", err)
+	}
+
+	end, err := time.Parse(quietHoursClockFormat, strings.TrimSpace(endStr))
+	if err != nil {
+		return false, fmt.Errorf("parsing end: %w", err)
+	}
+
+	minuteOfDay := func(t time.Time) int { return t.Hour()*60 + t.Minute() } //nolint:mnd // Minutes per hour
+	now, from, until := minuteOfDay(t), minuteOfDay(start), minuteOfDay(end)
+
+	if from <= until {
+		return now >= from && now < until, nil
+	}
+
+	// Quiet hours span midnight
+	return now >= from || now < until, nil
+}
+
+// mentionContent prefixes the content with the mention of the role
+func mentionContent(roleID, content string) string {
+	if roleID == "" {
+		return content
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("<@&%s> %s", roleID, content))
+}
@@ -0,0 +1,96 @@
+package liveposting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/streaming"
+)
+
+func TestInQuietHours(t *testing.T) {
+	for _, tc := range []struct {
+		spec  string
+		clock string
+		quiet bool
+	}{
+		{"", "03:00", false},
+		{"01:00-06:00", "00:59", false},
+		{"01:00-06:00", "01:00", true},
+		{"01:00-06:00", "05:59", true},
+		{"01:00-06:00", "06:00", false},
+		// Spanning midnight
+		{"22:00-06:00", "21:59", false},
+		{"22:00-06:00", "22:00", true},
+		{"22:00-06:00", "23:59", true},
+		{"22:00-06:00", "00:00", true},
+		{"22:00-06:00", "05:59", true},
+		{"22:00-06:00", "06:00", false},
+		{"22:00-06:00", "12:00", false},
+		{" 22:00 - 06:00 ", "23:00", true},
+	} {
+		now, err := time.Parse(quietHoursClockFormat, tc.clock)
+		if err != nil {
+			t.Fatalf("parsing clock %q: %s", tc.clock, err)
+		}
+
+		quiet, err := inQuietHours(tc.spec, now)
+		if err != nil {
+			t.Errorf("checking %q at %s: %s", tc.spec, tc.clock, err)
+			continue
+		}
+
+		if quiet != tc.quiet {
+			t.Errorf("checking %q at %s: expected %v, got %v", tc.spec, tc.clock, tc.quiet, quiet)
+		}
+	}
+
+	for _, spec := range []string{"22:00", "22-06", "25:00-06:00"} {
+		if _, err := inQuietHours(spec, time.Now()); err == nil {
+			t.Errorf("expected %q to be rejected", spec)
+		}
+	}
+}
+
+func TestPostTarget(t *testing.T) {
+	m := &modLivePosting{cfg: moduleConfig{
+		ChannelID:     "channel",
+		MentionRoleID: "role",
+		QuietHours:    "22:00-06:00",
+		Timezone:      time.UTC,
+		Routes: []liveRoute{
+			{Games: []string{"Just Chatting"}, ThreadID: "chatting-thread"},
+			{Users: []string{"youtube:UCabc"}, ChannelID: "youtube-channel", MentionRoleID: "youtube-role", QuietHours: "01:00-02:00"},
+		},
+	}}
+
+	var (
+		noon     = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		midnight = time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+		twitch   = streaming.User{Platform: streaming.PlatformTwitch, Login: "luziferus"}
+		youtube  = streaming.User{Platform: streaming.PlatformYouTube, Login: "UCabc"}
+	)
+
+	for name, tc := range map[string]struct {
+		user     streaming.User
+		stream   streaming.Stream
+		now      time.Time
+		expected postTarget
+	}{
+		"default":               {user: twitch, now: noon, expected: postTarget{ChannelID: "channel", MentionRoleID: "role"}},
+		"default quiet":         {user: twitch, now: midnight, expected: postTarget{ChannelID: "channel"}},
+		"game route in thread":  {user: twitch, stream: streaming.Stream{GameName: "just chatting"}, now: noon, expected: postTarget{ChannelID: "chatting-thread", MentionRoleID: "role", Thread: true}},
+		"user route":            {user: youtube, now: noon, expected: postTarget{ChannelID: "youtube-channel", MentionRoleID: "youtube-role"}},
+		"route own quiet hours": {user: youtube, now: midnight, expected: postTarget{ChannelID: "youtube-channel", MentionRoleID: "youtube-role"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			target, err := m.postTarget(tc.user, tc.stream, tc.now)
+			if err != nil {
+				t.Fatalf("resolving target: %s", err)
+			}
+
+			if target != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, target)
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package liveposting
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/streaming"
+)
+
+type (
+	// embedField contains the templates of one field of the embed
+	embedField struct {
+		Name   string `attr:"name" required:"true"`
+		Value  string `attr:"value" required:"true"`
+		Inline bool   `attr:"inline"`
+	}
+
+	// postRenderer renders templates with the same data and keeps
+	// the first error so the embed can be assembled without checking
+	// each field
+	postRenderer struct {
+		data postTemplateData
+		err  error
+	}
+
+	// postTemplate contains the templates to render the live-post from,
+	// empty values of a per-user template are taken from the module
+	// attributes
+	postTemplate struct {
+		Content          string                `attr:"post_text"`
+		EmbedAuthorIcon  string                `attr:"embed_author_icon"`
+		EmbedAuthorName  string                `attr:"embed_author_name"`
+		EmbedColor       *attributestore.Color `attr:"embed_color"`
+		EmbedDescription string                `attr:"embed_description"`
+		EmbedFields      []embedField          `attr:"embed_fields"`
+		EmbedFooter      string                `attr:"embed_footer"`
+		EmbedImage       string                `attr:"embed_image"`
+		EmbedThumbnail   string                `attr:"embed_thumbnail"`
+		EmbedTitle       string                `attr:"embed_title"`
+		EmbedURL         string                `attr:"embed_url"`
+	}
+
+	// postTemplateData is passed to the templates of the live-post
+	postTemplateData struct {
+		// PreviewURL is the URL of the stream preview sized to the
+		// embed image, changed on every render to bypass caches
+		PreviewURL string
+		Stream     streaming.Stream
+		// Uptime is the time since the stream started in hours and
+		// minutes (`2h 13m`)
+		Uptime string
+		User   streaming.User
+		// VODURL is the URL of the VOD of the ended stream, only set
+		// for the offline_text
+		VODURL string
+	}
+)
+
+// assembleLivePost renders the content and the embed of the live-post
+// for the stream of the user
+func (m *modLivePosting) assembleLivePost(user streaming.User, stream streaming.Stream) (string, *discordgo.MessageEmbed, error) {
+	tpl := m.postTemplate(user.Ref())
+
+	previewURL, err := m.previewURL(stream)
+	if err != nil {
+		return "", nil, err
+	}
+
+	data := postTemplateData{
+		PreviewURL: previewURL,
+		Stream:     stream,
+		Uptime:     formatDuration(time.Since(stream.StartedAt)),
+		User:       user,
+	}
+
+	r := &postRenderer{data: data}
+
+	content := strings.NewReplacer(
+		"${displayname}", user.DisplayName,
+		"${username}", user.Login,
+	).Replace(r.render("post_text", tpl.Content))
+
+	embed := &discordgo.MessageEmbed{
+		Color:       int(*tpl.EmbedColor),
+		Description: r.render("embed_description", tpl.EmbedDescription),
+		Title:       r.render("embed_title", tpl.EmbedTitle),
+		Type:        discordgo.EmbedTypeRich,
+		URL:         r.render("embed_url", tpl.EmbedURL),
+	}
+
+	if name := r.render("embed_author_name", tpl.EmbedAuthorName); name != "" {
+		embed.Author = &discordgo.MessageEmbedAuthor{
+			Name:    name,
+			IconURL: r.render("embed_author_icon", tpl.EmbedAuthorIcon),
+		}
+	}
+
+	for i, f := range tpl.EmbedFields {
+		name, value := r.render(fmt.Sprintf("embed_fields[%d].name", i), f.Name), r.render(fmt.Sprintf("embed_fields[%d].value", i), f.Value)
+		if name == "" || value == "" {
+			// Discord rejects fields without name or value
+			continue
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: name, Value: value, Inline: f.Inline})
+	}
+
+	if text := r.render("embed_footer", tpl.EmbedFooter); text != "" {
+		embed.Footer = &discordgo.MessageEmbedFooter{Text: text}
+	}
+
+	if u := r.render("embed_image", tpl.EmbedImage); u != "" {
+		embed.Image = &discordgo.MessageEmbedImage{
+			URL:    u,
+			Width:  int(m.cfg.EmbedImageWidth),
+			Height: int(m.cfg.EmbedImageHeight),
+		}
+	}
+
+	if u := r.render("embed_thumbnail", tpl.EmbedThumbnail); u != "" {
+		embed.Thumbnail = &discordgo.MessageEmbedThumbnail{
+			URL:    u,
+			Width:  int(m.cfg.EmbedThumbnailWidth),
+			Height: int(m.cfg.EmbedThumbnailHeight),
+		}
+	}
+
+	if r.err != nil {
+		return "", nil, r.err
+	}
+
+	return content, embed, nil
+}
+
+// postTemplate returns the templates for the channel reference (see
+// streaming.ParseChannel) with the per-user template applied on top of
+// the module attributes
+func (m *modLivePosting) postTemplate(ref string) postTemplate {
+	// Attribute names are not case-sensitive, the channel IDs of some
+	// platforms are
+	lookup := func(m map[string]string) (string, bool) {
+		for k, v := range m {
+			if strings.EqualFold(k, ref) {
+				return v, true
+			}
+		}
+		return "", false
+	}
+
+	tpl := postTemplate{
+		Content:          m.cfg.PostText,
+		EmbedAuthorIcon:  m.cfg.EmbedAuthorIcon,
+		EmbedAuthorName:  m.cfg.EmbedAuthorName,
+		EmbedColor:       &m.cfg.EmbedColor,
+		EmbedDescription: m.cfg.EmbedDescription,
+		EmbedFields:      m.cfg.EmbedFields,
+		EmbedFooter:      m.cfg.EmbedFooter,
+		EmbedImage:       m.cfg.EmbedImage,
+		EmbedThumbnail:   m.cfg.EmbedThumbnail,
+		EmbedTitle:       m.cfg.EmbedTitle,
+		EmbedURL:         m.cfg.EmbedURL,
+	}
+
+	if override, ok := lookup(m.cfg.PostTextOverrides); ok {
+		tpl.Content = override
+	}
+
+	var (
+		override postTemplate
+		ok       bool
+	)
+	for k, v := range m.cfg.Templates {
+		if strings.EqualFold(k, ref) {
+			override, ok = v, true
+		}
+	}
+	if !ok {
+		return tpl
+	}
+
+	for _, o := range []struct {
+		dst *string
+		src string
+	}{
+		{&tpl.Content, override.Content},
+		{&tpl.EmbedAuthorIcon, override.EmbedAuthorIcon},
+		{&tpl.EmbedAuthorName, override.EmbedAuthorName},
+		{&tpl.EmbedDescription, override.EmbedDescription},
+		{&tpl.EmbedFooter, override.EmbedFooter},
+		{&tpl.EmbedImage, override.EmbedImage},
+		{&tpl.EmbedThumbnail, override.EmbedThumbnail},
+		{&tpl.EmbedTitle, override.EmbedTitle},
+		{&tpl.EmbedURL, override.EmbedURL},
+	} {
+		if o.src != "" {
+			*o.dst = o.src
+		}
+	}
+
+	if override.EmbedColor != nil {
+		tpl.EmbedColor = override.EmbedColor
+	}
+
+	if override.EmbedFields != nil {
+		tpl.EmbedFields = override.EmbedFields
+	}
+
+	return tpl
+}
+
+// previewURL returns the URL of the stream preview sized to the embed
+// image, optionally served through the preserve proxy
+func (m *modLivePosting) previewURL(stream streaming.Stream) (string, error) {
+	// Discord caches the images and the URLs do not change every time
+	// so we force Discord to load a new image every time
+	previewImageURL, err := url.Parse(
+		strings.NewReplacer(
+			"{width}", strconv.FormatInt(m.cfg.EmbedImageWidth, 10),
+			"{height}", strconv.FormatInt(m.cfg.EmbedImageHeight, 10),
+		).Replace(stream.ThumbnailURL),
+	)
+	if err != nil {
+		return "", fmt.Errorf("parsing stream preview URL: %w", err)
+	}
+
+	previewImageQuery := previewImageURL.Query()
+	previewImageQuery.Add("_discordNoCache", time.Now().Format(time.RFC3339))
+	previewImageURL.RawQuery = previewImageQuery.Encode()
+
+	if proxy, err := url.Parse(m.cfg.PreserveProxy); err == nil && proxy.String() != "" {
+		// Discord screws up the plain-text URL format, so we need to use the b64-format
+		proxy.Path = "/b64:" + base64.URLEncoding.EncodeToString([]byte(previewImageURL.String()))
+		previewImageURL = proxy
+	}
+
+	return previewImageURL.String(), nil
+}
+
+// validateTemplates parses all templates of the module so errors are
+// reported on initialization instead of when posting
+func (m *modLivePosting) validateTemplates() error {
+	tpls := []postTemplate{m.postTemplate("")}
+	for login := range m.cfg.Templates {
+		tpls = append(tpls, m.postTemplate(login))
+	}
+	for login := range m.cfg.PostTextOverrides {
+		tpls = append(tpls, m.postTemplate(login))
+	}
+
+	if _, err := parsePostTemplate(m.cfg.OfflineText); err != nil {
+		return err
+	}
+
+	for _, tpl := range tpls {
+		sources := []string{
+			tpl.Content, tpl.EmbedAuthorIcon, tpl.EmbedAuthorName, tpl.EmbedDescription,
+			tpl.EmbedFooter, tpl.EmbedImage, tpl.EmbedThumbnail, tpl.EmbedTitle, tpl.EmbedURL,
+		}
+		for _, f := range tpl.EmbedFields {
+			sources = append(sources, f.Name, f.Value)
+		}
+
+		for _, src := range sources {
+			if _, err := parsePostTemplate(src); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// render executes the template and returns the trimmed output or an
+// empty string if rendering failed
+func (p *postRenderer) render(name, src string) string {
+	if p.err != nil || src == "" {
+		return ""
+	}
+
+	tpl, err := parsePostTemplate(src)
+	if err != nil {
+		p.err = fmt.Errorf("parsing %s template: %w", name, err)
+		return ""
+	}
+
+	buf := new(bytes.Buffer)
+	if err = tpl.Execute(buf, p.data); err != nil {
+		p.err = fmt.Errorf("executing %s template: %w", name, err)
+		return ""
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
+// parsePostTemplate parses the template with the sprig functions
+// available
+func parsePostTemplate(src string) (*template.Template, error) {
+	tpl, err := template.New("liveposting").Funcs(sprig.FuncMap()).Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	return tpl, nil
+}
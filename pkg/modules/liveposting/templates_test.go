@@ -0,0 +1,165 @@
+package liveposting
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/streaming"
+)
+
+func TestAssembleLivePost(t *testing.T) {
+	stream := streaming.Stream{
+		ID:           "10",
+		Platform:     streaming.PlatformTwitch,
+		UserID:       "1",
+		UserLogin:    "luziferus",
+		UserName:     "Luziferus",
+		GameName:     "Software and Game Development",
+		Title:        "Coding",
+		ViewerCount:  42,
+		StartedAt:    time.Now().Add(-90 * time.Minute),
+		ThumbnailURL: "https://static-cdn.jtvnw.net/previews-ttv/live_user_luziferus-{width}x{height}.jpg",
+	}
+
+	users := map[string]streaming.User{
+		"luziferus": {ID: "1", Platform: streaming.PlatformTwitch, Login: "luziferus", DisplayName: "Luziferus", ProfileImageURL: "https://example.com/luziferus.png", URL: "https://www.twitch.tv/luziferus"},
+		"other":     {ID: "2", Platform: streaming.PlatformTwitch, Login: "other", DisplayName: "Other", ProfileImageURL: "https://example.com/other.png", URL: "https://www.twitch.tv/other"},
+	}
+
+	for name, tc := range map[string]struct {
+		attrs   attributestore.ModuleAttributeStore
+		user    string
+		content string
+		check   func(*testing.T, *discordgo.MessageEmbed)
+		err     bool
+	}{
+		"default templates": {
+			user:    "luziferus",
+			content: "Luziferus is live",
+			check: func(t *testing.T, e *discordgo.MessageEmbed) {
+				switch {
+				case e.Title != "Coding", e.URL != "https://www.twitch.tv/luziferus":
+					t.Errorf("unexpected title / URL %q / %q", e.Title, e.URL)
+				case e.Color != 0x6441a5:
+					t.Errorf("unexpected color %#x", e.Color)
+				case e.Author == nil || e.Author.Name != "Luziferus" || e.Author.IconURL != "https://example.com/luziferus.png":
+					t.Errorf("unexpected author %+v", e.Author)
+				case e.Thumbnail == nil || e.Thumbnail.URL != "https://example.com/luziferus.png" || e.Thumbnail.Width != 300:
+					t.Errorf("unexpected thumbnail %+v", e.Thumbnail)
+				case e.Footer != nil:
+					t.Errorf("expected no footer, got %+v", e.Footer)
+				}
+
+				if fields := embedFields(e); fields != "Game=Software and Game Development|Viewers=42|Uptime=1h 30m" {
+					t.Errorf("unexpected fields %s", fields)
+				}
+
+				if e.Image == nil || e.Image.Width != 1280 || e.Image.Height != 720 {
+					t.Fatalf("unexpected image %+v", e.Image)
+				}
+				preview, err := url.Parse(e.Image.URL)
+				if err != nil || !strings.HasSuffix(preview.Path, "-1280x720.jpg") || preview.Query().Get("_discordNoCache") == "" {
+					t.Errorf("expected sized and cache-busted preview, got %q", e.Image.URL)
+				}
+			},
+		},
+		"sprig functions": {
+			attrs: attributestore.ModuleAttributeStore{
+				"post_text":         "{{ .User.DisplayName | upper }} streams {{ .Stream.GameName | lower | quote }}",
+				"embed_description": `{{ .Stream.Tags | join ", " | default "no tags" }}`,
+			},
+			user:    "luziferus",
+			content: `LUZIFERUS streams "software and game development"`,
+			check: func(t *testing.T, e *discordgo.MessageEmbed) {
+				if e.Description != "no tags" {
+					t.Errorf("unexpected description %q", e.Description)
+				}
+			},
+		},
+		"post_text override": {
+			attrs:   attributestore.ModuleAttributeStore{"post_text_Luziferus": "{{ .User.DisplayName }} is coding: {{ .Stream.Title }}"},
+			user:    "luziferus",
+			content: "Luziferus is coding: Coding",
+		},
+		"post_text override of other user": {
+			attrs:   attributestore.ModuleAttributeStore{"post_text_luziferus": "{{ .User.DisplayName }} is coding"},
+			user:    "other",
+			content: "Other is live",
+		},
+		"embed overrides": {
+			attrs: attributestore.ModuleAttributeStore{
+				"embed_footer": "{{ .Stream.Platform }}",
+				"template_luziferus": map[string]any{
+					"post_text":       "${username} went live",
+					"embed_color":     "#ff0000",
+					"embed_fields":    []any{map[string]any{"name": "Viewers", "value": "{{ .Stream.ViewerCount }}"}, map[string]any{"name": "Empty", "value": "{{ .Stream.Language }}"}},
+					"embed_image":     "",
+					"embed_thumbnail": "https://example.com/custom.png",
+					"embed_title":     "{{ .Stream.Title | upper }}",
+				},
+			},
+			user:    "luziferus",
+			content: "luziferus went live",
+			check: func(t *testing.T, e *discordgo.MessageEmbed) {
+				switch {
+				case e.Title != "CODING":
+					t.Errorf("unexpected title %q", e.Title)
+				case e.Color != 0xff0000:
+					t.Errorf("unexpected color %#x", e.Color)
+				case e.Thumbnail == nil || e.Thumbnail.URL != "https://example.com/custom.png":
+					t.Errorf("unexpected thumbnail %+v", e.Thumbnail)
+				case e.Image == nil:
+					t.Error("expected empty override to keep the image of the module")
+				case e.Footer == nil || e.Footer.Text != "twitch":
+					t.Errorf("expected footer of the module, got %+v", e.Footer)
+				}
+
+				if fields := embedFields(e); fields != "Viewers=42" {
+					t.Errorf("expected overridden fields without empty ones, got %s", fields)
+				}
+			},
+		},
+		"template error": {
+			attrs: attributestore.ModuleAttributeStore{"embed_title": "{{ .Stream.Unknown }}"},
+			user:  "luziferus",
+			err:   true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := newTestModule(t, moduleAttrs(tc.attrs), nil)
+
+			content, embed, err := m.assembleLivePost(users[tc.user], stream)
+			if tc.err {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("assembling post: %s", err)
+			}
+
+			if content != tc.content {
+				t.Errorf("expected content %q, got %q", tc.content, content)
+			}
+			if tc.check != nil {
+				tc.check(t, embed)
+			}
+		})
+	}
+}
+
+// embedFields formats the fields of the embed as `name=value|...`
+func embedFields(e *discordgo.MessageEmbed) string {
+	fields := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		fields = append(fields, f.Name+"="+f.Value)
+	}
+
+	return strings.Join(fields, "|")
+}
@@ -1,65 +1,141 @@
-// Package liverole implements a module for assigning Discord roles to live streamers.
+// Package liverole implements a module for assigning Discord roles to live streamers
+// on Twitch, YouTube and Kick.
 package liverole
 
 import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"net/url"
 	"slices"
 	"strings"
+	"sync"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/sirupsen/logrus"
 
-	"github.com/Luzifer/discord-community/pkg/attributestore"
 	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/metrics"
 	"github.com/Luzifer/discord-community/pkg/modules"
+	"github.com/Luzifer/discord-community/pkg/streaming"
 	"github.com/Luzifer/discord-community/pkg/twitch"
 )
 
-/*
- * @module liverole
- * @module_desc Adds live-role to certain group of users if they are streaming on Twitch
- */
+type (
+	modLiveRole struct {
+		cfg       moduleConfig
+		discord   *discordgo.Session
+		id        string
+		config    *config.File
+		eventSub  *twitch.EventSubClient
+		live      *metrics.LiveTracker
+		providers streaming.Providers
+		twitch    *twitch.Adapter
+
+		// discordUsers maps the lower-case Twitch logins to the Discord
+		// user IDs configured through discord_user_{username}
+		discordUsers  map[string]string
+		webhookActive func(login string) bool
+
+		// twitchIDs maps the lower-case Twitch logins to their user IDs
+		// resolved for the EventSub WebSocket session
+		twitchIDs     map[string]string
+		twitchIDsLock sync.RWMutex
+	}
 
-type modLiveRole struct {
-	attrs   attributestore.ModuleAttributeStore
-	discord *discordgo.Session
-	id      string
-	config  *config.File
-}
+	moduleConfig struct {
+		DiscordUsers       map[string]string `attr:"discord_user_{username}"`
+		RoleStreamers      string            `attr:"role_streamers"`
+		RoleStreamersLive  string            `attr:"role_streamers_live" required:"true"`
+		TwitchClientID     string            `attr:"twitch_client_id"`
+		TwitchClientSecret string            `attr:"twitch_client_secret"`
+		TwitchUserToken    string            `attr:"twitch_user_token"`
+	}
+)
+
+var moduleSchema = modules.NewSchema(
+	"Adds live-role to certain group of users if they are streaming on Twitch, YouTube (requires top-level `youtube` section) or Kick (requires top-level `kick` section)",
+	moduleConfig{},
+	map[string]string{
+		"discord_user_{username}": "Discord user ID of the member streaming as Twitch user `{username}`: these streamers are tracked through EventSub instead of their Discord presence (requires `twitch_user_token` or the `twitch_eventsub` webhook)",
+		"role_streamers":          "Only take members with this role ID into account",
+		"role_streamers_live":     "Role ID to assign to live streamers (make sure the bot [can assign](https://support.discord.com/hc/en-us/articles/214836687-Role-Management-101) this role)",
+		"twitch_client_id":        "Twitch client ID overriding the one of the top-level `twitch` section",
+		"twitch_client_secret":    "Secret for the Twitch app identified with twitch_client_id (required when overriding twitch_client_id)",
+		"twitch_user_token":       "User access token issued for the Twitch app: enables instant notifications through EventSub for the `discord_user_{username}` streamers",
+	},
+)
 
 func init() {
-	modules.RegisterModule("liverole", func() modules.Module { return &modLiveRole{} })
+	modules.RegisterModule("liverole", func() modules.Module { return &modLiveRole{} }, moduleSchema)
 }
 
-func (m modLiveRole) ID() string { return m.id }
+func (m *modLiveRole) ID() string { return m.id }
 
 func (m *modLiveRole) Initialize(args modules.ModuleInitArgs) error {
-	m.attrs = args.Attrs
 	m.discord = args.Discord
 	m.id = args.ID
 	m.config = args.Config
+	m.live = metrics.NewLiveTracker(args.ID)
+
+	if err := args.Attrs.Decode(&m.cfg); err != nil {
+		return fmt.Errorf("decoding attributes: %w", err)
+	}
+
+	var err error
+	if m.twitch, err = args.TwitchAdapter(m.cfg.TwitchClientID, m.cfg.TwitchClientSecret); err != nil {
+		return fmt.Errorf("getting twitch adapter: %w", err)
+	}
+	m.providers = args.StreamingProviders(m.twitch)
+
+	args.AddHandler(m.handlePresenceUpdate)
 
-	if err := m.attrs.Expect(
-		"role_streamers_live",
-		"twitch_client_id",
-		"twitch_client_secret",
-	); err != nil {
-		return fmt.Errorf("validating attributes: %w", err)
+	m.discordUsers = make(map[string]string)
+	for username, discordUserID := range m.cfg.DiscordUsers {
+		m.discordUsers[strings.ToLower(username)] = discordUserID
 	}
 
-	m.discord.AddHandler(m.handlePresenceUpdate)
+	m.webhookActive = args.TwitchEventSubActive
+	args.AddTwitchEventSubHandler(slices.Collect(maps.Keys(m.discordUsers)), m.handleEventSubNotification)
+
+	if m.cfg.TwitchUserToken != "" && len(m.cfg.DiscordUsers) > 0 {
+		m.eventSub = twitch.NewEventSubClient(
+			m.twitch.With(twitch.WithToken(m.cfg.TwitchUserToken)),
+			m.eventSubSubscriptions,
+			m.handleEventSubNotification,
+		)
+	}
+
+	return nil
+}
+
+func (m *modLiveRole) Setup() error {
+	if m.eventSub == nil {
+		return nil
+	}
+
+	if err := m.eventSub.Start(); err != nil {
+		return fmt.Errorf("starting EventSub client: %w", err)
+	}
 
 	return nil
 }
 
-func (modLiveRole) Setup() error { return nil }
+func (m *modLiveRole) Shutdown(ctx context.Context) error {
+	if m.eventSub == nil {
+		return nil
+	}
+
+	if err := m.eventSub.Stop(ctx); err != nil {
+		return fmt.Errorf("stopping EventSub client: %w", err)
+	}
 
-func (m modLiveRole) addLiveStreamerRole(guildID, userID string, presentRoles []string) (err error) {
-	// @attr role_streamers_live required string "" Role ID to assign to live streamers (make sure the bot [can assign](https://support.discord.com/hc/en-us/articles/214836687-Role-Management-101) this role)
-	roleID := m.attrs.MustString("role_streamers_live", nil)
+	return nil
+}
+
+func (m *modLiveRole) addLiveStreamerRole(guildID, userID string, presentRoles []string) (err error) {
+	roleID := m.cfg.RoleStreamersLive
 	if roleID == "" {
 		return errors.New("empty live-role-id")
 	}
@@ -75,7 +151,80 @@ func (m modLiveRole) addLiveStreamerRole(guildID, userID string, presentRoles []
 	return nil
 }
 
-func (m modLiveRole) handlePresenceUpdate(d *discordgo.Session, p *discordgo.PresenceUpdate) {
+// eventSubSubscriptions resolves the IDs of the configured streamers,
+// syncs their live-role with their current stream status as events
+// might have been missed while not connected and returns the
+// subscriptions to track their streams
+func (m *modLiveRole) eventSubSubscriptions(ctx context.Context) ([]twitch.EventSubSubscription, error) {
+	logins := slices.Collect(maps.Keys(m.discordUsers))
+
+	users, err := m.twitch.GetUserByUsername(ctx, logins...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching twitch user details: %w", err)
+	}
+
+	streams, err := m.twitch.GetStreamsForUser(ctx, logins...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching streams for users: %w", err)
+	}
+
+	var (
+		subs      []twitch.EventSubSubscription
+		twitchIDs = make(map[string]string)
+	)
+
+	for _, user := range users.Data {
+		twitchIDs[strings.ToLower(user.Login)] = user.ID
+		subs = append(subs,
+			twitch.StreamOfflineSubscription(user.ID),
+			twitch.StreamOnlineSubscription(user.ID),
+		)
+
+		isLive := slices.ContainsFunc(streams.Data, func(s twitch.Stream) bool { return s.UserID == user.ID })
+		if err = m.setLiveStreamerRole(m.discordUsers[strings.ToLower(user.Login)], isLive); err != nil {
+			logrus.WithError(err).WithField("user", user.Login).Error("Unable to update live-streamer-role")
+		}
+	}
+
+	m.twitchIDsLock.Lock()
+	defer m.twitchIDsLock.Unlock()
+
+	m.twitchIDs = twitchIDs
+
+	return subs, nil
+}
+
+func (m *modLiveRole) handleEventSubNotification(_ context.Context, n twitch.EventSubNotification) {
+	logger := logrus.WithFields(logrus.Fields{
+		"id":   m.id,
+		"type": n.Subscription.Type,
+	})
+
+	// Both events carry the broadcaster of the stream
+	var evt twitch.StreamOfflineEvent
+	if err := n.Decode(&evt); err != nil {
+		logger.WithError(err).Error("Unable to decode EventSub notification")
+		return
+	}
+
+	discordUserID, ok := m.discordUsers[strings.ToLower(evt.BroadcasterUserLogin)]
+	if !ok {
+		return
+	}
+
+	if n.Subscription.Type != twitch.EventSubTypeStreamOffline && n.Subscription.Type != twitch.EventSubTypeStreamOnline {
+		return
+	}
+
+	if err := m.setLiveStreamerRole(discordUserID, n.Subscription.Type == twitch.EventSubTypeStreamOnline); err != nil {
+		logger.WithError(err).WithField("user", evt.BroadcasterUserLogin).Error("Unable to update live-streamer-role")
+		return
+	}
+
+	logger.WithField("user", evt.BroadcasterUserLogin).Debug("Updated live-streamer-role")
+}
+
+func (m *modLiveRole) handlePresenceUpdate(d *discordgo.Session, p *discordgo.PresenceUpdate) {
 	if p.User == nil {
 		// The frick? Non-user presence?
 		return
@@ -86,6 +235,11 @@ func (m modLiveRole) handlePresenceUpdate(d *discordgo.Session, p *discordgo.Pre
 		return
 	}
 
+	if m.isTrackedByEventSub(p.User.ID) {
+		// Live status is known from EventSub, presence is not reliable
+		return
+	}
+
 	logger := logrus.WithField("user", p.User.ID)
 
 	member, err := d.GuildMember(p.GuildID, p.User.ID)
@@ -96,15 +250,19 @@ func (m modLiveRole) handlePresenceUpdate(d *discordgo.Session, p *discordgo.Pre
 
 	logger = logger.WithField("name", member.User.String())
 
-	// @attr role_streamers optional string "" Only take members with this role ID into account
-	roleStreamer := m.attrs.MustString("role_streamers", new(""))
+	roleStreamer := m.cfg.RoleStreamers
 	if roleStreamer != "" && !slices.Contains(member.Roles, roleStreamer) {
 		// User is not part of the streamer role
 		return
 	}
 
-	var exitFunc func(string, string, []string) error
+	var (
+		exitFunc func(string, string, []string) error
+		isLive   bool
+	)
 	defer func() {
+		m.live.SetLive(p.User.ID, isLive)
+
 		if exitFunc != nil {
 			if err := exitFunc(p.GuildID, p.User.ID, member.Roles); err != nil {
 				logger.WithError(err).Error("Unable to update live-streamer-role")
@@ -137,37 +295,56 @@ func (m modLiveRole) handlePresenceUpdate(d *discordgo.Session, p *discordgo.Pre
 		return
 	}
 
-	if u.Host != "www.twitch.tv" {
-		logger.WithError(err).WithField("url", activity.URL).Warning("Activity is not on Twitch")
+	platform, channel, ok, err := m.providers.ChannelFromURL(context.Background(), u)
+	if err != nil || !ok {
+		logger.WithError(err).WithField("url", activity.URL).Warning("Activity is not on a configured platform")
 		exitFunc = m.removeLiveStreamerRole
-		logger = logger.WithFields(logrus.Fields{"action": "remove", "reason": "activity not on twitch"})
+		logger = logger.WithFields(logrus.Fields{"action": "remove", "reason": "activity not on configured platform"})
 		return
 	}
 
-	t := twitch.New(
-		// @attr twitch_client_id required string "" Twitch client ID the token was issued for
-		m.attrs.MustString("twitch_client_id", nil),
-		// @attr twitch_client_secret required string "" Secret for the Twitch app identified with twitch_client_id
-		m.attrs.MustString("twitch_client_secret", nil),
-		"", // No User-Token used
-	)
-
-	streams, err := t.GetStreamsForUser(context.Background(), strings.TrimLeft(u.Path, "/"))
+	streams, err := m.providers.Streams(context.Background(), streaming.Key(platform, channel))
 	if err != nil {
-		logger.WithError(err).WithField("user", strings.TrimLeft(u.Path, "/")).Warning("Unable to fetch streams for user")
+		logger.WithError(err).WithFields(logrus.Fields{"platform": platform, "user": channel}).Warning("Unable to fetch streams for user")
 		exitFunc = m.removeLiveStreamerRole
 		logger = logger.WithFields(logrus.Fields{"action": "remove", "reason": "error in getting streams"})
 		return
 	}
 
-	if len(streams.Data) > 0 {
+	if len(streams) > 0 {
+		isLive = true
 		exitFunc = m.addLiveStreamerRole
 		logger = logger.WithFields(logrus.Fields{"action": "add", "reason": "stream found"})
 	}
 }
 
-func (m modLiveRole) removeLiveStreamerRole(guildID, userID string, presentRoles []string) (err error) {
-	roleID := m.attrs.MustString("role_streamers_live", nil)
+// isTrackedByEventSub reports whether the Discord user is mapped to a
+// Twitch user having active EventSub subscriptions through the
+// WebSocket session or the webhook
+func (m *modLiveRole) isTrackedByEventSub(discordUserID string) bool {
+	m.twitchIDsLock.RLock()
+	defer m.twitchIDsLock.RUnlock()
+
+	for login, userID := range m.discordUsers {
+		if userID != discordUserID {
+			continue
+		}
+
+		if m.webhookActive(login) {
+			return true
+		}
+
+		twitchID, ok := m.twitchIDs[login]
+		return ok && m.eventSub != nil &&
+			m.eventSub.IsActive(twitch.StreamOnlineSubscription(twitchID)) &&
+			m.eventSub.IsActive(twitch.StreamOfflineSubscription(twitchID))
+	}
+
+	return false
+}
+
+func (m *modLiveRole) removeLiveStreamerRole(guildID, userID string, presentRoles []string) (err error) {
+	roleID := m.cfg.RoleStreamersLive
 	if roleID == "" {
 		return errors.New("empty live-role-id")
 	}
@@ -182,3 +359,20 @@ func (m modLiveRole) removeLiveStreamerRole(guildID, userID string, presentRoles
 
 	return nil
 }
+
+// setLiveStreamerRole adds or removes the live-role of the Discord
+// user and records the live status
+func (m *modLiveRole) setLiveStreamerRole(discordUserID string, isLive bool) error {
+	member, err := m.discord.GuildMember(m.config.GuildID, discordUserID)
+	if err != nil {
+		return fmt.Errorf("fetching member: %w", err)
+	}
+
+	m.live.SetLive(discordUserID, isLive)
+
+	if isLive {
+		return m.addLiveStreamerRole(m.config.GuildID, discordUserID, member.Roles)
+	}
+
+	return m.removeLiveStreamerRole(m.config.GuildID, discordUserID, member.Roles)
+}
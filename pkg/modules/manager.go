@@ -0,0 +1,381 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/metrics"
+	"github.com/Luzifer/discord-community/pkg/streaming"
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+type (
+	// Manager keeps track of the active module instances and the
+	// resources registered by them so single instances can be torn
+	// down and re-initialized when the configuration changes
+	Manager struct {
+		crontab *cron.Cron
+		discord *discordgo.Session
+		guildID string
+		store   *MetaStore
+
+		instances []*instance
+		lock      sync.Mutex
+		problems  map[string]error
+
+		applicationID  string
+		commandsSynced bool
+		syncedCommands []*discordgo.ApplicationCommand
+
+		commandRoutes   map[string]interactionRoute
+		componentRoutes map[string]interactionRoute
+		eventSubRoutes  map[string]eventSubRoute
+		routeLock       sync.RWMutex
+
+		eventSubAdapter *twitch.Adapter
+		eventSubUserIDs map[string]string
+		eventSubWebhook *twitch.EventSubWebhook
+
+		streamingLock      sync.Mutex
+		streamingProviders streaming.Providers
+
+		twitchAdapter     *twitch.Adapter
+		twitchAuthAdapter *twitch.Adapter
+		twitchOptions     []twitch.Option
+		twitchUserTokens  map[string]*twitch.UserTokenSource
+		twitchLock        sync.Mutex
+	}
+
+	instance struct {
+		config    config.ModuleConfig
+		file      *config.File
+		module    Module
+		resources *instanceResources
+		setupDone bool
+	}
+
+	instanceResources struct {
+		commands         []registeredCommand
+		componentHandler InteractionHandler
+		cronJobs         []*cronJob
+		eventSubHandler  *registeredEventSubHandler
+		handlerRemovers  []func()
+		running          sync.WaitGroup
+	}
+)
+
+// NewManager creates a new Manager without any active modules and
+// registers the handler dispatching interactions to the modules
+func NewManager(crontab *cron.Cron, discord *discordgo.Session, store *MetaStore) *Manager {
+	m := &Manager{
+		crontab: crontab,
+		discord: discord,
+		store:   store,
+
+		commandRoutes:   make(map[string]interactionRoute),
+		componentRoutes: make(map[string]interactionRoute),
+		eventSubRoutes:  make(map[string]eventSubRoute),
+
+		twitchUserTokens: make(map[string]*twitch.UserTokenSource),
+	}
+
+	discord.AddHandler(m.handleInteraction)
+
+	return m
+}
+
+// Apply compares the module configurations in the given config with
+// the currently active modules and initializes new or changed modules
+// while tearing down changed or removed ones. Modules failing to
+// initialize are reported in the returned error and disabled, changed
+// modules are re-initialized with their previous config instead.
+// Newly initialized modules need to be set up using Setup afterwards.
+//
+//nolint:funlen // Single task, seeing no sense in splitting
+func (m *Manager) Apply(ctx context.Context, cfg *config.File) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var (
+		errs      []error
+		instances []*instance
+		seenIDs   []string
+	)
+
+	m.guildID = cfg.GuildID
+	m.problems = make(map[string]error)
+
+	// Tear down removed modules first to release their commands for
+	// modules initialized below
+	for _, inst := range m.instances {
+		if slices.ContainsFunc(cfg.ModuleConfigs, func(mc config.ModuleConfig) bool { return mc.ID == inst.config.ID }) {
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"id":     inst.config.ID,
+			"module": inst.config.Type,
+		}).Info("module removed from config, tearing down module")
+		m.teardown(ctx, inst)
+	}
+
+	for i, mc := range cfg.ModuleConfigs {
+		logger := logrus.WithFields(logrus.Fields{
+			"id":     mc.ID,
+			"idx":    i,
+			"module": mc.Type,
+		})
+
+		if slices.Contains(seenIDs, mc.ID) {
+			logger.Error("found duplicate module ID, module will be disabled")
+			continue
+		}
+
+		if mc.ID == "" {
+			logger.Error("module contains no ID and will be disabled")
+			continue
+		}
+
+		seenIDs = append(seenIDs, mc.ID)
+
+		previous := m.getInstance(mc.ID)
+		if previous != nil {
+			if previous.config.Type == mc.Type && reflect.DeepEqual(previous.config.Attributes, mc.Attributes) {
+				// Nothing changed, keep the running instance
+				instances = append(instances, previous)
+				continue
+			}
+
+			logger.Info("module config changed, re-initializing module")
+			m.teardown(ctx, previous)
+		}
+
+		inst, err := m.initialize(cfg, mc)
+		if err != nil {
+			m.problems[mc.ID] = fmt.Errorf("initializing module: %w", err)
+			errs = append(errs, fmt.Errorf("initializing module %q (%s): %w", mc.ID, mc.Type, err))
+
+			if previous == nil {
+				continue
+			}
+
+			// A broken config must not take down a working module, so
+			// it keeps running with the previous config
+			if inst, err = m.initialize(previous.file, previous.config); err != nil {
+				logger.WithError(err).Error("re-initializing module with previous config")
+				continue
+			}
+
+			logger.Warn("new module config is invalid, keeping previous config")
+			instances = append(instances, inst)
+			continue
+		}
+
+		instances = append(instances, inst)
+		logger.Debug("enabled module")
+	}
+
+	m.instances = instances
+
+	return errors.Join(errs...)
+}
+
+// Len returns the number of active module instances
+func (m *Manager) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return len(m.instances)
+}
+
+// Problems returns the errors of modules which failed to initialize
+// or to set up during the last Apply / Setup and the modules which
+// are initialized but not yet set up
+func (m *Manager) Problems() map[string]string {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	out := make(map[string]string)
+	for id, err := range m.problems {
+		out[id] = err.Error()
+	}
+
+	for _, inst := range m.instances {
+		if !inst.setupDone {
+			out[inst.config.ID] = "setup pending"
+		}
+	}
+
+	return out
+}
+
+// Setup executes the Setup method of all modules which were not yet
+// set up and syncs the application commands of all modules to the
+// guild. Modules failing to set up are torn down and reported in the
+// returned error, failing to sync the commands is only logged.
+func (m *Manager) Setup(ctx context.Context) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var (
+		errs      []error
+		instances []*instance
+	)
+
+	for _, inst := range m.instances {
+		if inst.setupDone {
+			instances = append(instances, inst)
+			continue
+		}
+
+		if err := inst.module.Setup(); err != nil {
+			m.problems[inst.config.ID] = fmt.Errorf("running setup: %w", err)
+			errs = append(errs, fmt.Errorf("running setup for module %q (%s): %w", inst.config.ID, inst.config.Type, err))
+			m.teardown(ctx, inst)
+			continue
+		}
+
+		inst.setupDone = true
+		instances = append(instances, inst)
+	}
+
+	m.instances = instances
+
+	if err := m.syncCommands(); err != nil {
+		// Commands are retried on the next Setup, the modules keep
+		// working without them
+		logrus.WithError(err).Error("syncing application commands")
+	}
+
+	if err := m.reconcileTwitchEventSub(ctx); err != nil {
+		// Modules fall back to polling for users without subscription
+		// so this must not disable any module
+		logrus.WithError(err).Error("reconciling Twitch EventSub webhook subscriptions")
+	}
+
+	return errors.Join(errs...)
+}
+
+// Shutdown tears down all active module instances in reverse order
+// of their initialization
+func (m *Manager) Shutdown(ctx context.Context) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for i := len(m.instances) - 1; i >= 0; i-- {
+		m.teardown(ctx, m.instances[i])
+	}
+
+	m.instances = nil
+}
+
+func (m *Manager) getInstance(id string) *instance {
+	for _, inst := range m.instances {
+		if inst.config.ID == id {
+			return inst
+		}
+	}
+
+	return nil
+}
+
+func (m *Manager) initialize(cfg *config.File, mc config.ModuleConfig) (*instance, error) {
+	mod := GetModuleByName(mc.Type)
+	if mod == nil {
+		return nil, errors.New("found configuration for unsupported module")
+	}
+
+	var errs []error
+	for _, err := range ValidateModuleConfig(mc) {
+		if errors.Is(err, ErrUnknownAttribute) {
+			// Unknown attributes do not break the module, so we only
+			// tell the user about them
+			logrus.WithError(err).WithField("id", mc.ID).Warn("validating module attributes")
+			continue
+		}
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("validating attributes: %w", errors.Join(errs...))
+	}
+
+	inst := &instance{
+		config:    mc,
+		file:      cfg,
+		module:    mod,
+		resources: &instanceResources{},
+	}
+
+	if err := mod.Initialize(ModuleInitArgs{
+		ID:    mc.ID,
+		Attrs: mc.Attributes,
+
+		Discord: m.discord,
+		Config:  cfg,
+		Store:   m.store,
+		Twitch:  m.sharedTwitchAdapter(),
+
+		crontab:   m.crontab,
+		manager:   m,
+		resources: inst.resources,
+	}); err != nil {
+		// Initialize might have registered resources before failing
+		m.teardown(context.Background(), inst)
+		return nil, err
+	}
+
+	if err := m.addRoutes(inst); err != nil {
+		m.teardown(context.Background(), inst)
+		return nil, err
+	}
+
+	return inst, nil
+}
+
+// teardown removes all cron entries, Discord handlers and interaction
+// routes registered by the instance, waits for running jobs of the
+// instance and calls the Shutdown method of the module if available
+func (m *Manager) teardown(ctx context.Context, inst *instance) {
+	m.removeRoutes(inst)
+
+	for _, job := range inst.resources.cronJobs {
+		m.crontab.Remove(job.entryID)
+	}
+
+	for _, remove := range inst.resources.handlerRemovers {
+		remove()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		inst.resources.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logrus.WithField("id", inst.config.ID).Warn("timeout waiting for running jobs of module")
+	}
+
+	metrics.RemoveModule(inst.config.ID)
+
+	s, ok := inst.module.(Shutdowner)
+	if !ok {
+		return
+	}
+
+	if err := s.Shutdown(ctx); err != nil {
+		logrus.WithError(err).WithField("id", inst.config.ID).Error("shutting down module")
+	}
+}
@@ -0,0 +1,98 @@
+package modules
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/robfig/cron/v3"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/config"
+)
+
+type (
+	testReloadConfig struct {
+		Fail  bool   `attr:"fail"`
+		Value string `attr:"value"`
+	}
+
+	testReloadModule struct {
+		cfg testReloadConfig
+		id  string
+	}
+)
+
+func init() {
+	RegisterModule("test-reload", func() Module { return &testReloadModule{} }, NewSchema("Test module", testReloadConfig{}, map[string]string{"fail": "Fail on initialize", "value": "Any value"}))
+}
+
+func (t *testReloadModule) ID() string { return t.id }
+
+func (t *testReloadModule) Initialize(args ModuleInitArgs) error {
+	t.id = args.ID
+
+	if err := args.Attrs.Decode(&t.cfg); err != nil {
+		return err //nolint:wrapcheck // Test module
+	}
+
+	if t.cfg.Fail {
+		return errors.New("failing as configured")
+	}
+
+	return nil
+}
+
+func (*testReloadModule) Setup() error { return nil }
+
+func TestApplyKeepsPreviousConfigOnFailure(t *testing.T) {
+	mgr := NewManager(cron.New(), &discordgo.Session{}, nil)
+
+	apply := func(attrs attributestore.ModuleAttributeStore) error {
+		return mgr.Apply(context.Background(), &config.File{ModuleConfigs: []config.ModuleConfig{
+			{ID: "mod", Type: "test-reload", Attributes: attrs},
+		}})
+	}
+
+	value := func() string {
+		inst := mgr.getInstance("mod")
+		if inst == nil {
+			return ""
+		}
+		return inst.module.(*testReloadModule).cfg.Value //nolint:forcetypeassert // Only registered type
+	}
+
+	if err := apply(attributestore.ModuleAttributeStore{"value": "first"}); err != nil {
+		t.Fatalf("applying config: %s", err)
+	}
+
+	if err := apply(attributestore.ModuleAttributeStore{"value": "second", "fail": true}); err == nil {
+		t.Fatal("expected failing module to be reported")
+	}
+
+	if v := value(); v != "first" {
+		t.Errorf("expected module to keep running with previous config, got value %q", v)
+	}
+	if _, ok := mgr.Problems()["mod"]; !ok {
+		t.Error("expected failed config to be reported as problem")
+	}
+
+	// Fixing the config replaces the previous one
+	if err := apply(attributestore.ModuleAttributeStore{"value": "third"}); err != nil {
+		t.Fatalf("applying config: %s", err)
+	}
+	if v := value(); v != "third" {
+		t.Errorf("expected fixed config to be applied, got value %q", v)
+	}
+
+	// New modules have no previous config to fall back to
+	if err := mgr.Apply(context.Background(), &config.File{ModuleConfigs: []config.ModuleConfig{
+		{ID: "new", Type: "test-reload", Attributes: attributestore.ModuleAttributeStore{"fail": true}},
+	}}); err == nil {
+		t.Fatal("expected failing module to be reported")
+	}
+	if n := mgr.Len(); n != 0 {
+		t.Errorf("expected no running modules, got %d", n)
+	}
+}
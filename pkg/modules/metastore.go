@@ -2,92 +2,134 @@
 package modules
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"os"
 	"sync"
-
-	"github.com/sirupsen/logrus"
+	"time"
 
 	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/metrics"
+	"github.com/Luzifer/discord-community/pkg/storage"
 )
 
-// MetaStore holds the data stored by modules and serializes it
+// MetaStore holds the data stored by modules and persists it using
+// the configured storage backend
 type MetaStore struct {
-	ModuleAttributes map[string]attributestore.ModuleAttributeStore `json:"module_attributes"`
+	backend storage.Backend
+	lock    sync.RWMutex
+}
 
-	filename string
-	lock     sync.RWMutex
+// NewMetaStore opens the storage backend described by the location
+// (see storage.New for supported formats) and returns a new MetaStore
+// instance using that backend
+func NewMetaStore(location string) (*MetaStore, error) {
+	backend, err := storage.New(location)
+	if err != nil {
+		return nil, fmt.Errorf("opening storage backend: %w", err)
+	}
+
+	return &MetaStore{backend: backend}, nil
 }
 
-// NewMetaStoreFromDisk reads the stored data from disk and returns
-// a new MetaStore instance with that data
-func NewMetaStoreFromDisk(filename string) (*MetaStore, error) {
-	out := &MetaStore{
-		ModuleAttributes: make(map[string]attributestore.ModuleAttributeStore),
-		filename:         filename,
+// Close closes the underlying storage backend
+func (m *MetaStore) Close() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err := m.backend.Close(); err != nil {
+		return fmt.Errorf("closing storage backend: %w", err)
 	}
 
-	s, err := os.Stat(filename)
-	switch {
-	case err == nil:
-		// This is fine
+	return nil
+}
 
-	case os.IsNotExist(err):
-		// No store yet, return empty store
-		return out, nil
+// Delete removes the given key for the given module ID
+func (m *MetaStore) Delete(moduleID, key string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	defer m.observeWrite("delete", time.Now())
 
-	default:
-		return nil, fmt.Errorf("getting file stats for store: %w", err)
+	if err := m.backend.Delete(moduleID, key); err != nil {
+		return fmt.Errorf("deleting from store: %w", err)
 	}
 
-	if s.IsDir() {
-		// A directory was provided
-		return nil, errors.New("store location is directory")
+	return nil
+}
+
+// DeleteModule removes all keys stored for the given module ID
+func (m *MetaStore) DeleteModule(moduleID string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	defer m.observeWrite("delete_module", time.Now())
+
+	attrs, err := m.backend.Get(moduleID)
+	if err != nil {
+		return fmt.Errorf("reading from store: %w", err)
 	}
 
-	if s.Size() == 0 {
-		// An empty file was created, we don't care and will overwrite on save
-		return out, nil
+	for key := range attrs {
+		if err = m.backend.Delete(moduleID, key); err != nil {
+			return fmt.Errorf("deleting from store: %w", err)
+		}
 	}
 
-	f, err := os.Open(filename) //#nosec:G304 // Intended to open store location
+	return nil
+}
+
+// Export returns a copy of all data held in the store
+func (m *MetaStore) Export() (storage.Dump, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	d, err := storage.Export(m.backend)
 	if err != nil {
-		return nil, fmt.Errorf("opening store: %w", err)
+		return d, fmt.Errorf("exporting store: %w", err)
 	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			logrus.WithError(err).Error("closing store (read)")
-		}
-	}()
 
-	if err = json.NewDecoder(f).Decode(out); err != nil {
-		return nil, fmt.Errorf("decoding store: %w", err)
+	return d, nil
+}
+
+// Import writes all data from the dump into the store, keys present
+// in the store but not in the dump are kept
+func (m *MetaStore) Import(d storage.Dump) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	defer m.observeWrite("import", time.Now())
+
+	if err := storage.Import(m.backend, d); err != nil {
+		return fmt.Errorf("importing store: %w", err)
 	}
 
-	return out, nil
+	return nil
 }
 
-// ReadWithLock returns the ModuleAttributeStore for the given module ID
-// and locks the MetaStore while the returned store is used
-func (m *MetaStore) ReadWithLock(moduleID string, fn func(m attributestore.ModuleAttributeStore) error) error {
+// Modules lists the IDs of all modules having data in the store
+func (m *MetaStore) Modules() ([]string, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
-	if m.ModuleAttributes[moduleID] == nil {
-		return fn(attributestore.ModuleAttributeStore{})
+	ids, err := m.backend.Modules()
+	if err != nil {
+		return nil, fmt.Errorf("listing modules: %w", err)
 	}
 
-	return fn(m.ModuleAttributes[moduleID])
+	return ids, nil
 }
 
-// Save stores the data to disk
-func (m *MetaStore) Save() error {
+// ReadWithLock returns the ModuleAttributeStore for the given module ID
+// and locks the MetaStore while the returned store is used
+func (m *MetaStore) ReadWithLock(moduleID string, fn func(m attributestore.ModuleAttributeStore) error) error {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
 
-	return m.save()
+	attrs, err := m.backend.Get(moduleID)
+	if err != nil {
+		return fmt.Errorf("reading from store: %w", err)
+	}
+
+	return fn(attrs)
 }
 
 // Set stores the given value for the given key and module ID
@@ -95,33 +137,15 @@ func (m *MetaStore) Set(moduleID, key string, value any) (err error) {
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	if m.ModuleAttributes[moduleID] == nil {
-		m.ModuleAttributes[moduleID] = make(attributestore.ModuleAttributeStore)
-	}
-
-	m.ModuleAttributes[moduleID][key] = value
+	defer m.observeWrite("set", time.Now())
 
-	if err = m.save(); err != nil {
+	if err = m.backend.Set(moduleID, key, value); err != nil {
 		return fmt.Errorf("saving store: %w", err)
 	}
 
 	return nil
 }
 
-func (m *MetaStore) save() error { //revive:disable-line:confusing-naming // exported has locking for other packages
-	f, err := os.Create(m.filename)
-	if err != nil {
-		return fmt.Errorf("creating storage file: %w", err)
-	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			logrus.WithError(err).Error("closing store (write)")
-		}
-	}()
-
-	if err = json.NewEncoder(f).Encode(m); err != nil {
-		return fmt.Errorf("encoding storage file: %w", err)
-	}
-
-	return nil
+func (*MetaStore) observeWrite(operation string, start time.Time) {
+	metrics.StoreWriteDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
 }
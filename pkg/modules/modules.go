@@ -2,7 +2,9 @@
 package modules
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 	"sync"
 
 	"github.com/bwmarrin/discordgo"
@@ -10,6 +12,8 @@ import (
 
 	"github.com/Luzifer/discord-community/pkg/attributestore"
 	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/metrics"
+	"github.com/Luzifer/discord-community/pkg/twitch"
 )
 
 type (
@@ -23,15 +27,33 @@ type (
 		Setup() error
 	}
 
+	// Shutdowner can be implemented by modules which need to clean up
+	// before the bot exits or the module is re-initialized (e.g. finish
+	// in-flight Discord requests)
+	Shutdowner interface {
+		// Shutdown is called after the cron entries and Discord handlers
+		// of the module have been removed and before the Discord
+		// connection is closed. The context is cancelled when the
+		// shutdown timeout is reached.
+		Shutdown(ctx context.Context) error
+	}
+
 	// ModuleInitArgs define the arguments a module is passed during its Initialize
 	ModuleInitArgs struct {
 		ID    string
 		Attrs attributestore.ModuleAttributeStore
 
-		Crontab *cron.Cron
 		Discord *discordgo.Session
 		Config  *config.File
 		Store   *MetaStore
+		// Twitch is the Adapter configured through the top-level
+		// twitch section (nil if not configured), use TwitchAdapter to
+		// respect credentials overridden by the module
+		Twitch *twitch.Adapter
+
+		crontab   *cron.Cron
+		manager   *Manager
+		resources *instanceResources
 	}
 
 	// ModuleInitFn creates a new Module instance when called
@@ -41,8 +63,61 @@ type (
 var (
 	moduleRegister     = make(map[string]ModuleInitFn)
 	moduleRegisterLock sync.RWMutex
+	moduleSchemas      = make(map[string]Schema)
 )
 
+// AddCronFunc registers a function to be executed on the given cron
+// schedule. The returned error is logged and recorded as the result of
+// the last run. Executions are skipped while the previous one is still
+// running. The entry is removed when the module is torn down.
+func (a ModuleInitArgs) AddCronFunc(spec string, cmd func() error) error {
+	job := &cronJob{
+		cmd:       cmd,
+		moduleID:  a.ID,
+		resources: a.resources,
+		spec:      spec,
+	}
+
+	id, err := a.crontab.AddFunc(spec, job.run)
+	if err != nil {
+		return fmt.Errorf("adding cron entry: %w", err)
+	}
+
+	job.entryID = id
+	a.resources.cronJobs = append(a.resources.cronJobs, job)
+	return nil
+}
+
+// AddHandler registers a Discord event handler (see
+// discordgo.Session.AddHandler). The handler is removed when the
+// module is torn down.
+func (a ModuleInitArgs) AddHandler(handler any) {
+	a.resources.handlerRemovers = append(a.resources.handlerRemovers, a.Discord.AddHandler(a.countingHandler(handler)))
+}
+
+// countingHandler wraps the handler to count the handled events using
+// the metrics.GatewayEvents counter. Discord handlers are functions
+// taking the session and the event so the wrapper is created with the
+// same signature in order to be accepted by discordgo.
+func (a ModuleInitArgs) countingHandler(handler any) any {
+	fn := reflect.ValueOf(handler)
+	if fn.Kind() != reflect.Func || fn.Type().NumIn() != 2 { //nolint:mnd // Session and event
+		// Not a handler we know how to wrap, discordgo will reject it
+		return handler
+	}
+
+	evtType := fn.Type().In(1)
+	if evtType.Kind() == reflect.Pointer {
+		evtType = evtType.Elem()
+	}
+	counter := metrics.GatewayEvents.WithLabelValues(a.ID, evtType.Name())
+
+	return reflect.MakeFunc(fn.Type(), func(args []reflect.Value) []reflect.Value {
+		counter.Inc()
+		return fn.Call(args)
+	}).Interface()
+}
+
 // GetModuleByName spawns a new instance of a Module when called
 func GetModuleByName(name string) Module {
 	moduleRegisterLock.RLock()
@@ -57,7 +132,8 @@ func GetModuleByName(name string) Module {
 }
 
 // RegisterModule registers a new named module for use with GetModuleByName
-func RegisterModule(name string, modInit ModuleInitFn) {
+// together with the Schema describing its attributes
+func RegisterModule(name string, modInit ModuleInitFn, schema Schema) {
 	moduleRegisterLock.Lock()
 	defer moduleRegisterLock.Unlock()
 
@@ -66,4 +142,5 @@ func RegisterModule(name string, modInit ModuleInitFn) {
 	}
 
 	moduleRegister[name] = modInit
+	moduleSchemas[name] = schema
 }
@@ -10,49 +10,65 @@ import (
 	"github.com/bwmarrin/discordgo"
 	"github.com/sirupsen/logrus"
 
-	"github.com/Luzifer/discord-community/pkg/attributestore"
-	"github.com/Luzifer/discord-community/pkg/helpers"
 	"github.com/Luzifer/discord-community/pkg/modules"
 	"github.com/Luzifer/discord-community/pkg/twitch"
 )
 
-/*
- * @module presence
- * @module_desc Updates the presence status of the bot to display the next stream
- */
-
 const (
 	presenceTimeDay = 24 * time.Hour
 )
 
-type modPresence struct {
-	attrs   attributestore.ModuleAttributeStore
-	discord *discordgo.Session
-	id      string
-}
+type (
+	modPresence struct {
+		cfg     moduleConfig
+		discord *discordgo.Session
+		id      string
+		twitch  *twitch.Adapter
+	}
+
+	moduleConfig struct {
+		Cron               string        `attr:"cron" default:"* * * * *"`
+		FallbackText       string        `attr:"fallback_text" required:"true"`
+		SchedulePastTime   time.Duration `attr:"schedule_past_time" default:"15m"`
+		TwitchChannelID    string        `attr:"twitch_channel_id" required:"true"`
+		TwitchClientID     string        `attr:"twitch_client_id"`
+		TwitchClientSecret string        `attr:"twitch_client_secret"`
+	}
+)
+
+var moduleSchema = modules.NewSchema(
+	"Updates the presence status of the bot to display the next stream",
+	moduleConfig{},
+	map[string]string{
+		"cron":                 "When to execute the module",
+		"fallback_text":        "What to set the text to when no stream is found (`playing <text>`)",
+		"schedule_past_time":   "How long in the past should the schedule contain an entry",
+		"twitch_channel_id":    "ID (not name) of the channel to fetch the schedule from",
+		"twitch_client_id":     "Twitch client ID overriding the one of the top-level `twitch` section",
+		"twitch_client_secret": "Secret for the Twitch app identified with twitch_client_id (required when overriding twitch_client_id)",
+	},
+)
 
 func init() {
-	modules.RegisterModule("presence", func() modules.Module { return &modPresence{} })
+	modules.RegisterModule("presence", func() modules.Module { return &modPresence{} }, moduleSchema)
 }
 
 func (m modPresence) ID() string { return m.id }
 
 func (m *modPresence) Initialize(args modules.ModuleInitArgs) error {
-	m.attrs = args.Attrs
 	m.discord = args.Discord
 	m.id = args.ID
 
-	if err := m.attrs.Expect(
-		"fallback_text",
-		"twitch_channel_id",
-		"twitch_client_id",
-		"twitch_client_secret",
-	); err != nil {
-		return fmt.Errorf("validating attributes: %w", err)
+	if err := args.Attrs.Decode(&m.cfg); err != nil {
+		return fmt.Errorf("decoding attributes: %w", err)
+	}
+
+	var err error
+	if m.twitch, err = args.TwitchAdapter(m.cfg.TwitchClientID, m.cfg.TwitchClientSecret); err != nil {
+		return fmt.Errorf("getting twitch adapter: %w", err)
 	}
 
-	// @attr cron optional string "* * * * *" When to execute the module
-	if _, err := args.Crontab.AddFunc(m.attrs.MustString("cron", new("* * * * *")), m.cronUpdatePresence); err != nil {
+	if err := args.AddCronFunc(m.cfg.Cron, m.cronUpdatePresence); err != nil {
 		return fmt.Errorf("adding cron function: %w", err)
 	}
 
@@ -61,30 +77,18 @@ func (m *modPresence) Initialize(args modules.ModuleInitArgs) error {
 
 func (modPresence) Setup() error { return nil }
 
-func (m modPresence) cronUpdatePresence() {
+func (m modPresence) cronUpdatePresence() error {
 	var nextStream *time.Time
 
-	t := twitch.New(
-		// @attr twitch_client_id required string "" Twitch client ID the token was issued for
-		m.attrs.MustString("twitch_client_id", nil),
-		// @attr twitch_client_secret required string "" Secret for the Twitch app identified with twitch_client_id
-		m.attrs.MustString("twitch_client_secret", nil),
-		"", // No User-Token used
-	)
-
-	data, err := t.GetChannelStreamSchedule(
+	for seg, err := range m.twitch.ScheduleSegments(
 		context.Background(),
-		// @attr twitch_channel_id required string "" ID (not name) of the channel to fetch the schedule from
-		m.attrs.MustString("twitch_channel_id", nil),
-		// @attr schedule_past_time optional duration "15m" How long in the past should the schedule contain an entry
-		new(time.Now().Add(-m.attrs.MustDuration("schedule_past_time", helpers.DefaultStreamSchedulePastTime))),
-	)
-	if err != nil {
-		logrus.WithError(err).Error("Unable to fetch stream schedule")
-		return
-	}
+		m.cfg.TwitchChannelID,
+		new(time.Now().Add(-m.cfg.SchedulePastTime)),
+	) {
+		if err != nil {
+			return fmt.Errorf("fetching stream schedule: %w", err)
+		}
 
-	for _, seg := range data.Data.Segments {
 		if seg.StartTime == nil || seg.CanceledUntil != nil {
 			continue
 		}
@@ -97,17 +101,17 @@ func (m modPresence) cronUpdatePresence() {
 		break
 	}
 
-	// @attr fallback_text required string "" What to set the text to when no stream is found (`playing <text>`)
-	status := m.attrs.MustString("fallback_text", nil)
+	status := m.cfg.FallbackText
 	if nextStream != nil {
 		status = m.durationToHumanReadable(time.Since(*nextStream))
 	}
 
 	if err := m.discord.UpdateGameStatus(0, status); err != nil {
-		logrus.WithError(err).Error("Unable to update status")
+		return fmt.Errorf("updating status: %w", err)
 	}
 
 	logrus.Debug("Updated presence")
+	return nil
 }
 
 func (modPresence) durationToHumanReadable(d time.Duration) string {
@@ -7,7 +7,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/Luzifer/go_helpers/env"
 	"github.com/bwmarrin/discordgo"
 	"github.com/sirupsen/logrus"
 
@@ -17,41 +16,68 @@ import (
 	"github.com/Luzifer/discord-community/pkg/modules"
 )
 
-/*
- * @module reactionrole
- * @module_desc Creates a post with pre-set reactions and assigns roles on reaction
- */
-
-type modReactionRole struct {
-	attrs   attributestore.ModuleAttributeStore
-	discord *discordgo.Session
-	id      string
-	config  *config.File
-	store   *modules.MetaStore
-}
+type (
+	modReactionRole struct {
+		cfg     moduleConfig
+		discord *discordgo.Session
+		id      string
+		config  *config.File
+		store   *modules.MetaStore
+	}
+
+	moduleConfig struct {
+		ChannelID            string               `attr:"discord_channel_id" required:"true"`
+		Content              string               `attr:"content"`
+		EmbedColor           attributestore.Color `attr:"embed_color" default:"0x2ECC71"`
+		EmbedDescription     string               `attr:"embed_description"`
+		EmbedThumbnailHeight int64                `attr:"embed_thumbnail_height"`
+		EmbedThumbnailURL    string               `attr:"embed_thumbnail_url"`
+		EmbedThumbnailWidth  int64                `attr:"embed_thumbnail_width"`
+		EmbedTitle           string               `attr:"embed_title"`
+		ReactionRoles        []reactionRole       `attr:"reaction_roles" required:"true"`
+	}
+
+	reactionRole struct {
+		Emote   string `attr:"emote" required:"true"`
+		RoleID  string `attr:"role_id" required:"true"`
+		SetOnly bool   `attr:"set_only"`
+	}
+)
+
+var moduleSchema = modules.NewSchema(
+	"Creates a post with pre-set reactions and assigns roles on reaction",
+	moduleConfig{},
+	map[string]string{
+		"content":                "Message content to post above the embed",
+		"discord_channel_id":     "ID of the Discord channel to post the message to",
+		"embed_color":            "Integer / HEX representation of the color for the embed (`0x2ECC71` or `\"#2ECC71\"`)",
+		"embed_description":      "Description for the embed block",
+		"embed_thumbnail_height": "Height of the thumbnail",
+		"embed_thumbnail_url":    "Publically hosted image URL to use as thumbnail",
+		"embed_thumbnail_width":  "Width of the thumbnail",
+		"embed_title":            "Title of the embed (embed will not be added when title is missing)",
+		"reaction_roles":         "List of objects with `emote`, `role_id` and optional `set_only` keys. `emote` equals an unicode emote (✅) or a custom emote in form `:<emote-name>:<emote-id>`. `role_id` is the integer ID of the guilds role to add with this emote. If `set_only` is `true`, the role will only be added but not removed when the reaction is removed. The legacy format `emote=role-id[:set]` is still supported for list entries.",
+	},
+)
 
 func init() {
-	modules.RegisterModule("reactionrole", func() modules.Module { return &modReactionRole{} })
+	modules.RegisterModule("reactionrole", func() modules.Module { return &modReactionRole{} }, moduleSchema)
 }
 
 func (m modReactionRole) ID() string { return m.id }
 
 func (m *modReactionRole) Initialize(args modules.ModuleInitArgs) error {
-	m.attrs = args.Attrs
 	m.discord = args.Discord
 	m.id = args.ID
 	m.store = args.Store
 	m.config = args.Config
 
-	if err := m.attrs.Expect(
-		"discord_channel_id",
-		"reaction_roles",
-	); err != nil {
-		return fmt.Errorf("validating attributes: %w", err)
+	if err := args.Attrs.Decode(&m.cfg); err != nil {
+		return fmt.Errorf("decoding attributes: %w", err)
 	}
 
-	m.discord.AddHandler(m.handleMessageReactionAdd)
-	m.discord.AddHandler(m.handleMessageReactionRemove)
+	args.AddHandler(m.handleMessageReactionAdd)
+	args.AddHandler(m.handleMessageReactionRemove)
 
 	return nil
 }
@@ -60,44 +86,32 @@ func (m *modReactionRole) Initialize(args modules.ModuleInitArgs) error {
 func (m modReactionRole) Setup() error {
 	var err error
 
-	// @attr discord_channel_id required string "" ID of the Discord channel to post the message to
-	channelID := m.attrs.MustString("discord_channel_id", nil)
+	channelID := m.cfg.ChannelID
 
-	// @attr content optional string "" Message content to post above the embed
-	contentString := m.attrs.MustString("content", new(""))
+	contentString := m.cfg.Content
 
 	var msgEmbed *discordgo.MessageEmbed
-	// @attr embed_title optional string "" Title of the embed (embed will not be added when title is missing)
-	if title := m.attrs.MustString("embed_title", new("")); title != "" {
+	if m.cfg.EmbedTitle != "" {
 		msgEmbed = &discordgo.MessageEmbed{
-			// @attr embed_color optional int64 "0x2ECC71" Integer / HEX representation of the color for the embed
-			Color: int(m.attrs.MustInt64("embed_color", helpers.StreamScheduleDefaultColor)),
-			// @attr embed_description optional string "" Description for the embed block
-			Description: strings.TrimSpace(m.attrs.MustString("embed_description", new(""))),
+			Color:       int(m.cfg.EmbedColor),
+			Description: strings.TrimSpace(m.cfg.EmbedDescription),
 			Timestamp:   time.Now().Format(time.RFC3339),
-			Title:       title,
+			Title:       m.cfg.EmbedTitle,
 			Type:        discordgo.EmbedTypeRich,
 		}
 
-		if m.attrs.MustString("embed_thumbnail_url", new("")) != "" {
+		if m.cfg.EmbedThumbnailURL != "" {
 			msgEmbed.Thumbnail = &discordgo.MessageEmbedThumbnail{
-				// @attr embed_thumbnail_url optional string "" Publically hosted image URL to use as thumbnail
-				URL: m.attrs.MustString("embed_thumbnail_url", new("")),
-				// @attr embed_thumbnail_width optional int64 "" Width of the thumbnail
-				Width: int(m.attrs.MustInt64("embed_thumbnail_width", new(int64(0)))),
-				// @attr embed_thumbnail_height optional int64 "" Height of the thumbnail
-				Height: int(m.attrs.MustInt64("embed_thumbnail_height", new(int64(0)))),
+				URL:    m.cfg.EmbedThumbnailURL,
+				Width:  int(m.cfg.EmbedThumbnailWidth),
+				Height: int(m.cfg.EmbedThumbnailHeight),
 			}
 		}
 	}
 
-	reactionListRaw, err := m.attrs.StringSlice("reaction_roles")
-	if err != nil {
-		return fmt.Errorf("getting role list: %w", err)
-	}
 	var reactionList []string
-	for _, r := range reactionListRaw {
-		reactionList = append(reactionList, strings.Split(r, "=")[0])
+	for _, r := range m.cfg.ReactionRoles {
+		reactionList = append(reactionList, r.Emote)
 	}
 
 	var managedMsg *discordgo.Message
@@ -177,14 +191,14 @@ func (m modReactionRole) Setup() error {
 	return nil
 }
 
-func (m modReactionRole) extractRoles() (map[string]string, error) {
-	// @attr reaction_roles required []string "" List of strings in format `emote=role-id[:set]`. `emote` equals an unicode emote (✅) or a custom emote in form `:<emote-name>:<emote-id>`. `role-id` is the integer ID of the guilds role to add with this emote. If `:set` is added at the end, the role will only be added but not removed when the reaction is removed.
-	list, err := m.attrs.StringSlice("reaction_roles")
-	if err != nil {
-		return nil, fmt.Errorf("getting role list: %w", err)
+func (m modReactionRole) getReactionRole(emote string) (reactionRole, bool) {
+	for _, r := range m.cfg.ReactionRoles {
+		if r.Emote == emote {
+			return r, true
+		}
 	}
 
-	return env.ListToMap(list), nil
+	return reactionRole{}, false
 }
 
 //revive:disable-next-line:flag-parameter // not a flag, just telling whether a reaction was added or removed
@@ -215,27 +229,21 @@ func (m modReactionRole) handleMessageReaction(_ *discordgo.Session, e *discordg
 		return
 	}
 
-	roles, err := m.extractRoles()
-	if err != nil {
-		logrus.WithError(err).Error("Unable to extract role mapping")
-		return
-	}
-
 	for _, check := range []string{e.Emoji.Name, fmt.Sprintf(":%s:%s", e.Emoji.Name, e.Emoji.ID)} {
-		role, ok := roles[check]
+		role, ok := m.getReactionRole(check)
 		if !ok {
 			continue
 		}
 
 		if add {
-			if err = m.discord.GuildMemberRoleAdd(m.config.GuildID, e.UserID, strings.Split(role, ":")[0]); err != nil {
+			if err = m.discord.GuildMemberRoleAdd(m.config.GuildID, e.UserID, role.RoleID); err != nil {
 				logrus.WithError(err).Error("Unable to add role to user")
 			}
 			return
 		}
 
-		if !strings.HasSuffix(role, ":set") {
-			if err = m.discord.GuildMemberRoleRemove(m.config.GuildID, e.UserID, strings.Split(role, ":")[0]); err != nil {
+		if !role.SetOnly {
+			if err = m.discord.GuildMemberRoleRemove(m.config.GuildID, e.UserID, role.RoleID); err != nil {
 				logrus.WithError(err).Error("Unable to remove role to user")
 			}
 			return
@@ -250,3 +258,32 @@ func (m modReactionRole) handleMessageReactionAdd(d *discordgo.Session, e *disco
 func (m modReactionRole) handleMessageReactionRemove(d *discordgo.Session, e *discordgo.MessageReactionRemove) {
 	m.handleMessageReaction(d, e.MessageReaction, false)
 }
+
+// UnmarshalAttribute implements the attributestore.Unmarshaler interface
+// to support the legacy `emote=role-id[:set]` format
+func (r *reactionRole) UnmarshalAttribute(value any) error {
+	if sv, ok := value.(string); ok {
+		emote, role, ok := strings.Cut(sv, "=")
+		if !ok || emote == "" || role == "" {
+			return fmt.Errorf("invalid reaction role %q, expected emote=role-id[:set]", sv)
+		}
+
+		r.Emote = emote
+		r.RoleID, r.SetOnly = strings.CutSuffix(role, ":set")
+		return nil
+	}
+
+	attrs, ok := value.(map[string]any)
+	if !ok {
+		return attributestore.ErrValueMismatch
+	}
+
+	// Use a type without the UnmarshalAttribute method to decode the
+	// struct fields without recursing into this method
+	type plainReactionRole reactionRole
+	if err := attributestore.ModuleAttributeStore(attrs).Decode((*plainReactionRole)(r)); err != nil {
+		return fmt.Errorf("decoding reaction role: %w", err)
+	}
+
+	return nil
+}
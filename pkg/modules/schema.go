@@ -0,0 +1,190 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/config"
+)
+
+type (
+	// Attribute describes a single attribute a module accepts in its
+	// configuration
+	Attribute struct {
+		// Name of the attribute, a placeholder in curly braces at the
+		// end of the name (e.g. `post_text_{username}`) matches any
+		// attribute having the prefix in front of the placeholder
+		Name        string
+		Type        string
+		Required    bool
+		Default     string
+		Description string
+	}
+
+	// Schema describes a module type and its attributes
+	Schema struct {
+		Description string
+		Attributes  []Attribute
+
+		configType reflect.Type
+	}
+)
+
+var (
+	// ErrInvalidAttribute signals an attribute has a value not matching
+	// the type defined in the Schema
+	ErrInvalidAttribute = errors.New("invalid attribute value")
+
+	// ErrMissingAttribute signals a required attribute is not set
+	ErrMissingAttribute = errors.New("missing required attribute")
+
+	// ErrUnknownAttribute signals an attribute is set which is not
+	// defined in the Schema
+	ErrUnknownAttribute = errors.New("unknown attribute")
+)
+
+// NewSchema creates a Schema from the struct the module decodes its
+// attributes into (see attributestore.ModuleAttributeStore.Decode)
+// and the descriptions of those attributes. It panics when attributes
+// and descriptions do not match as this is a programming error.
+func NewSchema(description string, cfg any, attrDescriptions map[string]string) Schema {
+	s := Schema{
+		Description: description,
+		configType:  reflect.TypeOf(cfg),
+	}
+
+	for _, f := range attributestore.Fields(cfg) {
+		desc, ok := attrDescriptions[f.Name]
+		if !ok {
+			panic(fmt.Errorf("missing description for attribute %q", f.Name))
+		}
+
+		s.Attributes = append(s.Attributes, Attribute{
+			Name:        f.Name,
+			Type:        f.Type,
+			Required:    f.Required,
+			Default:     f.Default,
+			Description: desc,
+		})
+	}
+
+	if len(s.Attributes) != len(attrDescriptions) {
+		panic(errors.New("descriptions given for unknown attributes"))
+	}
+
+	return s
+}
+
+// GetModuleNames returns the sorted names of all registered modules
+func GetModuleNames() []string {
+	moduleRegisterLock.RLock()
+	defer moduleRegisterLock.RUnlock()
+
+	var names []string
+	for name := range moduleRegister {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// GetModuleSchema returns the Schema the module with the given name
+// was registered with
+func GetModuleSchema(name string) (Schema, bool) {
+	moduleRegisterLock.RLock()
+	defer moduleRegisterLock.RUnlock()
+
+	s, ok := moduleSchemas[name]
+	return s, ok
+}
+
+// ValidateModuleConfig checks the module type is registered and
+// validates the module attributes against its Schema
+func ValidateModuleConfig(mc config.ModuleConfig) []error {
+	schema, ok := GetModuleSchema(mc.Type)
+	if !ok {
+		return []error{fmt.Errorf("unsupported module type %q", mc.Type)}
+	}
+
+	return schema.Validate(mc.Attributes)
+}
+
+// SortedAttributes returns the attributes of the Schema with required
+// attributes first, each group sorted by name
+func (s Schema) SortedAttributes() []Attribute {
+	out := slices.Clone(s.Attributes)
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Required != out[j].Required {
+			return out[i].Required
+		}
+		return out[i].Name < out[j].Name
+	})
+
+	return out
+}
+
+// Validate checks the given attributes against the Schema and returns
+// one error for each problem found: unknown keys, missing required keys
+// and values not matching the expected type
+func (s Schema) Validate(attrs attributestore.ModuleAttributeStore) (errs []error) {
+	var keys []string
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !s.hasAttribute(k) {
+			errs = append(errs, fmt.Errorf("%w %q", ErrUnknownAttribute, k))
+		}
+	}
+
+	if s.configType == nil {
+		return errs
+	}
+
+	err := attrs.Decode(reflect.New(s.configType).Interface())
+	if err == nil {
+		return errs
+	}
+
+	decodeErrs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		decodeErrs = joined.Unwrap()
+	}
+
+	for _, err := range decodeErrs {
+		var ae attributestore.AttributeError
+		switch {
+		case !errors.As(err, &ae):
+			errs = append(errs, err)
+		case errors.Is(ae.Err, attributestore.ErrValueNotSet):
+			errs = append(errs, fmt.Errorf("%w %q", ErrMissingAttribute, ae.Name))
+		default:
+			errs = append(errs, fmt.Errorf("%w %q: %w", ErrInvalidAttribute, ae.Name, ae.Err))
+		}
+	}
+
+	return errs
+}
+
+func (s Schema) hasAttribute(key string) bool {
+	for _, attr := range s.Attributes {
+		if attr.Name == key {
+			return true
+		}
+
+		if prefix, _, ok := strings.Cut(attr.Name, "{"); ok && strings.HasSuffix(attr.Name, "}") && strings.HasPrefix(key, prefix) && len(key) > len(prefix) {
+			return true
+		}
+	}
+
+	return false
+}
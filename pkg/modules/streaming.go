@@ -0,0 +1,51 @@
+package modules
+
+import (
+	"maps"
+
+	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/streaming"
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+// StreamingProviders returns the providers of the configured streaming
+// platforms using the given Adapter (see TwitchAdapter) for Twitch
+func (a ModuleInitArgs) StreamingProviders(twitchAdapter *twitch.Adapter) streaming.Providers {
+	return a.manager.StreamingProviders(twitchAdapter)
+}
+
+// SetStreamingConfig creates the providers for YouTube and Kick shared
+// by all modules from the top-level youtube and kick sections, it needs
+// to be called before the modules are initialized
+func (m *Manager) SetStreamingConfig(yt config.YouTubeConfig, kick config.KickConfig) {
+	m.streamingLock.Lock()
+	defer m.streamingLock.Unlock()
+
+	m.streamingProviders = make(streaming.Providers)
+
+	if yt.APIKey != "" {
+		m.streamingProviders[streaming.PlatformYouTube] = streaming.NewYouTubeProvider(yt.APIKey)
+	}
+
+	if kick.ClientID != "" {
+		m.streamingProviders[streaming.PlatformKick] = streaming.NewKickProvider(kick.ClientID, kick.ClientSecret)
+	}
+}
+
+// StreamingProviders returns the providers for YouTube and Kick if
+// configured and a provider for Twitch using the given Adapter
+func (m *Manager) StreamingProviders(twitchAdapter *twitch.Adapter) streaming.Providers {
+	m.streamingLock.Lock()
+	defer m.streamingLock.Unlock()
+
+	providers := maps.Clone(m.streamingProviders)
+	if providers == nil {
+		providers = make(streaming.Providers)
+	}
+
+	if twitchAdapter != nil {
+		providers[streaming.PlatformTwitch] = streaming.NewTwitchProvider(twitchAdapter)
+	}
+
+	return providers
+}
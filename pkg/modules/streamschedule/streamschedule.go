@@ -19,41 +19,81 @@ import (
 	"github.com/Luzifer/discord-community/pkg/twitch"
 )
 
-/*
- * @module schedule
- * @module_desc Posts stream schedule derived from Twitch schedule as embed in Discord channel
- */
-
-type modStreamSchedule struct {
-	attrs   attributestore.ModuleAttributeStore
-	discord *discordgo.Session
-	id      string
-	store   *modules.MetaStore
-}
+type (
+	modStreamSchedule struct {
+		cfg     moduleConfig
+		discord *discordgo.Session
+		id      string
+		store   *modules.MetaStore
+		twitch  *twitch.Adapter
+	}
+
+	moduleConfig struct {
+		ChannelID            string               `attr:"discord_channel_id" required:"true"`
+		Content              string               `attr:"content"`
+		Cron                 string               `attr:"cron" default:"*/10 * * * *"`
+		EmbedColor           attributestore.Color `attr:"embed_color" default:"0x2ECC71"`
+		EmbedDescription     string               `attr:"embed_description"`
+		EmbedThumbnailHeight int64                `attr:"embed_thumbnail_height"`
+		EmbedThumbnailURL    string               `attr:"embed_thumbnail_url"`
+		EmbedThumbnailWidth  int64                `attr:"embed_thumbnail_width"`
+		EmbedTitle           string               `attr:"embed_title"`
+		Locale               string               `attr:"locale" default:"en_US"`
+		ScheduleEntries      int64                `attr:"schedule_entries" default:"5"`
+		SchedulePastTime     time.Duration        `attr:"schedule_past_time" default:"15m"`
+		TimeFormat           string               `attr:"time_format" default:"%b %d, %Y %I:%M %p"`
+		Timezone             *time.Location       `attr:"timezone" default:"UTC"`
+		TwitchChannelID      string               `attr:"twitch_channel_id" required:"true"`
+		TwitchClientID       string               `attr:"twitch_client_id"`
+		TwitchClientSecret   string               `attr:"twitch_client_secret"`
+	}
+)
+
+var moduleSchema = modules.NewSchema(
+	"Posts stream schedule derived from Twitch schedule as embed in Discord channel",
+	moduleConfig{},
+	map[string]string{
+		"content":                "Message content to post above the embed - Allows Go templating, make sure to proper escape the template strings. See [here](https://github.com/Luzifer/discord-community/blob/5f004fdab066f16580f41076a4e6d8668fe743c9/twitch.go#L53-L71) for available data object.",
+		"cron":                   "When to execute the schedule transfer",
+		"discord_channel_id":     "ID of the Discord channel to post the message to",
+		"embed_color":            "Integer / HEX representation of the color for the embed (`0x2ECC71` or `\"#2ECC71\"`)",
+		"embed_description":      "Description for the embed block",
+		"embed_thumbnail_height": "Height of the thumbnail",
+		"embed_thumbnail_url":    "Publically hosted image URL to use as thumbnail",
+		"embed_thumbnail_width":  "Width of the thumbnail",
+		"embed_title":            "Title of the embed (embed will not be added when title is missing)",
+		"locale":                 "Locale to translate the date to ([supported locales](https://github.com/goodsign/monday/blob/24c0b92f25dca51152defe82cefc7f7fc1c92009/locale.go#L9-L49))",
+		"schedule_entries":       "How many schedule entries to add to the embed as fields",
+		"schedule_past_time":     "How long in the past should the schedule contain an entry",
+		"time_format":            "Time format in [limited strftime format](https://github.com/Luzifer/discord-community/blob/master/pkg/modules/streamschedule/strftime.go) to use (e.g. `%a. %d.%m. %H:%M Uhr`)",
+		"timezone":               "Timezone to display the times in (e.g. `Europe/Berlin`)",
+		"twitch_channel_id":      "ID (not name) of the channel to fetch the schedule from",
+		"twitch_client_id":       "Twitch client ID overriding the one of the top-level `twitch` section",
+		"twitch_client_secret":   "Secret for the Twitch app identified with twitch_client_id (required when overriding twitch_client_id)",
+	},
+)
 
 func init() {
-	modules.RegisterModule("schedule", func() modules.Module { return &modStreamSchedule{} })
+	modules.RegisterModule("schedule", func() modules.Module { return &modStreamSchedule{} }, moduleSchema)
 }
 
 func (m modStreamSchedule) ID() string { return m.id }
 
 func (m *modStreamSchedule) Initialize(args modules.ModuleInitArgs) error {
-	m.attrs = args.Attrs
 	m.discord = args.Discord
 	m.id = args.ID
 	m.store = args.Store
 
-	if err := m.attrs.Expect(
-		"discord_channel_id",
-		"twitch_channel_id",
-		"twitch_client_id",
-		"twitch_client_secret",
-	); err != nil {
-		return fmt.Errorf("validating attributes: %w", err)
+	if err := args.Attrs.Decode(&m.cfg); err != nil {
+		return fmt.Errorf("decoding attributes: %w", err)
+	}
+
+	var err error
+	if m.twitch, err = args.TwitchAdapter(m.cfg.TwitchClientID, m.cfg.TwitchClientSecret); err != nil {
+		return fmt.Errorf("getting twitch adapter: %w", err)
 	}
 
-	// @attr cron optional string "*/10 * * * *" When to execute the schedule transfer
-	if _, err := args.Crontab.AddFunc(m.attrs.MustString("cron", new("*/10 * * * *")), m.cronUpdateSchedule); err != nil {
+	if err := args.AddCronFunc(m.cfg.Cron, m.cronUpdateSchedule); err != nil {
 		return fmt.Errorf("adding cron function: %w", err)
 	}
 
@@ -64,24 +104,19 @@ func (modStreamSchedule) Setup() error { return nil }
 
 func (m modStreamSchedule) assembleEmbed(data *twitch.StreamSchedule) *discordgo.MessageEmbed {
 	msgEmbed := &discordgo.MessageEmbed{
-		// @attr embed_color optional int64 "0x2ECC71" Integer / HEX representation of the color for the embed
-		Color: int(m.attrs.MustInt64("embed_color", helpers.StreamScheduleDefaultColor)),
-		// @attr embed_description optional string "" Description for the embed block
-		Description: strings.TrimSpace(m.attrs.MustString("embed_description", new(""))),
+		Color:       int(m.cfg.EmbedColor),
+		Description: strings.TrimSpace(m.cfg.EmbedDescription),
 		Fields:      nil,
 		Timestamp:   time.Now().Format(time.RFC3339),
-		Title:       m.attrs.MustString("embed_title", nil),
+		Title:       m.cfg.EmbedTitle,
 		Type:        discordgo.EmbedTypeRich,
 	}
 
-	if m.attrs.MustString("embed_thumbnail_url", new("")) != "" {
+	if m.cfg.EmbedThumbnailURL != "" {
 		msgEmbed.Thumbnail = &discordgo.MessageEmbedThumbnail{
-			// @attr embed_thumbnail_url optional string "" Publically hosted image URL to use as thumbnail
-			URL: m.attrs.MustString("embed_thumbnail_url", new("")),
-			// @attr embed_thumbnail_width optional int64 "" Width of the thumbnail
-			Width: int(m.attrs.MustInt64("embed_thumbnail_width", new(int64(0)))),
-			// @attr embed_thumbnail_height optional int64 "" Height of the thumbnail
-			Height: int(m.attrs.MustInt64("embed_thumbnail_height", new(int64(0)))),
+			URL:    m.cfg.EmbedThumbnailURL,
+			Width:  int(m.cfg.EmbedThumbnailWidth),
+			Height: int(m.cfg.EmbedThumbnailHeight),
 		}
 	}
 
@@ -111,8 +146,7 @@ func (m modStreamSchedule) assembleEmbed(data *twitch.StreamSchedule) *discordgo
 			Inline: false,
 		})
 
-		// @attr schedule_entries optional int64 "5" How many schedule entries to add to the embed as fields
-		if len(msgEmbed.Fields) == int(m.attrs.MustInt64("schedule_entries", helpers.DefaultStreamScheduleEntries)) {
+		if len(msgEmbed.Fields) == int(m.cfg.ScheduleEntries) {
 			break
 		}
 	}
@@ -120,42 +154,27 @@ func (m modStreamSchedule) assembleEmbed(data *twitch.StreamSchedule) *discordgo
 	return msgEmbed
 }
 
-func (m modStreamSchedule) cronUpdateSchedule() {
-	t := twitch.New(
-		// @attr twitch_client_id required string "" Twitch client ID the token was issued for
-		m.attrs.MustString("twitch_client_id", nil),
-		// @attr twitch_client_secret required string "" Secret for the Twitch app identified with twitch_client_id
-		m.attrs.MustString("twitch_client_secret", nil),
-		"", // No User-Token used
-	)
-
-	data, err := t.GetChannelStreamSchedule(
+func (m modStreamSchedule) cronUpdateSchedule() error {
+	data, err := m.twitch.GetChannelStreamSchedule(
 		context.Background(),
-		// @attr twitch_channel_id required string "" ID (not name) of the channel to fetch the schedule from
-		m.attrs.MustString("twitch_channel_id", nil),
-		// @attr schedule_past_time optional duration "15m" How long in the past should the schedule contain an entry
-		new(time.Now().Add(-m.attrs.MustDuration("schedule_past_time", helpers.DefaultStreamSchedulePastTime))),
+		m.cfg.TwitchChannelID,
+		new(time.Now().Add(-m.cfg.SchedulePastTime)),
 	)
 	if err != nil {
-		logrus.WithError(err).Error("Unable to fetch stream schedule")
-		return
+		return fmt.Errorf("fetching stream schedule: %w", err)
 	}
 
-	// @attr discord_channel_id required string "" ID of the Discord channel to post the message to
-	channelID := m.attrs.MustString("discord_channel_id", nil)
+	channelID := m.cfg.ChannelID
 
 	var msgEmbed *discordgo.MessageEmbed
-	// @attr embed_title optional string "" Title of the embed (embed will not be added when title is missing)
-	if m.attrs.MustString("embed_title", new("")) != "" {
+	if m.cfg.EmbedTitle != "" {
 		msgEmbed = m.assembleEmbed(data)
 	}
 
 	var contentString string
-	// @attr content optional string "" Message content to post above the embed - Allows Go templating, make sure to proper escape the template strings. See [here](https://github.com/Luzifer/discord-community/blob/5f004fdab066f16580f41076a4e6d8668fe743c9/twitch.go#L53-L71) for available data object.
-	if m.attrs.MustString("content", new("")) != "" {
+	if m.cfg.Content != "" {
 		if contentString, err = m.executeContentTemplate(data); err != nil {
-			logrus.WithError(err).Error("executing stream schedule template")
-			return
+			return fmt.Errorf("executing stream schedule template: %w", err)
 		}
 	}
 
@@ -172,8 +191,7 @@ func (m modStreamSchedule) cronUpdateSchedule() {
 
 		return nil
 	}); err != nil {
-		logrus.WithError(err).Error("Unable to fetch managed message for stream schedule")
-		return
+		return fmt.Errorf("getting managed message: %w", err)
 	}
 
 	if managedMsg != nil {
@@ -184,7 +202,7 @@ func (m modStreamSchedule) cronUpdateSchedule() {
 
 		if helpers.IsDiscordMessageEmbedEqual(oldEmbed, msgEmbed) && strings.TrimSpace(managedMsg.Content) == strings.TrimSpace(contentString) {
 			logrus.Debug("Stream Schedule is up-to-date")
-			return
+			return nil
 		}
 
 		_, err = m.discord.ChannelMessageEditComplex(&discordgo.MessageEdit{
@@ -201,16 +219,15 @@ func (m modStreamSchedule) cronUpdateSchedule() {
 		})
 	}
 	if err != nil {
-		logrus.WithError(err).Error("Unable to announce streamplan")
-		return
+		return fmt.Errorf("updating / creating message: %w", err)
 	}
 
 	if err = m.store.Set(m.id, "message_id", managedMsg.ID); err != nil {
-		logrus.WithError(err).Error("Unable to store managed message id")
-		return
+		return fmt.Errorf("storing managed message id: %w", err)
 	}
 
 	logrus.Info("Updated Stream Schedule")
+	return nil
 }
 
 func (m modStreamSchedule) executeContentTemplate(data *twitch.StreamSchedule) (string, error) {
@@ -219,7 +236,7 @@ func (m modStreamSchedule) executeContentTemplate(data *twitch.StreamSchedule) (
 
 	tpl, err := template.New("streamschedule").
 		Funcs(fns).
-		Parse(m.attrs.MustString("content", new("")))
+		Parse(m.cfg.Content)
 	if err != nil {
 		return "", fmt.Errorf("parsing template: %w", err)
 	}
@@ -232,17 +249,9 @@ func (m modStreamSchedule) executeContentTemplate(data *twitch.StreamSchedule) (
 }
 
 func (m modStreamSchedule) formatTime(t time.Time) string {
-	// @attr timezone optional string "UTC" Timezone to display the times in (e.g. `Europe/Berlin`)
-	tz, err := time.LoadLocation(m.attrs.MustString("timezone", new("UTC")))
-	if err != nil {
-		logrus.WithError(err).Fatal("Unable to load timezone")
-	}
-
 	return localeStrftime(
-		t.In(tz),
-		// @attr time_format optional string "%b %d, %Y %I:%M %p" Time format in [limited strftime format](https://github.com/Luzifer/discord-community/blob/master/strftime.go) to use (e.g. `%a. %d.%m. %H:%M Uhr`)
-		m.attrs.MustString("time_format", new("%b %d, %Y %I:%M %p")),
-		// @attr locale optional string "en_US" Locale to translate the date to ([supported locales](https://github.com/goodsign/monday/blob/24c0b92f25dca51152defe82cefc7f7fc1c92009/locale.go#L9-L49))
-		m.attrs.MustString("locale", new("en_US")),
+		t.In(m.cfg.Timezone),
+		m.cfg.TimeFormat,
+		m.cfg.Locale,
 	)
 }
@@ -0,0 +1,107 @@
+package modules
+
+import (
+	"errors"
+
+	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+var (
+	// ErrTwitchNotConfigured signals neither the top-level twitch
+	// section nor the module provides Twitch credentials
+	ErrTwitchNotConfigured = errors.New("no twitch credentials configured: set them in the twitch section or the module attributes")
+
+	// errTwitchPartialCredentials signals only one of client ID and
+	// secret was overridden
+	errTwitchPartialCredentials = errors.New("twitch client id and secret must be set together")
+)
+
+// TwitchAdapter returns the Adapter shared by all modules or, when the
+// module overrides the credentials, an Adapter for the given app
+// using the client settings of the top-level twitch section. Cached
+// tokens and rate limits are shared between all Adapters using the
+// same credentials.
+func (a ModuleInitArgs) TwitchAdapter(clientID, clientSecret string) (*twitch.Adapter, error) {
+	return a.manager.TwitchAdapter(clientID, clientSecret)
+}
+
+// SetTwitchConfig creates the Adapter shared by all modules from the
+// top-level twitch section, it needs to be called before the modules
+// are initialized
+func (m *Manager) SetTwitchConfig(cfg config.TwitchConfig) {
+	m.twitchLock.Lock()
+	defer m.twitchLock.Unlock()
+
+	m.twitchOptions = nil
+	if cfg.RequestTimeout > 0 {
+		m.twitchOptions = append(m.twitchOptions, twitch.WithRequestTimeout(cfg.RequestTimeout))
+	}
+	if cfg.Retries != nil {
+		m.twitchOptions = append(m.twitchOptions, twitch.WithRetries(*cfg.Retries))
+	}
+
+	m.twitchAdapter = nil
+	if cfg.ClientID != "" {
+		m.twitchAdapter = twitch.New(cfg.ClientID, cfg.ClientSecret, "", m.twitchOptions...)
+	}
+}
+
+// TwitchAdapter returns the Adapter configured through the top-level
+// twitch section when no credentials are given or a new Adapter using
+// the given credentials and the client settings of the twitch section
+func (m *Manager) TwitchAdapter(clientID, clientSecret string) (*twitch.Adapter, error) {
+	m.twitchLock.Lock()
+	defer m.twitchLock.Unlock()
+
+	if err := checkTwitchCredentials(m.twitchAdapter != nil, clientID, clientSecret); err != nil {
+		return nil, err
+	}
+
+	if clientID == "" {
+		return m.twitchAdapter, nil
+	}
+
+	return twitch.New(clientID, clientSecret, "", m.twitchOptions...), nil
+}
+
+// sharedTwitchAdapter returns the Adapter configured through the
+// top-level twitch section or nil
+func (m *Manager) sharedTwitchAdapter() *twitch.Adapter {
+	m.twitchLock.Lock()
+	defer m.twitchLock.Unlock()
+
+	return m.twitchAdapter
+}
+
+// ValidateTwitchCredentials checks a module using Twitch gets
+// credentials from either its attributes or the top-level twitch
+// section. Modules not using Twitch are not checked.
+func ValidateTwitchCredentials(tc config.TwitchConfig, mc config.ModuleConfig) error {
+	schema, ok := GetModuleSchema(mc.Type)
+	if !ok || !schema.hasAttribute("twitch_client_id") {
+		return nil
+	}
+
+	// Values not set or not being strings are reported by the schema
+	// validation
+	clientID, _ := mc.Attributes.String("twitch_client_id")
+	clientSecret, _ := mc.Attributes.String("twitch_client_secret")
+
+	return checkTwitchCredentials(tc.ClientID != "", clientID, clientSecret)
+}
+
+// checkTwitchCredentials ensures overridden credentials are complete
+// and credentials are available at all
+func checkTwitchCredentials(shared bool, clientID, clientSecret string) error {
+	switch {
+	case (clientID == "") != (clientSecret == ""):
+		return errTwitchPartialCredentials
+
+	case clientID == "" && !shared:
+		return ErrTwitchNotConfigured
+
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,133 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+// TwitchUserTokenStoreID is the ID the tokens of the Twitch users who
+// authorized the app are stored under in the MetaStore
+const TwitchUserTokenStoreID = "twitch-user-tokens"
+
+var (
+	// ErrTwitchAuthDisabled signals no Twitch app is configured for
+	// the authorization of users
+	ErrTwitchAuthDisabled = errors.New("twitch user authorization is not configured")
+
+	_ twitch.UserTokenStore = (*MetaStore)(nil)
+)
+
+// TwitchUserAdapter returns an Adapter making requests using the
+// token of the Twitch user with the given login. The user needs to
+// authorize the app through the authorization flow configured in the
+// config file, until then requests fail with
+// twitch.ErrUserTokenNotFound.
+func (a ModuleInitArgs) TwitchUserAdapter(login string) (*twitch.Adapter, error) {
+	return a.manager.twitchUserAdapter(login)
+}
+
+// DeleteUserToken removes the token of the Twitch user with the given
+// login
+func (m *MetaStore) DeleteUserToken(login string) error {
+	return m.Delete(TwitchUserTokenStoreID, strings.ToLower(login))
+}
+
+// GetUserToken implements the twitch.UserTokenStore interface
+func (m *MetaStore) GetUserToken(login string) (*twitch.UserToken, error) {
+	var token twitch.UserToken
+
+	if err := m.ReadWithLock(TwitchUserTokenStoreID, func(a attributestore.ModuleAttributeStore) error {
+		raw, err := a.String(strings.ToLower(login))
+		if errors.Is(err, attributestore.ErrValueNotSet) {
+			return twitch.ErrUserTokenNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("getting token: %w", err)
+		}
+
+		if err = json.Unmarshal([]byte(raw), &token); err != nil {
+			return fmt.Errorf("decoding token: %w", err)
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// SetUserToken implements the twitch.UserTokenStore interface
+func (m *MetaStore) SetUserToken(token twitch.UserToken) error {
+	// Stored encoded as the backends do not preserve the type
+	raw, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("encoding token: %w", err)
+	}
+
+	return m.Set(TwitchUserTokenStoreID, strings.ToLower(token.UserLogin), string(raw))
+}
+
+// UserTokens returns the tokens of all Twitch users who authorized the
+// app sorted by login
+func (m *MetaStore) UserTokens() ([]twitch.UserToken, error) {
+	var logins []string
+
+	if err := m.ReadWithLock(TwitchUserTokenStoreID, func(a attributestore.ModuleAttributeStore) error {
+		for login := range a {
+			logins = append(logins, login)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	slices.Sort(logins)
+
+	tokens := make([]twitch.UserToken, 0, len(logins))
+	for _, login := range logins {
+		token, err := m.GetUserToken(login)
+		if err != nil {
+			return nil, fmt.Errorf("getting token of %s: %w", login, err)
+		}
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, nil
+}
+
+// SetTwitchUserAuth configures the Adapter of the Twitch app the users
+// authorize, it needs to be called before the modules are initialized
+func (m *Manager) SetTwitchUserAuth(adapter *twitch.Adapter) {
+	m.twitchLock.Lock()
+	defer m.twitchLock.Unlock()
+
+	m.twitchAuthAdapter = adapter
+}
+
+// twitchUserAdapter returns an Adapter using the token source of the
+// user, the token sources are shared so concurrent refreshes of the
+// same token are prevented
+func (m *Manager) twitchUserAdapter(login string) (*twitch.Adapter, error) {
+	m.twitchLock.Lock()
+	defer m.twitchLock.Unlock()
+
+	if m.twitchAuthAdapter == nil {
+		return nil, ErrTwitchAuthDisabled
+	}
+
+	login = strings.ToLower(login)
+	src, ok := m.twitchUserTokens[login]
+	if !ok {
+		src = twitch.NewUserTokenSource(m.twitchAuthAdapter, m.store, login)
+		m.twitchUserTokens[login] = src
+	}
+
+	return m.twitchAuthAdapter.With(twitch.WithUserTokenSource(src)), nil
+}
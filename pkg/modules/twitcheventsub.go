@@ -0,0 +1,150 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+type (
+	eventSubRoute struct {
+		handler   twitch.EventSubHandler
+		logins    []string
+		resources *instanceResources
+	}
+
+	registeredEventSubHandler struct {
+		handler twitch.EventSubHandler
+		logins  []string
+	}
+)
+
+// AddTwitchEventSubHandler registers the handler for the EventSub
+// notifications of the given Twitch users received through the
+// webhook configured in the config file. Subscriptions to the
+// stream.online and stream.offline events of the users are reconciled
+// during Setup. The handler is removed when the module is torn down.
+func (a ModuleInitArgs) AddTwitchEventSubHandler(logins []string, handler twitch.EventSubHandler) {
+	lower := make([]string, 0, len(logins))
+	for _, login := range logins {
+		lower = append(lower, strings.ToLower(login))
+	}
+
+	a.resources.eventSubHandler = &registeredEventSubHandler{handler: handler, logins: lower}
+}
+
+// TwitchEventSubActive reports whether the stream.online and
+// stream.offline notifications of the Twitch user are received
+// through the EventSub webhook
+func (a ModuleInitArgs) TwitchEventSubActive(login string) bool {
+	return a.manager.twitchEventSubActive(login)
+}
+
+// HandleTwitchEventSub passes the notification to the handlers of all
+// modules registered for the broadcaster of the event
+func (m *Manager) HandleTwitchEventSub(ctx context.Context, n twitch.EventSubNotification) {
+	var evt struct {
+		BroadcasterUserLogin string `json:"broadcaster_user_login"`
+	}
+
+	if err := n.Decode(&evt); err != nil {
+		logrus.WithError(err).WithField("type", n.Subscription.Type).Error("decoding EventSub notification")
+		return
+	}
+
+	var routes []eventSubRoute
+
+	m.routeLock.RLock()
+	for _, r := range m.eventSubRoutes {
+		if slices.Contains(r.logins, strings.ToLower(evt.BroadcasterUserLogin)) {
+			r.resources.running.Add(1)
+			routes = append(routes, r)
+		}
+	}
+	m.routeLock.RUnlock()
+
+	for _, r := range routes {
+		r.handler(ctx, n)
+		r.resources.running.Done()
+	}
+}
+
+// SetTwitchEventSubWebhook configures the webhook to manage the
+// EventSub subscriptions of the modules with and the Adapter to look
+// up the Twitch users. It needs to be called before the modules are
+// set up.
+func (m *Manager) SetTwitchEventSubWebhook(adapter *twitch.Adapter, webhook *twitch.EventSubWebhook) {
+	m.routeLock.Lock()
+	defer m.routeLock.Unlock()
+
+	m.eventSubAdapter = adapter
+	m.eventSubWebhook = webhook
+}
+
+// reconcileTwitchEventSub looks up the Twitch users registered by the
+// modules and reconciles the webhook subscriptions for them
+func (m *Manager) reconcileTwitchEventSub(ctx context.Context) error {
+	var logins []string
+
+	m.routeLock.RLock()
+	adapter, webhook := m.eventSubAdapter, m.eventSubWebhook
+	for _, r := range m.eventSubRoutes {
+		for _, login := range r.logins {
+			if !slices.Contains(logins, login) {
+				logins = append(logins, login)
+			}
+		}
+	}
+	m.routeLock.RUnlock()
+
+	if webhook == nil {
+		return nil
+	}
+
+	var (
+		subs    []twitch.EventSubSubscription
+		userIDs = make(map[string]string)
+	)
+
+	for user, err := range adapter.Users(ctx, logins...) {
+		if err != nil {
+			return fmt.Errorf("fetching twitch user details: %w", err)
+		}
+
+		userIDs[strings.ToLower(user.Login)] = user.ID
+		subs = append(subs,
+			twitch.ChannelUpdateSubscription(user.ID),
+			twitch.StreamOfflineSubscription(user.ID),
+			twitch.StreamOnlineSubscription(user.ID),
+		)
+	}
+
+	m.routeLock.Lock()
+	m.eventSubUserIDs = userIDs
+	m.routeLock.Unlock()
+
+	if err := webhook.Reconcile(ctx, subs); err != nil {
+		return fmt.Errorf("reconciling subscriptions: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) twitchEventSubActive(login string) bool {
+	m.routeLock.RLock()
+	id, ok := m.eventSubUserIDs[strings.ToLower(login)]
+	webhook := m.eventSubWebhook
+	m.routeLock.RUnlock()
+
+	if !ok || webhook == nil {
+		return false
+	}
+
+	return webhook.IsActive(twitch.StreamOnlineSubscription(id)) &&
+		webhook.IsActive(twitch.StreamOfflineSubscription(id))
+}
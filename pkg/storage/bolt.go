@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+)
+
+const (
+	boltFileMode    = 0o600
+	boltOpenTimeout = 5 * time.Second
+)
+
+// BoltBackend stores the attributes in an embedded bbolt database
+// using one bucket per module and JSON encoded values
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+var _ Backend = (*BoltBackend)(nil)
+
+// NewBoltBackend opens or creates the bbolt database at the given path
+func NewBoltBackend(filename string) (*BoltBackend, error) {
+	db, err := bolt.Open(filename, boltFileMode, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt database: %w", err)
+	}
+
+	return &BoltBackend{db: db}, nil
+}
+
+// Close implements the Backend interface
+func (b *BoltBackend) Close() error {
+	if err := b.db.Close(); err != nil {
+		return fmt.Errorf("closing bolt database: %w", err)
+	}
+
+	return nil
+}
+
+// Delete implements the Backend interface
+func (b *BoltBackend) Delete(moduleID, key string) error {
+	if err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(moduleID))
+		if bucket == nil {
+			return nil
+		}
+
+		if err := bucket.Delete([]byte(key)); err != nil {
+			return fmt.Errorf("deleting key: %w", err)
+		}
+
+		if k, _ := bucket.Cursor().First(); k == nil {
+			// Last key was removed, drop the module
+			return tx.DeleteBucket([]byte(moduleID)) //nolint:wrapcheck // Wrapped outside the transaction
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("updating bolt database: %w", err)
+	}
+
+	return nil
+}
+
+// Get implements the Backend interface
+func (b *BoltBackend) Get(moduleID string) (attributestore.ModuleAttributeStore, error) {
+	out := attributestore.ModuleAttributeStore{}
+
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(moduleID))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var value any
+			if err := json.Unmarshal(v, &value); err != nil {
+				return fmt.Errorf("decoding value of %q: %w", k, err)
+			}
+
+			out[string(k)] = value
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("reading bolt database: %w", err)
+	}
+
+	return out, nil
+}
+
+// Modules implements the Backend interface
+func (b *BoltBackend) Modules() ([]string, error) {
+	var out []string
+
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			out = append(out, string(name))
+			return nil
+		})
+	}); err != nil {
+		return nil, fmt.Errorf("reading bolt database: %w", err)
+	}
+
+	return out, nil
+}
+
+// Set implements the Backend interface
+func (b *BoltBackend) Set(moduleID, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encoding value: %w", err)
+	}
+
+	if err = b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(moduleID))
+		if err != nil {
+			return fmt.Errorf("creating module bucket: %w", err)
+		}
+
+		return bucket.Put([]byte(key), data) //nolint:wrapcheck // Wrapped outside the transaction
+	}); err != nil {
+		return fmt.Errorf("updating bolt database: %w", err)
+	}
+
+	return nil
+}
+
+// migrateFrom imports the given JSON store into the database when the
+// database does not contain any data yet
+func (b *BoltBackend) migrateFrom(filename string) error {
+	moduleIDs, err := b.Modules()
+	if err != nil {
+		return err
+	}
+
+	if len(moduleIDs) > 0 {
+		// Database already contains data, migration is done
+		return nil
+	}
+
+	data, err := readDumpFile(filename)
+	if err != nil {
+		return fmt.Errorf("reading store to migrate: %w", err)
+	}
+
+	// Import within one transaction to not end up with a partially
+	// migrated database which would prevent the next migration attempt
+	if err = b.db.Update(func(tx *bolt.Tx) error {
+		for id, attrs := range data.ModuleAttributes {
+			bucket, err := tx.CreateBucketIfNotExists([]byte(id))
+			if err != nil {
+				return fmt.Errorf("creating module bucket: %w", err)
+			}
+
+			for k, v := range attrs {
+				value, err := json.Marshal(v)
+				if err != nil {
+					return fmt.Errorf("encoding value of %s/%s: %w", id, k, err)
+				}
+
+				if err = bucket.Put([]byte(k), value); err != nil {
+					return fmt.Errorf("storing value of %s/%s: %w", id, k, err)
+				}
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("importing store to migrate: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"modules": len(data.ModuleAttributes),
+		"source":  filename,
+	}).Info("Migrated store into bolt database")
+
+	return nil
+}
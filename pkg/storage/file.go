@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+)
+
+// FileBackend keeps all data in memory and writes it into a JSON file
+// on every change. The file is replaced atomically so a crash while
+// writing leaves the previous state intact.
+type FileBackend struct {
+	data     Dump
+	filename string
+	lock     sync.RWMutex
+}
+
+var _ Backend = (*FileBackend)(nil)
+
+// NewFileBackend reads the stored data from the given file and returns
+// a new FileBackend holding that data. A missing or empty file results
+// in an empty store.
+func NewFileBackend(filename string) (*FileBackend, error) {
+	out := &FileBackend{filename: filename}
+
+	data, err := readDumpFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	out.data = data
+
+	return out, nil
+}
+
+// Close implements the Backend interface
+func (*FileBackend) Close() error { return nil }
+
+// Delete implements the Backend interface
+func (f *FileBackend) Delete(moduleID, key string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if _, ok := f.data.ModuleAttributes[moduleID][key]; !ok {
+		return nil
+	}
+
+	delete(f.data.ModuleAttributes[moduleID], key)
+	if len(f.data.ModuleAttributes[moduleID]) == 0 {
+		delete(f.data.ModuleAttributes, moduleID)
+	}
+
+	return f.save()
+}
+
+// Get implements the Backend interface
+func (f *FileBackend) Get(moduleID string) (attributestore.ModuleAttributeStore, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	out := maps.Clone(f.data.ModuleAttributes[moduleID])
+	if out == nil {
+		out = attributestore.ModuleAttributeStore{}
+	}
+
+	return out, nil
+}
+
+// Modules implements the Backend interface
+func (f *FileBackend) Modules() ([]string, error) {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	return slices.Sorted(maps.Keys(f.data.ModuleAttributes)), nil
+}
+
+// Set implements the Backend interface
+func (f *FileBackend) Set(moduleID, key string, value any) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.data.ModuleAttributes[moduleID] == nil {
+		f.data.ModuleAttributes[moduleID] = make(attributestore.ModuleAttributeStore)
+	}
+
+	f.data.ModuleAttributes[moduleID][key] = value
+
+	return f.save()
+}
+
+// save writes the data into a temporary file next to the store and
+// renames it over the store afterwards. Caller must hold the lock.
+func (f *FileBackend) save() (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(f.filename), filepath.Base(f.filename)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary storage file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			// Don't leave broken temp-files behind
+			if rmErr := os.Remove(tmp.Name()); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+				logrus.WithError(rmErr).Error("removing temporary storage file")
+			}
+		}
+	}()
+
+	if err = json.NewEncoder(tmp).Encode(f.data); err != nil {
+		return errors.Join(fmt.Errorf("encoding storage file: %w", err), tmp.Close())
+	}
+
+	if err = tmp.Sync(); err != nil {
+		return errors.Join(fmt.Errorf("syncing storage file: %w", err), tmp.Close())
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("closing storage file: %w", err)
+	}
+
+	if err = os.Rename(tmp.Name(), f.filename); err != nil {
+		return fmt.Errorf("replacing storage file: %w", err)
+	}
+
+	return nil
+}
+
+func readDumpFile(filename string) (Dump, error) {
+	out := Dump{ModuleAttributes: make(map[string]attributestore.ModuleAttributeStore)}
+
+	s, err := os.Stat(filename)
+	switch {
+	case err == nil:
+		// This is fine
+
+	case os.IsNotExist(err):
+		// No store yet, return empty store
+		return out, nil
+
+	default:
+		return out, fmt.Errorf("getting file stats for store: %w", err)
+	}
+
+	if s.IsDir() {
+		// A directory was provided
+		return out, errors.New("store location is directory")
+	}
+
+	if s.Size() == 0 {
+		// An empty file was created, we don't care and will overwrite on save
+		return out, nil
+	}
+
+	f, err := os.Open(filename) //#nosec:G304 // Intended to open store location
+	if err != nil {
+		return out, fmt.Errorf("opening store: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logrus.WithError(err).Error("closing store (read)")
+		}
+	}()
+
+	if err = json.NewDecoder(f).Decode(&out); err != nil {
+		return out, fmt.Errorf("decoding store: %w", err)
+	}
+
+	if out.ModuleAttributes == nil {
+		out.ModuleAttributes = make(map[string]attributestore.ModuleAttributeStore)
+	}
+
+	return out, nil
+}
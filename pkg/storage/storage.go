@@ -0,0 +1,145 @@
+// Package storage provides the persistence backends for the module
+// attributes held by the MetaStore.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+)
+
+type (
+	// Backend persists the attributes stored by the modules. All
+	// methods must be safe for concurrent use.
+	Backend interface {
+		// Close flushes pending data and releases the backend
+		Close() error
+		// Delete removes the given key of the given module
+		Delete(moduleID, key string) error
+		// Get returns a copy of all attributes of the given module, an
+		// unknown module yields an empty store
+		Get(moduleID string) (attributestore.ModuleAttributeStore, error)
+		// Modules lists the IDs of all modules having attributes stored
+		Modules() ([]string, error)
+		// Set stores the value for the given key of the given module
+		Set(moduleID, key string, value any) error
+	}
+
+	// Dump represents the JSON representation of all stored data and
+	// is the format used by the file backend and for migrations
+	Dump struct {
+		ModuleAttributes map[string]attributestore.ModuleAttributeStore `json:"module_attributes"`
+	}
+)
+
+// ErrUnknownScheme signals the store location uses a scheme without
+// backend implementation
+var ErrUnknownScheme = errors.New("unknown store scheme")
+
+// New opens the backend described by the given location. Supported are
+//
+//   - `/path/to/store.json` or `file:///path/to/store.json` for a JSON
+//     file written atomically on every change
+//   - `bolt:///path/to/store.db` for an embedded bbolt database, the
+//     `migrate_from=/path/to/store.json` parameter imports an existing
+//     JSON store when the database is still empty
+func New(location string) (Backend, error) {
+	scheme, path, params, err := ParseLocation(location)
+	if err != nil {
+		return nil, err
+	}
+
+	switch scheme {
+	case "file":
+		return NewFileBackend(path)
+
+	case "bolt":
+		b, err := NewBoltBackend(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if src := params.Get("migrate_from"); src != "" {
+			if err = b.migrateFrom(src); err != nil {
+				return nil, errors.Join(err, b.Close())
+			}
+		}
+
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownScheme, scheme)
+}
+
+// Export reads all data from the given backend into a Dump
+func Export(b Backend) (Dump, error) {
+	out := Dump{ModuleAttributes: make(map[string]attributestore.ModuleAttributeStore)}
+
+	moduleIDs, err := b.Modules()
+	if err != nil {
+		return out, fmt.Errorf("listing modules: %w", err)
+	}
+
+	for _, id := range moduleIDs {
+		if out.ModuleAttributes[id], err = b.Get(id); err != nil {
+			return out, fmt.Errorf("getting attributes of %q: %w", id, err)
+		}
+	}
+
+	return out, nil
+}
+
+// Import writes all data of the Dump into the given backend, existing
+// keys are overwritten
+func Import(b Backend, d Dump) error {
+	for id, attrs := range d.ModuleAttributes {
+		for k, v := range attrs {
+			if err := b.Set(id, k, v); err != nil {
+				return fmt.Errorf("setting %s/%s: %w", id, k, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseLocation splits the store location into scheme, path and
+// parameters. Locations without scheme are treated as file paths.
+func ParseLocation(location string) (scheme, path string, params url.Values, err error) {
+	if !strings.Contains(location, "://") {
+		return "file", location, url.Values{}, nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("parsing store location: %w", err)
+	}
+
+	// Host is part of the path to support relative paths like
+	// `bolt://store.db` in addition to `bolt:///var/lib/store.db`
+	path = u.Host + u.Path
+	if path == "" {
+		return "", "", nil, errors.New("store location contains no path")
+	}
+
+	return u.Scheme, path, u.Query(), nil
+}
+
+// ValidateLocation checks whether the location can be parsed and
+// references a known backend without opening it
+func ValidateLocation(location string) error {
+	scheme, _, _, err := ParseLocation(location)
+	if err != nil {
+		return err
+	}
+
+	switch scheme {
+	case "bolt", "file":
+		return nil
+	}
+
+	return fmt.Errorf("%w: %q", ErrUnknownScheme, scheme)
+}
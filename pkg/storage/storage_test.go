@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBackends(t *testing.T) {
+	for name, location := range map[string]func(dir string) string{
+		"bolt": func(dir string) string { return "bolt://" + filepath.Join(dir, "store.db") },
+		"file": func(dir string) string { return filepath.Join(dir, "store.json") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			b := testOpen(t, location(dir))
+
+			for _, kv := range [][3]string{
+				{"mod-a", "message_id", "123"},
+				{"mod-a", "channel_id", "456"},
+				{"mod-b", "message_id", "789"},
+			} {
+				if err := b.Set(kv[0], kv[1], kv[2]); err != nil {
+					t.Fatalf("setting %s/%s: %s", kv[0], kv[1], err)
+				}
+			}
+
+			if err := b.Delete("mod-b", "message_id"); err != nil {
+				t.Fatalf("deleting: %s", err)
+			}
+
+			if err := b.Delete("unknown", "key"); err != nil {
+				t.Fatalf("deleting unknown key: %s", err)
+			}
+
+			// Modifying the returned store must not modify the backend
+			attrs, err := b.Get("mod-a")
+			if err != nil {
+				t.Fatalf("getting attributes: %s", err)
+			}
+			attrs["message_id"] = "changed"
+
+			if err = b.Close(); err != nil {
+				t.Fatalf("closing: %s", err)
+			}
+
+			b = testOpen(t, location(dir))
+			defer b.Close() //nolint:errcheck // Only reading from the store
+
+			modules, err := b.Modules()
+			if err != nil {
+				t.Fatalf("listing modules: %s", err)
+			}
+			if !slices.Equal(modules, []string{"mod-a"}) {
+				t.Errorf("expected only mod-a to be stored, got %v", modules)
+			}
+
+			attrs, err = b.Get("mod-a")
+			if err != nil {
+				t.Fatalf("getting attributes: %s", err)
+			}
+			if attrs["message_id"] != "123" || attrs["channel_id"] != "456" || len(attrs) != 2 {
+				t.Errorf("unexpected attributes after reopening: %v", attrs)
+			}
+
+			if attrs, err = b.Get("unknown"); err != nil || len(attrs) != 0 {
+				t.Errorf("expected empty store for unknown module, got %v (%v)", attrs, err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("listing directory: %s", err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("expected only the store in the directory, found %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestMigrateFromFile(t *testing.T) {
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "store.json")
+	location := "bolt://" + filepath.Join(dir, "store.db") + "?migrate_from=" + jsonFile
+
+	src := testOpen(t, jsonFile)
+	if err := src.Set("mod-a", "message_id", "123"); err != nil {
+		t.Fatalf("setting source value: %s", err)
+	}
+
+	b := testOpen(t, location)
+
+	attrs, err := b.Get("mod-a")
+	if err != nil {
+		t.Fatalf("getting migrated attributes: %s", err)
+	}
+	if attrs["message_id"] != "123" {
+		t.Errorf("expected value to be migrated, got %v", attrs)
+	}
+
+	if err = b.Set("mod-a", "message_id", "456"); err != nil {
+		t.Fatalf("updating value: %s", err)
+	}
+	if err = b.Close(); err != nil {
+		t.Fatalf("closing: %s", err)
+	}
+
+	// A database containing data must not be migrated again
+	b = testOpen(t, location)
+	defer b.Close() //nolint:errcheck // Only reading from the store
+
+	if attrs, err = b.Get("mod-a"); err != nil || attrs["message_id"] != "456" {
+		t.Errorf("expected migrated database to be left untouched, got %v (%v)", attrs, err)
+	}
+}
+
+func TestNewUnknownScheme(t *testing.T) {
+	if _, err := New("redis://localhost"); !errors.Is(err, ErrUnknownScheme) {
+		t.Errorf("expected ErrUnknownScheme, got %v", err)
+	}
+}
+
+func TestParseLocation(t *testing.T) {
+	for location, expected := range map[string][2]string{
+		"/var/lib/store.json":        {"file", "/var/lib/store.json"},
+		"store.json":                 {"file", "store.json"},
+		"file:///var/lib/store.json": {"file", "/var/lib/store.json"},
+		"bolt:///var/lib/store.db":   {"bolt", "/var/lib/store.db"},
+		"bolt://store.db":            {"bolt", "store.db"},
+	} {
+		scheme, path, _, err := ParseLocation(location)
+		if err != nil {
+			t.Errorf("parsing %q: %s", location, err)
+			continue
+		}
+
+		if scheme != expected[0] || path != expected[1] {
+			t.Errorf("parsing %q: expected %v, got [%s %s]", location, expected, scheme, path)
+		}
+	}
+
+	if _, _, _, err := ParseLocation("bolt://"); err == nil {
+		t.Error("expected location without path to be rejected")
+	}
+}
+
+func testOpen(t *testing.T, location string) Backend {
+	t.Helper()
+
+	b, err := New(location)
+	if err != nil {
+		t.Fatalf("opening %q: %s", location, err)
+	}
+
+	return b
+}
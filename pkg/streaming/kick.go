@@ -0,0 +1,272 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultKickAPIBaseURL is the base URL of the Kick public API
+	DefaultKickAPIBaseURL = "https://api.kick.com/public/v1"
+	// DefaultKickIDBaseURL is the base URL of the Kick authentication
+	// API
+	DefaultKickIDBaseURL = "https://id.kick.com"
+
+	// kickMaxBatchSize is the maximum number of channels or users to
+	// look up in one request
+	kickMaxBatchSize = 50
+	// kickTokenRefreshMargin is the time before expiry the app access
+	// token is renewed
+	kickTokenRefreshMargin = time.Minute
+)
+
+type (
+	// KickProvider implements the Provider using the Kick public API
+	// authorized by an app access token. Channels are referenced by
+	// their slug.
+	KickProvider struct {
+		apiBaseURL   string
+		client       *http.Client
+		clientID     string
+		clientSecret string
+		idBaseURL    string
+
+		token *kickToken
+	}
+
+	kickChannel struct {
+		BroadcasterUserID int64 `json:"broadcaster_user_id"`
+		Category          struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"category"`
+		Slug   string `json:"slug"`
+		Stream struct {
+			IsLive      bool      `json:"is_live"`
+			Language    string    `json:"language"`
+			StartTime   time.Time `json:"start_time"`
+			Thumbnail   string    `json:"thumbnail"`
+			ViewerCount int64     `json:"viewer_count"`
+		} `json:"stream"`
+		StreamTitle string `json:"stream_title"`
+	}
+
+	// kickToken caches the app access token shared by all copies of
+	// the provider
+	kickToken struct {
+		expiresAt time.Time
+		lock      sync.Mutex
+		token     string
+	}
+
+	kickUser struct {
+		Name           string `json:"name"`
+		ProfilePicture string `json:"profile_picture"`
+		UserID         int64  `json:"user_id"`
+	}
+)
+
+var _ Provider = KickProvider{}
+
+// NewKickProvider creates a new KickProvider using the credentials of
+// the given app
+func NewKickProvider(clientID, clientSecret string) KickProvider {
+	return KickProvider{
+		apiBaseURL:   DefaultKickAPIBaseURL,
+		client:       &http.Client{Timeout: requestTimeout},
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		idBaseURL:    DefaultKickIDBaseURL,
+
+		token: &kickToken{},
+	}
+}
+
+// ChannelFromURL returns the slug of the channel URL
+// (`https://kick.com/luziferus`)
+func (KickProvider) ChannelFromURL(_ context.Context, u *url.URL) (string, bool, error) {
+	if u.Host != "kick.com" && u.Host != "www.kick.com" {
+		return "", false, nil
+	}
+
+	slug, _, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	return strings.ToLower(slug), slug != "", nil
+}
+
+// Platform returns PlatformKick
+func (KickProvider) Platform() string { return PlatformKick }
+
+// Streams returns the live streams of the given slugs
+func (k KickProvider) Streams(ctx context.Context, channels ...string) ([]Stream, error) {
+	items, err := k.channels(ctx, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Stream
+	for _, c := range items {
+		if !c.Stream.IsLive {
+			continue
+		}
+
+		userID := strconv.FormatInt(c.BroadcasterUserID, 10)
+
+		out = append(out, Stream{
+			// The API does not expose stream IDs, the start of the
+			// stream identifies it for the broadcaster
+			ID:           userID + "-" + strconv.FormatInt(c.Stream.StartTime.Unix(), 10),
+			Platform:     PlatformKick,
+			UserID:       userID,
+			UserLogin:    c.Slug,
+			UserName:     c.Slug,
+			GameID:       strconv.FormatInt(c.Category.ID, 10),
+			GameName:     c.Category.Name,
+			Title:        c.StreamTitle,
+			ViewerCount:  c.Stream.ViewerCount,
+			StartedAt:    c.Stream.StartTime,
+			Language:     c.Stream.Language,
+			ThumbnailURL: c.Stream.Thumbnail,
+			URL:          "https://kick.com/" + c.Slug,
+		})
+	}
+
+	return out, nil
+}
+
+// Users returns the details of the given slugs
+func (k KickProvider) Users(ctx context.Context, channels ...string) ([]User, error) {
+	items, err := k.channels(ctx, channels)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(items))
+	for _, c := range items {
+		ids = append(ids, strconv.FormatInt(c.BroadcasterUserID, 10))
+	}
+
+	users := make(map[int64]kickUser)
+	for batch := range slices.Chunk(ids, kickMaxBatchSize) {
+		var resp struct {
+			Data []kickUser `json:"data"`
+		}
+
+		if err = k.get(ctx, "users", url.Values{"id": batch}, &resp); err != nil {
+			return nil, fmt.Errorf("fetching users: %w", err)
+		}
+
+		for _, u := range resp.Data {
+			users[u.UserID] = u
+		}
+	}
+
+	out := make([]User, 0, len(items))
+	for _, c := range items {
+		name := c.Slug
+		if u, ok := users[c.BroadcasterUserID]; ok && u.Name != "" {
+			name = u.Name
+		}
+
+		out = append(out, User{
+			ID:              strconv.FormatInt(c.BroadcasterUserID, 10),
+			Platform:        PlatformKick,
+			Login:           c.Slug,
+			DisplayName:     name,
+			ProfileImageURL: users[c.BroadcasterUserID].ProfilePicture,
+			URL:             "https://kick.com/" + c.Slug,
+		})
+	}
+
+	return out, nil
+}
+
+// accessToken returns the cached app access token or requests a new
+// one when it is about to expire
+func (k KickProvider) accessToken(ctx context.Context) (string, error) {
+	k.token.lock.Lock()
+	defer k.token.lock.Unlock()
+
+	if k.token.token != "" && time.Until(k.token.expiresAt) > kickTokenRefreshMargin {
+		return k.token.token, nil
+	}
+
+	params := make(url.Values)
+	params.Set("client_id", k.clientID)
+	params.Set("client_secret", k.clientSecret)
+	params.Set("grant_type", "client_credentials")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.idBaseURL+"/oauth/token", strings.NewReader(params.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var resp struct {
+		AccessToken string `json:"access_token"` //#nosec:G117 // Intended to work with secrets
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	if err = doJSON(k.client, PlatformKick, "oauth/token", req, &resp); err != nil {
+		return "", fmt.Errorf("requesting app access token: %w", err)
+	}
+
+	k.token.token = resp.AccessToken
+	k.token.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+
+	return k.token.token, nil
+}
+
+// channels fetches the channels of the given slugs
+func (k KickProvider) channels(ctx context.Context, slugs []string) ([]kickChannel, error) {
+	var out []kickChannel
+
+	for batch := range slices.Chunk(slugs, kickMaxBatchSize) {
+		var resp struct {
+			Data []kickChannel `json:"data"`
+		}
+
+		if err := k.get(ctx, "channels", url.Values{"slug": batch}, &resp); err != nil {
+			return nil, fmt.Errorf("fetching channels: %w", err)
+		}
+
+		out = append(out, resp.Data...)
+	}
+
+	return out, nil
+}
+
+// get executes a request against the public API
+func (k KickProvider) get(ctx context.Context, endpoint string, params url.Values, output any) error {
+	token, err := k.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.apiBaseURL+"/"+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	if err = doJSON(k.client, PlatformKick, endpoint, req, output); err != nil {
+		var apiErr APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			// Token was revoked, request a new one next time
+			k.token.lock.Lock()
+			k.token.token = ""
+			k.token.lock.Unlock()
+		}
+
+		return err
+	}
+
+	return nil
+}
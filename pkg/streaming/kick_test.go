@@ -0,0 +1,254 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testKickClientID     = "kick-client"
+	testKickClientSecret = "kick-secret"
+)
+
+type fakeKick struct {
+	channels      []kickChannel
+	lock          sync.Mutex
+	requests      map[string]int
+	tokenLifetime time.Duration
+	tokens        map[string]struct{}
+	users         []kickUser
+}
+
+func TestKickChannelFromURL(t *testing.T) {
+	k := NewKickProvider(testKickClientID, testKickClientSecret)
+
+	for raw, expected := range map[string]string{
+		"https://kick.com/Luziferus":        "luziferus",
+		"https://www.kick.com/luziferus/":   "luziferus",
+		"https://kick.com/luziferus/videos": "luziferus",
+		"https://kick.com/":                 "",
+		"https://www.twitch.tv/luziferus":   "",
+	} {
+		t.Run(raw, func(t *testing.T) {
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("parsing URL: %s", err)
+			}
+
+			channel, ok, err := k.ChannelFromURL(context.Background(), u)
+			if err != nil {
+				t.Fatalf("resolving URL: %s", err)
+			}
+
+			if ok != (expected != "") || channel != expected {
+				t.Errorf("expected channel %q, got %q (%v)", expected, channel, ok)
+			}
+		})
+	}
+}
+
+func TestKickStreams(t *testing.T) {
+	k, _ := newFakeKick(t)
+
+	streams, err := k.Streams(context.Background(), "luziferus", "offline", "unknown")
+	if err != nil {
+		t.Fatalf("fetching streams: %s", err)
+	}
+
+	if len(streams) != 1 {
+		t.Fatalf("expected only the live channel, got %+v", streams)
+	}
+
+	s := streams[0]
+	switch {
+	case s.ID != "1-"+strconv.FormatInt(s.StartedAt.Unix(), 10), s.Key() != "kick:"+s.ID:
+		t.Errorf("expected stream identified by user and start, got ID %q / key %q", s.ID, s.Key())
+	case s.UserID != "1", s.UserLogin != "luziferus", s.UserKey() != "kick:1":
+		t.Errorf("unexpected user of stream %+v", s)
+	case s.GameID != "15", s.GameName != "Software Development", s.Title != "Coding", s.ViewerCount != 42:
+		t.Errorf("unexpected details of stream %+v", s)
+	case s.URL != "https://kick.com/luziferus":
+		t.Errorf("unexpected URL %q", s.URL)
+	}
+}
+
+func TestKickUsers(t *testing.T) {
+	k, _ := newFakeKick(t)
+
+	users, err := k.Users(context.Background(), "luziferus", "offline", "unknown")
+	if err != nil {
+		t.Fatalf("fetching users: %s", err)
+	}
+
+	slices.SortFunc(users, func(a, b User) int { return strings.Compare(a.ID, b.ID) })
+
+	if len(users) != 2 {
+		t.Fatalf("expected unknown channels to be omitted, got %+v", users)
+	}
+
+	switch u := users[0]; {
+	case u.ID != "1", u.Login != "luziferus", u.Ref() != "kick:luziferus":
+		t.Errorf("unexpected channel reference %+v", u)
+	case u.DisplayName != "Luziferus", u.ProfileImageURL != "https://files.kick.com/luziferus.png":
+		t.Errorf("unexpected user details %+v", u)
+	}
+
+	// Channels without user details fall back to the slug
+	if u := users[1]; u.DisplayName != "offline" || u.ProfileImageURL != "" {
+		t.Errorf("expected slug as display name, got %+v", u)
+	}
+}
+
+func TestKickToken(t *testing.T) {
+	k, fake := newFakeKick(t)
+
+	fetch := func() error {
+		_, err := k.Streams(context.Background(), "luziferus")
+		return err
+	}
+
+	// Copies of the provider share the token
+	for _, p := range []KickProvider{k, k} {
+		if _, err := p.Users(context.Background(), "luziferus"); err != nil {
+			t.Fatalf("fetching users: %s", err)
+		}
+	}
+	if n := fake.count("/oauth/token"); n != 1 {
+		t.Errorf("expected token to be requested once, got %d token requests", n)
+	}
+
+	// A revoked token is dropped so the next request gets a new one
+	fake.revokeTokens()
+
+	var apiErr APIError
+	if err := fetch(); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected request with revoked token to fail, got %v", err)
+	}
+	if err := fetch(); err != nil {
+		t.Fatalf("fetching with new token: %s", err)
+	}
+	if n := fake.count("/oauth/token"); n != 2 {
+		t.Errorf("expected new token after revocation, got %d token requests", n)
+	}
+
+	// Tokens expiring within the refresh margin are renewed
+	k, fake = newFakeKick(t)
+	fake.tokenLifetime = 30 * time.Second
+
+	for range 3 {
+		if err := fetch(); err != nil {
+			t.Fatalf("fetching streams: %s", err)
+		}
+	}
+	if n := fake.count("/oauth/token"); n != 3 {
+		t.Errorf("expected short-living tokens to be renewed, got %d token requests", n)
+	}
+}
+
+// newFakeKick creates a KickProvider using a fake public and
+// authentication API knowing the live channel luziferus and the
+// offline channel without user details
+func newFakeKick(t *testing.T) (KickProvider, *fakeKick) {
+	t.Helper()
+
+	live := kickChannel{BroadcasterUserID: 1, Slug: "luziferus", StreamTitle: "Coding"}
+	live.Category.ID, live.Category.Name = 15, "Software Development"
+	live.Stream.IsLive = true
+	live.Stream.StartTime = time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	live.Stream.ViewerCount = 42
+
+	fake := &fakeKick{
+		channels:      []kickChannel{live, {BroadcasterUserID: 2, Slug: "offline"}},
+		requests:      make(map[string]int),
+		tokenLifetime: time.Hour,
+		tokens:        make(map[string]struct{}),
+		users:         []kickUser{{Name: "Luziferus", ProfilePicture: "https://files.kick.com/luziferus.png", UserID: 1}},
+	}
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	k := NewKickProvider(testKickClientID, testKickClientSecret)
+	k.apiBaseURL, k.client, k.idBaseURL = srv.URL, srv.Client(), srv.URL
+
+	return k, fake
+}
+
+func (f *fakeKick) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.requests[r.URL.Path]++
+
+	if r.URL.Path == "/oauth/token" {
+		if r.Method != http.MethodPost || r.FormValue("grant_type") != "client_credentials" ||
+			r.FormValue("client_id") != testKickClientID || r.FormValue("client_secret") != testKickClientSecret {
+			http.Error(w, "invalid client", http.StatusBadRequest)
+			return
+		}
+
+		token := "token-" + strconv.Itoa(f.requests[r.URL.Path])
+		f.tokens[token] = struct{}{}
+		f.write(w, map[string]any{"access_token": token, "expires_in": int(f.tokenLifetime / time.Second)})
+		return
+	}
+
+	if _, ok := f.tokens[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]; !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var data []any
+	switch r.URL.Path {
+	case "/channels":
+		for _, c := range f.channels {
+			if slices.Contains(r.URL.Query()["slug"], c.Slug) {
+				data = append(data, c)
+			}
+		}
+
+	case "/users":
+		for _, u := range f.users {
+			if slices.Contains(r.URL.Query()["id"], strconv.FormatInt(u.UserID, 10)) {
+				data = append(data, u)
+			}
+		}
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	f.write(w, map[string]any{"data": data})
+}
+
+// count returns the number of requests to the path
+func (f *fakeKick) count(path string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.requests[path]
+}
+
+// revokeTokens invalidates all issued tokens
+func (f *fakeKick) revokeTokens() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.tokens = make(map[string]struct{})
+}
+
+func (*fakeKick) write(w http.ResponseWriter, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body) //nolint:errcheck,errchkjson,gosec // Test server
+}
@@ -0,0 +1,310 @@
+// Package streaming abstracts the streaming platforms live streams are
+// tracked on so modules can handle Twitch, YouTube and Kick alike.
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/metrics"
+)
+
+const (
+	// PlatformKick is the name of the Kick platform
+	PlatformKick = "kick"
+	// PlatformTwitch is the name of the Twitch platform, channels
+	// without platform prefix belong to Twitch
+	PlatformTwitch = "twitch"
+	// PlatformYouTube is the name of the YouTube platform
+	PlatformYouTube = "youtube"
+
+	// requestTimeout is the timeout of a single request against the
+	// APIs of YouTube and Kick
+	requestTimeout = 5 * time.Second
+)
+
+type (
+	// APIError is returned when the API of a platform answers a
+	// request with an unsuccessful status
+	APIError struct {
+		Platform   string
+		StatusCode int
+		Body       string
+	}
+
+	// Provider gives access to the channels and live streams of one
+	// streaming platform
+	Provider interface {
+		// ChannelFromURL returns the channel the URL (e.g. of a Discord
+		// streaming presence) points to. Returns false if the URL does
+		// not belong to the platform.
+		ChannelFromURL(ctx context.Context, u *url.URL) (channel string, ok bool, err error)
+		// Platform returns the name of the platform used as prefix of
+		// channel references
+		Platform() string
+		// Streams returns the live streams of the given channels,
+		// channels not being live are omitted
+		Streams(ctx context.Context, channels ...string) ([]Stream, error)
+		// Users returns the details of the given channels, unknown
+		// channels are omitted
+		Users(ctx context.Context, channels ...string) ([]User, error)
+	}
+
+	// Providers contains the available providers by platform
+	Providers map[string]Provider
+
+	// Stream contains the details of a live stream passed to the
+	// templates of the modules
+	Stream struct {
+		ID           string
+		Platform     string
+		UserID       string
+		UserLogin    string
+		UserName     string
+		GameID       string
+		GameName     string
+		Title        string
+		ViewerCount  int64
+		StartedAt    time.Time
+		Language     string
+		ThumbnailURL string
+		Tags         []string
+		URL          string
+	}
+
+	// User contains the details of a channel on a platform passed to
+	// the templates of the modules
+	User struct {
+		ID              string
+		Platform        string
+		Login           string
+		DisplayName     string
+		ProfileImageURL string
+		URL             string
+	}
+
+	// channelValidator is implemented by providers accepting only some
+	// forms of channel references
+	channelValidator interface {
+		// ValidateChannel returns an error if the provider cannot look
+		// up the channel
+		ValidateChannel(channel string) error
+	}
+)
+
+var (
+	// ErrPlatformNotConfigured signals there is no provider for the
+	// platform of a channel reference
+	ErrPlatformNotConfigured = errors.New("streaming platform not configured")
+
+	platforms = []string{PlatformKick, PlatformTwitch, PlatformYouTube}
+)
+
+// Key returns the reference to the ID (of a user, channel or stream)
+// on the platform. Twitch IDs are returned unchanged to keep existing
+// references valid, IDs of other platforms are prefixed with the
+// platform.
+func Key(platform, id string) string {
+	if platform == PlatformTwitch || platform == "" {
+		return id
+	}
+
+	return platform + ":" + id
+}
+
+// ParseChannel splits a channel reference (`youtube:UC...`) into
+// platform and channel. References without a known platform prefix
+// are Twitch logins.
+func ParseChannel(ref string) (platform, channel string) {
+	if p, c, ok := strings.Cut(ref, ":"); ok {
+		for _, known := range platforms {
+			if strings.EqualFold(p, known) {
+				return known, c
+			}
+		}
+	}
+
+	return PlatformTwitch, ref
+}
+
+func (e APIError) Error() string {
+	return fmt.Sprintf("unexpected status %d from %s: %s", e.StatusCode, e.Platform, e.Body)
+}
+
+// ChannelFromURL asks the providers for the channel the URL points to
+// and returns the platform and the channel of the first provider
+// knowing the URL
+func (p Providers) ChannelFromURL(ctx context.Context, u *url.URL) (platform, channel string, ok bool, err error) {
+	for _, provider := range p {
+		if channel, ok, err = provider.ChannelFromURL(ctx, u); err != nil || ok {
+			return provider.Platform(), channel, ok, err
+		}
+	}
+
+	return "", "", false, nil
+}
+
+// Get returns the provider of the platform
+func (p Providers) Get(platform string) (Provider, error) {
+	provider, ok := p[platform]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrPlatformNotConfigured, platform)
+	}
+
+	return provider, nil
+}
+
+// Lookup fetches the details and the live streams of the referenced
+// channels (see ParseChannel) from their platforms
+func (p Providers) Lookup(ctx context.Context, refs ...string) ([]User, []Stream, error) {
+	users, err := p.Users(ctx, refs...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	streams, err := p.Streams(ctx, refs...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return users, streams, nil
+}
+
+// Streams fetches the live streams of the referenced channels (see
+// ParseChannel) from their platforms
+func (p Providers) Streams(ctx context.Context, refs ...string) ([]Stream, error) {
+	var streams []Stream
+
+	for platform, channels := range groupByPlatform(refs) {
+		provider, err := p.Get(platform)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := provider.Streams(ctx, channels...)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s streams: %w", platform, err)
+		}
+
+		streams = append(streams, s...)
+	}
+
+	return streams, nil
+}
+
+// Users fetches the details of the referenced channels (see
+// ParseChannel) from their platforms
+func (p Providers) Users(ctx context.Context, refs ...string) ([]User, error) {
+	var users []User
+
+	for platform, channels := range groupByPlatform(refs) {
+		provider, err := p.Get(platform)
+		if err != nil {
+			return nil, err
+		}
+
+		u, err := provider.Users(ctx, channels...)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s users: %w", platform, err)
+		}
+
+		users = append(users, u...)
+	}
+
+	return users, nil
+}
+
+// Validate checks providers for the platforms of all references exist
+// and are able to look up the referenced channels
+func (p Providers) Validate(refs ...string) error {
+	for _, ref := range refs {
+		platform, channel := ParseChannel(ref)
+		provider, err := p.Get(platform)
+		if err != nil {
+			return fmt.Errorf("channel %q: %w", ref, err)
+		}
+
+		if v, ok := provider.(channelValidator); ok {
+			if err = v.ValidateChannel(channel); err != nil {
+				return fmt.Errorf("channel %q: %w", ref, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Key returns the reference to the stream unique across platforms
+func (s Stream) Key() string { return Key(s.Platform, s.ID) }
+
+// UserKey returns the reference to the user of the stream unique
+// across platforms
+func (s Stream) UserKey() string { return Key(s.Platform, s.UserID) }
+
+// Key returns the reference to the user unique across platforms
+func (u User) Key() string { return Key(u.Platform, u.ID) }
+
+// Ref returns the channel reference of the user as accepted by
+// ParseChannel
+func (u User) Ref() string { return Key(u.Platform, u.Login) }
+
+// doJSON executes the request against the API of the platform and
+// decodes the response into the output. The endpoint is used as
+// metrics label.
+func doJSON(client *http.Client, platform, endpoint string, req *http.Request, output any) error {
+	start := time.Now()
+	resp, err := client.Do(req)
+	metrics.StreamingRequestDuration.WithLabelValues(platform, endpoint).Observe(time.Since(start).Seconds())
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.StreamingRequests.WithLabelValues(platform, endpoint, status).Inc()
+
+	if err != nil {
+		return fmt.Errorf("fetching response: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logrus.WithError(err).WithField("platform", platform).Error("closing response body")
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("unexpected status %d and cannot read body: %w", resp.StatusCode, err)
+		}
+
+		return APIError{Platform: platform, StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(output); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
+
+// groupByPlatform splits the channel references into the channels of
+// each platform
+func groupByPlatform(refs []string) map[string][]string {
+	byPlatform := make(map[string][]string)
+	for _, ref := range refs {
+		platform, channel := ParseChannel(ref)
+		byPlatform[platform] = append(byPlatform[platform], channel)
+	}
+
+	return byPlatform
+}
@@ -0,0 +1,89 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+// TwitchProvider implements the Provider using the Twitch Helix API
+type TwitchProvider struct {
+	adapter *twitch.Adapter
+}
+
+var _ Provider = TwitchProvider{}
+
+// NewTwitchProvider creates a new TwitchProvider using the given
+// Adapter
+func NewTwitchProvider(adapter *twitch.Adapter) TwitchProvider {
+	return TwitchProvider{adapter: adapter}
+}
+
+// ChannelFromURL returns the login of the channel URL
+// (`https://www.twitch.tv/luziferus`)
+func (TwitchProvider) ChannelFromURL(_ context.Context, u *url.URL) (string, bool, error) {
+	if u.Host != "www.twitch.tv" && u.Host != "twitch.tv" {
+		return "", false, nil
+	}
+
+	login, _, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	return login, login != "", nil
+}
+
+// Platform returns PlatformTwitch
+func (TwitchProvider) Platform() string { return PlatformTwitch }
+
+// Streams returns the live streams of the given logins
+func (t TwitchProvider) Streams(ctx context.Context, channels ...string) ([]Stream, error) {
+	streams, err := t.adapter.GetStreamsForUser(ctx, channels...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching streams: %w", err)
+	}
+
+	out := make([]Stream, 0, len(streams.Data))
+	for _, s := range streams.Data {
+		out = append(out, Stream{
+			ID:           s.ID,
+			Platform:     PlatformTwitch,
+			UserID:       s.UserID,
+			UserLogin:    s.UserLogin,
+			UserName:     s.UserName,
+			GameID:       s.GameID,
+			GameName:     s.GameName,
+			Title:        s.Title,
+			ViewerCount:  s.ViewerCount,
+			StartedAt:    s.StartedAt,
+			Language:     s.Language,
+			ThumbnailURL: s.ThumbnailURL,
+			Tags:         s.Tags,
+			URL:          "https://www.twitch.tv/" + s.UserLogin,
+		})
+	}
+
+	return out, nil
+}
+
+// Users returns the details of the given logins
+func (t TwitchProvider) Users(ctx context.Context, channels ...string) ([]User, error) {
+	users, err := t.adapter.GetUserByUsername(ctx, channels...)
+	if err != nil {
+		return nil, fmt.Errorf("fetching users: %w", err)
+	}
+
+	out := make([]User, 0, len(users.Data))
+	for _, u := range users.Data {
+		out = append(out, User{
+			ID:              u.ID,
+			Platform:        PlatformTwitch,
+			Login:           strings.ToLower(u.Login),
+			DisplayName:     u.DisplayName,
+			ProfileImageURL: u.ProfileImageURL,
+			URL:             "https://www.twitch.tv/" + strings.ToLower(u.Login),
+		})
+	}
+
+	return out, nil
+}
@@ -0,0 +1,296 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultYouTubeAPIBaseURL is the base URL of the YouTube Data API
+	DefaultYouTubeAPIBaseURL = "https://www.googleapis.com/youtube/v3"
+
+	// youTubeMaxBatchSize is the maximum number of IDs to look up in
+	// one request
+	youTubeMaxBatchSize = 50
+	// youTubeRecentUploads is the number of latest uploads checked for
+	// a live stream per channel
+	youTubeRecentUploads = 5
+)
+
+type (
+	// YouTubeProvider implements the Provider using the YouTube Data
+	// API authorized by an API key. Channels are referenced by their
+	// channel ID (`UC...`).
+	YouTubeProvider struct {
+		apiKey     string
+		apiBaseURL string
+		client     *http.Client
+	}
+
+	youTubeThumbnails map[string]struct {
+		URL string `json:"url"`
+	}
+
+	youTubeChannel struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			Title      string            `json:"title"`
+			Thumbnails youTubeThumbnails `json:"thumbnails"`
+		} `json:"snippet"`
+	}
+
+	youTubeVideo struct {
+		ID      string `json:"id"`
+		Snippet struct {
+			ChannelID            string            `json:"channelId"`
+			ChannelTitle         string            `json:"channelTitle"`
+			CategoryID           string            `json:"categoryId"`
+			DefaultAudioLanguage string            `json:"defaultAudioLanguage"`
+			LiveBroadcastContent string            `json:"liveBroadcastContent"`
+			Tags                 []string          `json:"tags"`
+			Thumbnails           youTubeThumbnails `json:"thumbnails"`
+			Title                string            `json:"title"`
+		} `json:"snippet"`
+		LiveStreamingDetails struct {
+			ActualStartTime   time.Time `json:"actualStartTime"`
+			ConcurrentViewers string    `json:"concurrentViewers"`
+		} `json:"liveStreamingDetails"`
+	}
+)
+
+var (
+	_ Provider         = YouTubeProvider{}
+	_ channelValidator = YouTubeProvider{}
+
+	errYouTubeChannelID = errors.New("YouTube channels must be referenced by their channel ID (UC...)")
+)
+
+// NewYouTubeProvider creates a new YouTubeProvider using the given
+// API key
+func NewYouTubeProvider(apiKey string) YouTubeProvider {
+	return YouTubeProvider{
+		apiKey:     apiKey,
+		apiBaseURL: DefaultYouTubeAPIBaseURL,
+		client:     &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// ChannelFromURL returns the channel ID of channel URLs
+// (`/channel/UC...`, `/@handle`) and the channel of the video for
+// video URLs (`/watch?v=...`, `/live/...`, `youtu.be/...`)
+func (y YouTubeProvider) ChannelFromURL(ctx context.Context, u *url.URL) (string, bool, error) {
+	var videoID string
+
+	path := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch {
+	case u.Host == "youtu.be":
+		videoID = path[0]
+
+	case !slices.Contains([]string{"youtube.com", "www.youtube.com", "m.youtube.com"}, u.Host):
+		return "", false, nil
+
+	case path[0] == "watch":
+		videoID = u.Query().Get("v")
+
+	case path[0] == "live" && len(path) > 1:
+		videoID = path[1]
+
+	case path[0] == "channel" && len(path) > 1:
+		return path[1], true, nil
+
+	case strings.HasPrefix(path[0], "@"):
+		channels, err := y.channels(ctx, url.Values{"forHandle": {path[0]}})
+		if err != nil || len(channels) == 0 {
+			return "", false, err
+		}
+		return channels[0].ID, true, nil
+	}
+
+	if videoID == "" {
+		return "", false, nil
+	}
+
+	videos, err := y.videos(ctx, videoID)
+	if err != nil || len(videos) == 0 {
+		return "", false, err
+	}
+
+	return videos[0].Snippet.ChannelID, true, nil
+}
+
+// Platform returns PlatformYouTube
+func (YouTubeProvider) Platform() string { return PlatformYouTube }
+
+// Streams returns the live streams of the given channel IDs. Searching
+// for live streams is expensive in API quota so the latest uploads of
+// the channels, which contain running live streams, are checked.
+func (y YouTubeProvider) Streams(ctx context.Context, channels ...string) ([]Stream, error) {
+	var videoIDs []string
+
+	for _, channelID := range channels {
+		if y.ValidateChannel(channelID) != nil {
+			// Only channel IDs have an uploads playlist, other
+			// references are rejected by Providers.Validate
+			continue
+		}
+
+		var resp struct {
+			Items []struct {
+				ContentDetails struct {
+					VideoID string `json:"videoId"`
+				} `json:"contentDetails"`
+			} `json:"items"`
+		}
+
+		if err := y.get(ctx, "playlistItems", url.Values{
+			"maxResults": {strconv.Itoa(youTubeRecentUploads)},
+			"part":       {"contentDetails"},
+			"playlistId": {"UU" + strings.TrimPrefix(channelID, "UC")},
+		}, &resp); err != nil {
+			var apiErr APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+				// Channel has no uploads
+				continue
+			}
+			return nil, fmt.Errorf("fetching uploads of %s: %w", channelID, err)
+		}
+
+		for _, item := range resp.Items {
+			videoIDs = append(videoIDs, item.ContentDetails.VideoID)
+		}
+	}
+
+	videos, err := y.videos(ctx, videoIDs...)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []Stream
+	for _, v := range videos {
+		if v.Snippet.LiveBroadcastContent != "live" {
+			continue
+		}
+
+		viewers, _ := strconv.ParseInt(v.LiveStreamingDetails.ConcurrentViewers, 10, 64)
+
+		out = append(out, Stream{
+			ID:           v.ID,
+			Platform:     PlatformYouTube,
+			UserID:       v.Snippet.ChannelID,
+			UserLogin:    v.Snippet.ChannelID,
+			UserName:     v.Snippet.ChannelTitle,
+			GameID:       v.Snippet.CategoryID,
+			Title:        v.Snippet.Title,
+			ViewerCount:  viewers,
+			StartedAt:    v.LiveStreamingDetails.ActualStartTime,
+			Language:     v.Snippet.DefaultAudioLanguage,
+			ThumbnailURL: v.Snippet.Thumbnails.best(),
+			Tags:         v.Snippet.Tags,
+			URL:          "https://www.youtube.com/watch?v=" + v.ID,
+		})
+	}
+
+	return out, nil
+}
+
+// Users returns the details of the given channel IDs
+func (y YouTubeProvider) Users(ctx context.Context, channels ...string) ([]User, error) {
+	var out []User
+
+	for batch := range slices.Chunk(channels, youTubeMaxBatchSize) {
+		items, err := y.channels(ctx, url.Values{"id": {strings.Join(batch, ",")}})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range items {
+			out = append(out, User{
+				ID:              c.ID,
+				Platform:        PlatformYouTube,
+				Login:           c.ID,
+				DisplayName:     c.Snippet.Title,
+				ProfileImageURL: c.Snippet.Thumbnails.best(),
+				URL:             "https://www.youtube.com/channel/" + c.ID,
+			})
+		}
+	}
+
+	return out, nil
+}
+
+// ValidateChannel rejects channels not referenced by their channel ID
+// (e.g. `@handle`) as only those can be checked for live streams
+func (YouTubeProvider) ValidateChannel(channel string) error {
+	if !strings.HasPrefix(channel, "UC") {
+		return errYouTubeChannelID
+	}
+
+	return nil
+}
+
+// channels fetches the channels matching the filter
+func (y YouTubeProvider) channels(ctx context.Context, filter url.Values) ([]youTubeChannel, error) {
+	var resp struct {
+		Items []youTubeChannel `json:"items"`
+	}
+
+	filter.Set("part", "snippet")
+	if err := y.get(ctx, "channels", filter, &resp); err != nil {
+		return nil, fmt.Errorf("fetching channels: %w", err)
+	}
+
+	return resp.Items, nil
+}
+
+// get executes a request against the Data API
+func (y YouTubeProvider) get(ctx context.Context, endpoint string, params url.Values, output any) error {
+	params.Set("key", y.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, y.apiBaseURL+"/"+endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	return doJSON(y.client, PlatformYouTube, endpoint, req, output)
+}
+
+// videos fetches the given videos including their live details
+func (y YouTubeProvider) videos(ctx context.Context, ids ...string) ([]youTubeVideo, error) {
+	var out []youTubeVideo
+
+	for batch := range slices.Chunk(ids, youTubeMaxBatchSize) {
+		var resp struct {
+			Items []youTubeVideo `json:"items"`
+		}
+
+		if err := y.get(ctx, "videos", url.Values{
+			"id":   {strings.Join(batch, ",")},
+			"part": {"snippet,liveStreamingDetails"},
+		}, &resp); err != nil {
+			return nil, fmt.Errorf("fetching videos: %w", err)
+		}
+
+		out = append(out, resp.Items...)
+	}
+
+	return out, nil
+}
+
+// best returns the URL of the largest thumbnail
+func (y youTubeThumbnails) best() string {
+	for _, size := range []string{"maxres", "standard", "high", "medium", "default"} {
+		if t, ok := y[size]; ok && t.URL != "" {
+			return t.URL
+		}
+	}
+
+	return ""
+}
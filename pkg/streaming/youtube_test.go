@@ -0,0 +1,260 @@
+package streaming
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testYouTubeAPIKey = "test-key"
+
+type fakeYouTube struct {
+	channels map[string]youTubeChannel
+	handles  map[string]string
+	lock     sync.Mutex
+	requests map[string]int
+	uploads  map[string][]string
+	videos   map[string]youTubeVideo
+}
+
+func TestYouTubeChannelFromURL(t *testing.T) {
+	y, _ := newFakeYouTube(t)
+
+	for raw, expected := range map[string]string{
+		"https://www.youtube.com/channel/UC1":       "UC1",
+		"https://www.youtube.com/@luziferus/videos": "UC1",
+		"https://www.youtube.com/watch?v=live1":     "UC1",
+		"https://m.youtube.com/live/live2":          "UC2",
+		"https://youtu.be/live1":                    "UC1",
+		"https://www.youtube.com/@unknown":          "",
+		"https://www.youtube.com/watch?v=unknown":   "",
+		"https://www.youtube.com/feed/trending":     "",
+		"https://www.twitch.tv/luziferus":           "",
+	} {
+		t.Run(raw, func(t *testing.T) {
+			u, err := url.Parse(raw)
+			if err != nil {
+				t.Fatalf("parsing URL: %s", err)
+			}
+
+			channel, ok, err := y.ChannelFromURL(context.Background(), u)
+			if err != nil {
+				t.Fatalf("resolving URL: %s", err)
+			}
+
+			if ok != (expected != "") || channel != expected {
+				t.Errorf("expected channel %q, got %q (%v)", expected, channel, ok)
+			}
+		})
+	}
+}
+
+func TestYouTubeStreams(t *testing.T) {
+	y, fake := newFakeYouTube(t)
+
+	// UC3 has no uploads, @luziferus is no channel ID
+	streams, err := y.Streams(context.Background(), "UC1", "UC2", "UC3", "@luziferus")
+	if err != nil {
+		t.Fatalf("fetching streams: %s", err)
+	}
+
+	if len(streams) != 2 {
+		t.Fatalf("expected only the live videos, got %+v", streams)
+	}
+
+	slices.SortFunc(streams, func(a, b Stream) int { return strings.Compare(a.ID, b.ID) })
+
+	s := streams[0]
+	switch {
+	case s.ID != "live1", s.Key() != "youtube:live1":
+		t.Errorf("unexpected stream ID %q / key %q", s.ID, s.Key())
+	case s.UserID != "UC1", s.UserLogin != "UC1", s.UserName != "Luziferus":
+		t.Errorf("unexpected channel of stream %+v", s)
+	case s.ViewerCount != 42, s.Title != "Coding":
+		t.Errorf("unexpected details of stream %+v", s)
+	case s.ThumbnailURL != "https://i.ytimg.com/vi/live1/maxresdefault.jpg":
+		t.Errorf("expected largest thumbnail, got %q", s.ThumbnailURL)
+	case s.URL != "https://www.youtube.com/watch?v=live1":
+		t.Errorf("unexpected URL %q", s.URL)
+	}
+
+	if n := fake.count("playlistItems"); n != 3 {
+		t.Errorf("expected uploads of the 3 channel IDs to be checked, got %d requests", n)
+	}
+}
+
+func TestYouTubeUsers(t *testing.T) {
+	y, fake := newFakeYouTube(t)
+
+	ids := []string{"UC1", "UC2", "UCunknown"}
+	for i := range youTubeMaxBatchSize {
+		ids = append(ids, fmt.Sprintf("UCfiller%d", i))
+	}
+
+	users, err := y.Users(context.Background(), ids...)
+	if err != nil {
+		t.Fatalf("fetching users: %s", err)
+	}
+
+	if len(users) != 2 {
+		t.Fatalf("expected unknown channels to be omitted, got %+v", users)
+	}
+
+	u := users[0]
+	switch {
+	case u.ID != "UC1", u.Login != "UC1", u.Ref() != "youtube:UC1":
+		t.Errorf("unexpected channel reference %+v", u)
+	case u.DisplayName != "Luziferus", u.ProfileImageURL != "https://yt3.ggpht.com/luziferus-high.jpg":
+		t.Errorf("unexpected channel details %+v", u)
+	case u.URL != "https://www.youtube.com/channel/UC1":
+		t.Errorf("unexpected URL %q", u.URL)
+	}
+
+	if n := fake.count("channels"); n != 2 {
+		t.Errorf("expected channels to be fetched in 2 batches, got %d requests", n)
+	}
+}
+
+func TestValidateYouTubeChannels(t *testing.T) {
+	p := Providers{PlatformYouTube: NewYouTubeProvider(testYouTubeAPIKey)}
+
+	if err := p.Validate("youtube:UC1", "YouTube:UC2"); err != nil {
+		t.Errorf("expected channel IDs to be accepted: %s", err)
+	}
+
+	if err := p.Validate("youtube:UC1", "youtube:@luziferus"); !errors.Is(err, errYouTubeChannelID) {
+		t.Errorf("expected handle to be rejected, got %v", err)
+	}
+
+	if err := p.Validate("kick:luziferus"); !errors.Is(err, ErrPlatformNotConfigured) {
+		t.Errorf("expected unconfigured platform to be rejected, got %v", err)
+	}
+}
+
+// newFakeYouTube creates a YouTubeProvider using a fake Data API
+// knowing the channels UC1 (with a live stream), UC2 (with a live and
+// an ended stream) and UC3 (without uploads)
+func newFakeYouTube(t *testing.T) (YouTubeProvider, *fakeYouTube) {
+	t.Helper()
+
+	fake := &fakeYouTube{
+		channels: make(map[string]youTubeChannel),
+		handles:  map[string]string{"@luziferus": "UC1"},
+		requests: make(map[string]int),
+		uploads: map[string][]string{
+			"UU1": {"live1", "vod1"},
+			"UU2": {"vod2", "live2"},
+		},
+		videos: make(map[string]youTubeVideo),
+	}
+
+	for id, title := range map[string]string{"UC1": "Luziferus", "UC2": "Other", "UC3": "Quiet"} {
+		var c youTubeChannel
+		c.ID, c.Snippet.Title = id, title
+		c.Snippet.Thumbnails = youTubeThumbnails{
+			"default": {URL: "https://yt3.ggpht.com/" + strings.ToLower(title) + ".jpg"},
+			"high":    {URL: "https://yt3.ggpht.com/" + strings.ToLower(title) + "-high.jpg"},
+		}
+		fake.channels[id] = c
+	}
+
+	for id, channelID := range map[string]string{"live1": "UC1", "vod1": "UC1", "live2": "UC2", "vod2": "UC2"} {
+		var v youTubeVideo
+		v.ID = id
+		v.Snippet.ChannelID = channelID
+		v.Snippet.ChannelTitle = fake.channels[channelID].Snippet.Title
+		v.Snippet.LiveBroadcastContent = "none"
+		v.Snippet.Thumbnails = youTubeThumbnails{
+			"default": {URL: "https://i.ytimg.com/vi/" + id + "/default.jpg"},
+			"maxres":  {URL: "https://i.ytimg.com/vi/" + id + "/maxresdefault.jpg"},
+		}
+
+		if strings.HasPrefix(id, "live") {
+			v.Snippet.LiveBroadcastContent = "live"
+			v.Snippet.Title = "Coding"
+			v.LiveStreamingDetails.ActualStartTime = time.Now().Add(-time.Hour).UTC()
+			v.LiveStreamingDetails.ConcurrentViewers = "42"
+		}
+
+		fake.videos[id] = v
+	}
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	y := NewYouTubeProvider(testYouTubeAPIKey)
+	y.apiBaseURL, y.client = srv.URL, srv.Client()
+
+	return y, fake
+}
+
+func (f *fakeYouTube) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	endpoint := strings.TrimPrefix(r.URL.Path, "/")
+	f.requests[endpoint]++
+
+	q := r.URL.Query()
+	if q.Get("key") != testYouTubeAPIKey {
+		http.Error(w, "invalid key", http.StatusForbidden)
+		return
+	}
+
+	var items []any
+	switch endpoint {
+	case "channels":
+		ids := strings.Split(q.Get("id"), ",")
+		if handle := q.Get("forHandle"); handle != "" {
+			ids = []string{f.handles[handle]}
+		}
+
+		for _, id := range ids {
+			if c, ok := f.channels[id]; ok {
+				items = append(items, c)
+			}
+		}
+
+	case "playlistItems":
+		uploads, ok := f.uploads[q.Get("playlistId")]
+		if !ok {
+			http.Error(w, "playlist not found", http.StatusNotFound)
+			return
+		}
+
+		for _, id := range uploads {
+			items = append(items, map[string]any{"contentDetails": map[string]string{"videoId": id}})
+		}
+
+	case "videos":
+		for id := range strings.SplitSeq(q.Get("id"), ",") {
+			if v, ok := f.videos[id]; ok {
+				items = append(items, v)
+			}
+		}
+
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"items": items}) //nolint:errcheck,errchkjson,gosec // Test server
+}
+
+// count returns the number of requests to the endpoint
+func (f *fakeYouTube) count(endpoint string) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.requests[endpoint]
+}
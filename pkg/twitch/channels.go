@@ -0,0 +1,125 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type (
+	// ChannelFollower contains a user following a channel
+	ChannelFollower struct {
+		UserID     string    `json:"user_id"`
+		UserLogin  string    `json:"user_login"`
+		UserName   string    `json:"user_name"`
+		FollowedAt time.Time `json:"followed_at"`
+	}
+
+	// ChannelFollowerListing contains followers of a channel and the
+	// total number of followers
+	ChannelFollowerListing struct {
+		Total      int64             `json:"total"`
+		Data       []ChannelFollower `json:"data"`
+		Pagination struct {
+			Cursor string `json:"cursor"`
+		} `json:"pagination"`
+	}
+
+	// ChannelInformation contains the details of a channel
+	ChannelInformation struct {
+		BroadcasterID               string   `json:"broadcaster_id"`
+		BroadcasterLogin            string   `json:"broadcaster_login"`
+		BroadcasterName             string   `json:"broadcaster_name"`
+		BroadcasterLanguage         string   `json:"broadcaster_language"`
+		GameID                      string   `json:"game_id"`
+		GameName                    string   `json:"game_name"`
+		Title                       string   `json:"title"`
+		Delay                       int64    `json:"delay"`
+		Tags                        []string `json:"tags"`
+		ContentClassificationLabels []string `json:"content_classification_labels"`
+		IsBrandedContent            bool     `json:"is_branded_content"`
+	}
+
+	// ChannelInformationListing contains channels
+	ChannelInformationListing struct {
+		Data []ChannelInformation `json:"data"`
+	}
+
+	// SearchChannel contains a channel found by SearchChannels
+	SearchChannel struct {
+		ID                  string   `json:"id"`
+		BroadcasterLogin    string   `json:"broadcaster_login"`
+		DisplayName         string   `json:"display_name"`
+		BroadcasterLanguage string   `json:"broadcaster_language"`
+		GameID              string   `json:"game_id"`
+		GameName            string   `json:"game_name"`
+		IsLive              bool     `json:"is_live"`
+		Tags                []string `json:"tags"`
+		ThumbnailURL        string   `json:"thumbnail_url"`
+		Title               string   `json:"title"`
+		// StartedAt is an RFC3339 timestamp or empty when the channel
+		// is not live
+		StartedAt string `json:"started_at"`
+	}
+
+	// SearchChannelListing contains channels found by a search
+	SearchChannelListing struct {
+		Data       []SearchChannel `json:"data"`
+		Pagination struct {
+			Cursor string `json:"cursor"`
+		} `json:"pagination"`
+	}
+)
+
+// ChannelFollowers iterates over the followers of the broadcaster,
+// newest first. This requires a user access token of the broadcaster
+// or one of its moderators having the `moderator:read:followers`
+// scope. Stopping the iteration stops fetching pages. An error ends
+// the iteration.
+func (t Adapter) ChannelFollowers(ctx context.Context, broadcasterID string) iter.Seq2[ChannelFollower, error] {
+	return getPaginated[ChannelFollower](ctx, t, "/helix/channels/followers", url.Values{"broadcaster_id": {broadcasterID}}, maxBatchSize)
+}
+
+// GetChannelFollowerCount returns the number of followers of the
+// broadcaster which is available using any token
+func (t Adapter) GetChannelFollowerCount(ctx context.Context, broadcasterID string) (int64, error) {
+	var out ChannelFollowerListing
+
+	params := make(url.Values)
+	params.Set("broadcaster_id", broadcasterID)
+	params.Set("first", "1")
+
+	if err := t.retry(ctx, "/helix/channels/followers", func() error {
+		return t.request(ctx, http.MethodGet, "/helix/channels/followers", params, nil, &out)
+	}); err != nil {
+		return 0, fmt.Errorf("getting followers: %w", err)
+	}
+
+	return out.Total, nil
+}
+
+// GetChannelInformation returns the details of the channels of the
+// given broadcasters
+func (t Adapter) GetChannelInformation(ctx context.Context, broadcasterIDs ...string) (*ChannelInformationListing, error) {
+	channels, err := collect(lookup[ChannelInformation](ctx, t, "/helix/channels", "broadcaster_id", broadcasterIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChannelInformationListing{Data: channels}, nil
+}
+
+// SearchChannels iterates over the channels matching the query,
+// optionally only the live ones. Stopping the iteration stops
+// fetching pages. An error ends the iteration.
+func (t Adapter) SearchChannels(ctx context.Context, query string, liveOnly bool) iter.Seq2[SearchChannel, error] {
+	params := make(url.Values)
+	params.Set("query", query)
+	params.Set("live_only", strconv.FormatBool(liveOnly))
+
+	return getPaginated[SearchChannel](ctx, t, "/helix/search/channels", params, maxBatchSize)
+}
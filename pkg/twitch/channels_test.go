@@ -0,0 +1,101 @@
+package twitch_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+func TestChannelFollowers(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	followers := make([]twitch.ChannelFollower, 150)
+	for i := range followers {
+		followers[i] = twitch.ChannelFollower{UserID: fmt.Sprint(i), FollowedAt: time.Now().Add(-time.Duration(i) * time.Hour)}
+	}
+	srv.SetFollowers("1", followers...)
+
+	// The total is available to app-access-tokens
+	count, err := srv.Adapter().GetChannelFollowerCount(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("fetching follower count: %s", err)
+	}
+	if count != int64(len(followers)) {
+		t.Errorf("expected %d followers, got %d", len(followers), count)
+	}
+
+	var ids []string
+	for follower, err := range srv.Adapter(twitch.WithToken(twitchtest.UserToken)).ChannelFollowers(context.Background(), "1") {
+		if err != nil {
+			t.Fatalf("iterating followers: %s", err)
+		}
+		ids = append(ids, follower.UserID)
+	}
+
+	if len(ids) != len(followers) || ids[0] != "0" || ids[149] != "149" {
+		t.Errorf("expected all followers newest first, got %d followers", len(ids))
+	}
+}
+
+func TestGetChannelInformation(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetChannels(
+		twitch.ChannelInformation{BroadcasterID: "1", BroadcasterLogin: "luziferus", Title: "Coding"},
+		twitch.ChannelInformation{BroadcasterID: "2", BroadcasterLogin: "other", Title: "Chatting"},
+	)
+
+	channels, err := srv.Adapter().GetChannelInformation(context.Background(), "2", "unknown")
+	if err != nil {
+		t.Fatalf("fetching channels: %s", err)
+	}
+	if len(channels.Data) != 1 || channels.Data[0].Title != "Chatting" {
+		t.Errorf("unexpected channels %+v", channels.Data)
+	}
+}
+
+func TestSearchChannels(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	adapter := srv.Adapter()
+
+	srv.SetChannels(
+		twitch.ChannelInformation{BroadcasterID: "1", BroadcasterLogin: "luziferus", BroadcasterName: "Luziferus"},
+		twitch.ChannelInformation{BroadcasterID: "2", BroadcasterLogin: "luzi_offline", BroadcasterName: "Luzi_Offline"},
+		twitch.ChannelInformation{BroadcasterID: "3", BroadcasterLogin: "other", BroadcasterName: "Other"},
+	)
+	srv.SetStreams(twitch.Stream{ID: "10", UserID: "1", UserLogin: "luziferus", StartedAt: time.Now()})
+
+	for name, tc := range map[string]struct {
+		liveOnly bool
+		expected string
+	}{
+		"all":       {liveOnly: false, expected: "[1 2]"},
+		"live only": {liveOnly: true, expected: "[1]"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var ids []string
+			for channel, err := range adapter.SearchChannels(context.Background(), "LUZI", tc.liveOnly) {
+				if err != nil {
+					t.Fatalf("searching channels: %s", err)
+				}
+
+				if channel.IsLive != (channel.StartedAt != "") {
+					t.Errorf("expected start time only for live channels, got %+v", channel)
+				}
+				ids = append(ids, channel.ID)
+			}
+
+			if fmt.Sprint(ids) != tc.expected {
+				t.Errorf("expected channels %s, got %v", tc.expected, ids)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package twitch
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"time"
+)
+
+type (
+	// Clip contains the details of a clip
+	Clip struct {
+		ID              string    `json:"id"`
+		URL             string    `json:"url"`
+		EmbedURL        string    `json:"embed_url"`
+		BroadcasterID   string    `json:"broadcaster_id"`
+		BroadcasterName string    `json:"broadcaster_name"`
+		CreatorID       string    `json:"creator_id"`
+		CreatorName     string    `json:"creator_name"`
+		VideoID         string    `json:"video_id"`
+		GameID          string    `json:"game_id"`
+		Language        string    `json:"language"`
+		Title           string    `json:"title"`
+		ViewCount       int64     `json:"view_count"`
+		CreatedAt       time.Time `json:"created_at"`
+		ThumbnailURL    string    `json:"thumbnail_url"`
+		// Duration is the length of the clip in seconds
+		Duration float64 `json:"duration"`
+		// VODOffset is the offset of the clip in the video in seconds,
+		// nil when the video is not available
+		VODOffset  *int64 `json:"vod_offset"`
+		IsFeatured bool   `json:"is_featured"`
+	}
+
+	// ClipListing contains clips
+	ClipListing struct {
+		Data       []Clip `json:"data"`
+		Pagination struct {
+			Cursor string `json:"cursor"`
+		} `json:"pagination"`
+	}
+)
+
+// Clips iterates over the clips of the broadcaster, ordered by views.
+// When startedAt is given only clips created since then (and before
+// endedAt when given) are returned. Stopping the iteration stops
+// fetching pages. An error ends the iteration.
+func (t Adapter) Clips(ctx context.Context, broadcasterID string, startedAt, endedAt *time.Time) iter.Seq2[Clip, error] {
+	params := make(url.Values)
+	params.Set("broadcaster_id", broadcasterID)
+	if startedAt != nil {
+		params.Set("started_at", startedAt.Format(time.RFC3339))
+	}
+	if endedAt != nil {
+		params.Set("ended_at", endedAt.Format(time.RFC3339))
+	}
+
+	return getPaginated[Clip](ctx, t, "/helix/clips", params, maxBatchSize)
+}
+
+// GetClipsByID returns the clips with the given IDs
+func (t Adapter) GetClipsByID(ctx context.Context, ids ...string) (*ClipListing, error) {
+	clips, err := collect(lookup[Clip](ctx, t, "/helix/clips", "id", ids))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClipListing{Data: clips}, nil
+}
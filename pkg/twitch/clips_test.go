@@ -0,0 +1,77 @@
+package twitch_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+func TestClips(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	adapter := srv.Adapter()
+
+	var (
+		now   = time.Now().Truncate(time.Second)
+		clips []twitch.Clip
+	)
+
+	// More clips than fit into one page, one per hour
+	for i := range 150 {
+		clips = append(clips, twitch.Clip{
+			ID:            fmt.Sprintf("clip%d", i),
+			BroadcasterID: "1",
+			CreatedAt:     now.Add(-time.Duration(i) * time.Hour),
+		})
+	}
+	clips = append(clips, twitch.Clip{ID: "other", BroadcasterID: "2", CreatedAt: now})
+	srv.SetClips(clips...)
+
+	var found int
+	for clip, err := range adapter.Clips(context.Background(), "1", nil, nil) {
+		if err != nil {
+			t.Fatalf("iterating clips: %s", err)
+		}
+		if clip.BroadcasterID != "1" {
+			t.Errorf("unexpected clip of broadcaster %q", clip.BroadcasterID)
+		}
+		found++
+	}
+
+	if found != 150 {
+		t.Errorf("expected 150 clips from all pages, got %d", found)
+	}
+	if n := srv.Requests("/helix/clips"); n != 2 {
+		t.Errorf("expected 2 pages, got %d requests", n)
+	}
+
+	var (
+		startedAt = now.Add(-10 * time.Hour)
+		endedAt   = now.Add(-5 * time.Hour)
+		ids       []string
+	)
+
+	for clip, err := range adapter.Clips(context.Background(), "1", &startedAt, &endedAt) {
+		if err != nil {
+			t.Fatalf("iterating clips: %s", err)
+		}
+		ids = append(ids, clip.ID)
+	}
+
+	if len(ids) != 6 || ids[0] != "clip5" || ids[5] != "clip10" {
+		t.Errorf("expected clips between start and end, got %v", ids)
+	}
+
+	byID, err := adapter.GetClipsByID(context.Background(), "clip3", "other")
+	if err != nil {
+		t.Fatalf("fetching clips by ID: %s", err)
+	}
+	if len(byID.Data) != 2 {
+		t.Errorf("unexpected clips by ID %+v", byID.Data)
+	}
+}
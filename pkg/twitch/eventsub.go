@@ -0,0 +1,539 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/metrics"
+)
+
+const (
+	// EventSubTypeChannelUpdate is sent when the broadcaster updates
+	// the title, category or language of the channel
+	EventSubTypeChannelUpdate = "channel.update"
+	// EventSubTypeStreamOffline is sent when the broadcaster stops a
+	// stream
+	EventSubTypeStreamOffline = "stream.offline"
+	// EventSubTypeStreamOnline is sent when the broadcaster starts a
+	// stream
+	EventSubTypeStreamOnline = "stream.online"
+
+	eventSubKeepaliveGrace     = 5 * time.Second
+	eventSubMaxParallelCreates = 10
+	eventSubMaxReconnectDelay  = 5 * time.Minute
+	eventSubMinReconnectDelay  = time.Second
+	eventSubSeenMessages       = 100
+	eventSubWelcomeTimeout     = 10 * time.Second
+)
+
+type (
+	// ChannelUpdateEvent is the event of a channel.update notification
+	ChannelUpdateEvent struct {
+		BroadcasterUserID           string   `json:"broadcaster_user_id"`
+		BroadcasterUserLogin        string   `json:"broadcaster_user_login"`
+		BroadcasterUserName         string   `json:"broadcaster_user_name"`
+		Title                       string   `json:"title"`
+		Language                    string   `json:"language"`
+		CategoryID                  string   `json:"category_id"`
+		CategoryName                string   `json:"category_name"`
+		ContentClassificationLabels []string `json:"content_classification_labels"`
+	}
+
+	// EventSubClient maintains an EventSub WebSocket session, creates
+	// the subscriptions for every new session and passes the received
+	// notifications to its handler. Sessions are re-established after
+	// connection failures and keepalive timeouts, reconnect requests
+	// of the server are followed keeping the subscriptions.
+	EventSubClient struct {
+		adapter       Adapter
+		handler       EventSubHandler
+		subscriptions EventSubSubscriptionsFunc
+
+		active   map[string]struct{}
+		cancel   context.CancelFunc
+		done     chan struct{}
+		handlers sync.WaitGroup
+		lock     sync.Mutex
+		seen     eventSubMessageLog
+	}
+
+	// EventSubHandler is called for every received notification. The
+	// context is cancelled when the client is stopped.
+	EventSubHandler func(ctx context.Context, n EventSubNotification)
+
+	// EventSubNotification contains a notification received through
+	// an EventSub session
+	EventSubNotification struct {
+		Subscription EventSubSubscription
+		Event        json.RawMessage
+	}
+
+	// EventSubSubscription describes an EventSub subscription by its
+	// type, version and condition
+	EventSubSubscription struct {
+		Type      string            `json:"type"`
+		Version   string            `json:"version"`
+		Condition map[string]string `json:"condition"`
+	}
+
+	// EventSubSubscriptionsFunc returns the subscriptions to create
+	// for a new session
+	EventSubSubscriptionsFunc func(ctx context.Context) ([]EventSubSubscription, error)
+
+	// StreamOfflineEvent is the event of a stream.offline notification
+	StreamOfflineEvent struct {
+		BroadcasterUserID    string `json:"broadcaster_user_id"`
+		BroadcasterUserLogin string `json:"broadcaster_user_login"`
+		BroadcasterUserName  string `json:"broadcaster_user_name"`
+	}
+
+	// StreamOnlineEvent is the event of a stream.online notification
+	StreamOnlineEvent struct {
+		ID                   string    `json:"id"`
+		BroadcasterUserID    string    `json:"broadcaster_user_id"`
+		BroadcasterUserLogin string    `json:"broadcaster_user_login"`
+		BroadcasterUserName  string    `json:"broadcaster_user_name"`
+		Type                 string    `json:"type"`
+		StartedAt            time.Time `json:"started_at"`
+	}
+
+	eventSubMessage struct {
+		Metadata struct {
+			MessageID           string `json:"message_id"`
+			MessageType         string `json:"message_type"`
+			SubscriptionType    string `json:"subscription_type"`
+			SubscriptionVersion string `json:"subscription_version"`
+		} `json:"metadata"`
+		Payload struct {
+			Session      *eventSubSession `json:"session"`
+			Subscription *struct {
+				EventSubSubscription
+				Status string `json:"status"`
+			} `json:"subscription"`
+			Event json.RawMessage `json:"event"`
+		} `json:"payload"`
+	}
+
+	// eventSubMessageLog remembers the IDs of the last handled messages
+	// to suppress redeliveries
+	eventSubMessageLog struct {
+		ids  []string
+		lock sync.Mutex
+	}
+
+	eventSubSession struct {
+		ID                      string `json:"id"`
+		KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+		ReconnectURL            string `json:"reconnect_url"`
+	}
+)
+
+// ErrEventSubUserToken signals the Adapter passed to the
+// EventSubClient has no user access token, which is required for
+// WebSocket subscriptions
+var ErrEventSubUserToken = errors.New("EventSub via WebSocket requires a user access token")
+
+// ChannelUpdateSubscription returns the channel.update subscription
+// for the given broadcaster
+func ChannelUpdateSubscription(broadcasterID string) EventSubSubscription {
+	return EventSubSubscription{
+		Type:      EventSubTypeChannelUpdate,
+		Version:   "2",
+		Condition: map[string]string{"broadcaster_user_id": broadcasterID},
+	}
+}
+
+// StreamOfflineSubscription returns the stream.offline subscription
+// for the given broadcaster
+func StreamOfflineSubscription(broadcasterID string) EventSubSubscription {
+	return EventSubSubscription{
+		Type:      EventSubTypeStreamOffline,
+		Version:   "1",
+		Condition: map[string]string{"broadcaster_user_id": broadcasterID},
+	}
+}
+
+// StreamOnlineSubscription returns the stream.online subscription
+// for the given broadcaster
+func StreamOnlineSubscription(broadcasterID string) EventSubSubscription {
+	return EventSubSubscription{
+		Type:      EventSubTypeStreamOnline,
+		Version:   "1",
+		Condition: map[string]string{"broadcaster_user_id": broadcasterID},
+	}
+}
+
+// NewEventSubClient creates a new EventSubClient using the given
+// Adapter, which must use a user access token (static or through a
+// UserTokenSource). The subscriptions function is called for every new
+// session.
+func NewEventSubClient(adapter *Adapter, subscriptions EventSubSubscriptionsFunc, handler EventSubHandler) *EventSubClient {
+	return &EventSubClient{
+		adapter:       *adapter,
+		handler:       handler,
+		subscriptions: subscriptions,
+
+		active: make(map[string]struct{}),
+	}
+}
+
+// IsActive reports whether the subscription was created for the
+// current session and was not revoked since
+func (c *EventSubClient) IsActive(sub EventSubSubscription) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, ok := c.active[sub.key()]
+	return ok
+}
+
+// Start connects to the EventSub server in the background and keeps
+// the session alive until Stop is called
+func (c *EventSubClient) Start() error {
+	if !c.adapter.hasUserToken() {
+		return ErrEventSubUserToken
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.done != nil {
+		return errors.New("client already started")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+		c.run(ctx)
+	}()
+
+	return nil
+}
+
+// Stop closes the session and waits for running handlers to finish or
+// the context to be cancelled
+func (c *EventSubClient) Stop(ctx context.Context) error {
+	c.lock.Lock()
+	cancel, done := c.cancel, c.done
+	c.lock.Unlock()
+
+	if done == nil {
+		return nil
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for EventSub client: %w", ctx.Err())
+	}
+}
+
+// Decode unmarshals the event of the notification into the given
+// event type (e.g. StreamOnlineEvent)
+func (n EventSubNotification) Decode(out any) error {
+	if err := json.Unmarshal(n.Event, out); err != nil {
+		return fmt.Errorf("decoding event: %w", err)
+	}
+
+	return nil
+}
+
+// connect dials the EventSub server and waits for the welcome message
+func (*EventSubClient) connect(ctx context.Context, url string) (*websocket.Conn, *eventSubSession, error) {
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("dialing: %w", err)
+	}
+	if err = resp.Body.Close(); err != nil {
+		logrus.WithError(err).Error("closing EventSub handshake body")
+	}
+
+	if err = conn.SetReadDeadline(time.Now().Add(eventSubWelcomeTimeout)); err != nil {
+		return nil, nil, errors.Join(fmt.Errorf("setting read deadline: %w", err), conn.Close())
+	}
+
+	var msg eventSubMessage
+	if err = conn.ReadJSON(&msg); err != nil {
+		return nil, nil, errors.Join(fmt.Errorf("reading welcome message: %w", err), conn.Close())
+	}
+	metrics.TwitchEventSubMessages.WithLabelValues(msg.Metadata.MessageType).Inc()
+
+	if msg.Metadata.MessageType != "session_welcome" || msg.Payload.Session == nil {
+		return nil, nil, errors.Join(fmt.Errorf("unexpected message %q instead of welcome", msg.Metadata.MessageType), conn.Close())
+	}
+
+	return conn, msg.Payload.Session, nil
+}
+
+// createSubscriptions creates the subscriptions returned by the
+// subscriptions function for the session and records the successfully
+// created ones as active. Twitch closes sessions without subscription
+// shortly after the welcome message, so the subscriptions are created
+// in parallel instead of waiting for retries of single ones.
+func (c *EventSubClient) createSubscriptions(ctx context.Context, sessionID string) error {
+	subs, err := c.subscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("getting subscriptions: %w", err)
+	}
+
+	var (
+		active     = make(map[string]struct{})
+		activeLock sync.Mutex
+		slots      = make(chan struct{}, eventSubMaxParallelCreates)
+		wg         sync.WaitGroup
+	)
+
+	for _, sub := range subs {
+		slots <- struct{}{}
+
+		wg.Go(func() {
+			defer func() { <-slots }()
+
+			if err := c.adapter.createEventSubSubscription(ctx, sub, sessionID); err != nil {
+				// Single subscriptions might fail (e.g. subscription limits
+				// reached) which must not stop the others from working
+				logrus.WithError(err).WithFields(logrus.Fields{
+					"condition": sub.Condition,
+					"type":      sub.Type,
+				}).Error("creating EventSub subscription")
+				return
+			}
+
+			activeLock.Lock()
+			defer activeLock.Unlock()
+
+			active[sub.key()] = struct{}{}
+		})
+	}
+
+	wg.Wait()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.active = active
+
+	return nil
+}
+
+// dispatch passes the notification to the handler in the background
+// unless it is a redelivery of an already handled message
+func (c *EventSubClient) dispatch(ctx context.Context, msg eventSubMessage) {
+	if c.seen.seenBefore(msg.Metadata.MessageID) || msg.Payload.Subscription == nil {
+		return
+	}
+
+	n := EventSubNotification{
+		Subscription: msg.Payload.Subscription.EventSubSubscription,
+		Event:        msg.Payload.Event,
+	}
+
+	c.handlers.Go(func() { c.handler(ctx, n) })
+}
+
+// run establishes sessions until the context is cancelled, waiting
+// with increasing delays between failed sessions
+func (c *EventSubClient) run(ctx context.Context) {
+	defer c.handlers.Wait()
+
+	delay := eventSubMinReconnectDelay
+	for {
+		start := time.Now()
+		err := c.runSession(ctx)
+
+		c.lock.Lock()
+		c.active = make(map[string]struct{})
+		c.lock.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(start) > eventSubMaxReconnectDelay {
+			// Session was working for a while, reconnect quickly
+			delay = eventSubMinReconnectDelay
+		}
+
+		logrus.WithError(err).WithField("retry_in", delay).Warn("EventSub session ended")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay = min(2*delay, eventSubMaxReconnectDelay) //nolint:mnd // Double the delay
+	}
+}
+
+// runSession connects a new session, creates the subscriptions and
+// reads messages until the connection fails or the context is
+// cancelled
+//
+//nolint:funlen,gocyclo // Message loop, splitting would scatter the connection handling
+func (c *EventSubClient) runSession(ctx context.Context) error {
+	conn, session, err := c.connect(ctx, c.adapter.eventSubURL)
+	if err != nil {
+		return fmt.Errorf("connecting: %w", err)
+	}
+
+	// The connection is replaced on reconnect requests, the lock guards
+	// closing it on cancellation while it is replaced
+	var connLock sync.Mutex
+	defer func() {
+		connLock.Lock()
+		defer connLock.Unlock()
+
+		if err := conn.Close(); err != nil {
+			logrus.WithError(err).Debug("closing EventSub connection")
+		}
+	}()
+
+	stop := context.AfterFunc(ctx, func() {
+		connLock.Lock()
+		defer connLock.Unlock()
+
+		if err := conn.Close(); err != nil {
+			logrus.WithError(err).Debug("closing EventSub connection")
+		}
+	})
+	defer stop()
+
+	if err = c.createSubscriptions(ctx, session.ID); err != nil {
+		return fmt.Errorf("creating subscriptions: %w", err)
+	}
+
+	logrus.WithField("session", session.ID).Debug("EventSub session established")
+
+	for {
+		keepalive := time.Duration(session.KeepaliveTimeoutSeconds) * time.Second
+		if err = conn.SetReadDeadline(time.Now().Add(keepalive + eventSubKeepaliveGrace)); err != nil {
+			return fmt.Errorf("setting read deadline: %w", err)
+		}
+
+		var msg eventSubMessage
+		if err = conn.ReadJSON(&msg); err != nil {
+			return fmt.Errorf("reading message: %w", err)
+		}
+		metrics.TwitchEventSubMessages.WithLabelValues(msg.Metadata.MessageType).Inc()
+
+		switch msg.Metadata.MessageType {
+		case "notification":
+			c.dispatch(ctx, msg)
+
+		case "revocation":
+			if msg.Payload.Subscription == nil {
+				continue
+			}
+
+			logrus.WithFields(logrus.Fields{
+				"condition": msg.Payload.Subscription.Condition,
+				"status":    msg.Payload.Subscription.Status,
+				"type":      msg.Payload.Subscription.Type,
+			}).Warn("EventSub subscription revoked")
+
+			c.lock.Lock()
+			delete(c.active, msg.Payload.Subscription.key())
+			c.lock.Unlock()
+
+		case "session_keepalive":
+			// Read deadline is extended with the next message
+
+		case "session_reconnect":
+			if msg.Payload.Session == nil {
+				continue
+			}
+
+			// Subscriptions are moved to the new connection, the old
+			// one must be kept until the new one is welcomed
+			newConn, newSession, err := c.connect(ctx, msg.Payload.Session.ReconnectURL)
+			if err != nil {
+				return fmt.Errorf("reconnecting: %w", err)
+			}
+
+			connLock.Lock()
+			if err := conn.Close(); err != nil {
+				logrus.WithError(err).Debug("closing replaced EventSub connection")
+			}
+			conn, session = newConn, newSession
+			connLock.Unlock()
+
+			logrus.WithField("session", session.ID).Debug("EventSub session reconnected")
+		}
+	}
+}
+
+// createEventSubSubscription subscribes the session to the given
+// subscription
+func (t Adapter) createEventSubSubscription(ctx context.Context, sub EventSubSubscription, sessionID string) error {
+	payload, err := json.Marshal(struct {
+		EventSubSubscription
+		Transport struct {
+			Method    string `json:"method"`
+			SessionID string `json:"session_id"`
+		} `json:"transport"`
+	}{
+		EventSubSubscription: sub,
+		Transport: struct {
+			Method    string `json:"method"`
+			SessionID string `json:"session_id"`
+		}{Method: "websocket", SessionID: sessionID},
+	})
+	if err != nil {
+		return fmt.Errorf("encoding subscription: %w", err)
+	}
+
+	if err = t.retry(ctx, "/helix/eventsub/subscriptions", func() error {
+		return t.request(ctx, http.MethodPost, "/helix/eventsub/subscriptions", nil, bytes.NewReader(payload), nil)
+	}); err != nil {
+		return fmt.Errorf("creating subscription: %w", err)
+	}
+
+	return nil
+}
+
+// seenBefore records the message ID and reports whether it was
+// already recorded before
+func (l *eventSubMessageLog) seenBefore(id string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if slices.Contains(l.ids, id) {
+		return true
+	}
+
+	l.ids = append(l.ids, id)
+	if len(l.ids) > eventSubSeenMessages {
+		l.ids = l.ids[len(l.ids)-eventSubSeenMessages:]
+	}
+
+	return false
+}
+
+// key returns a string identifying the subscription
+func (s EventSubSubscription) key() string {
+	parts := []string{s.Type, s.Version}
+	for _, k := range slices.Sorted(maps.Keys(s.Condition)) {
+		parts = append(parts, k+"="+s.Condition[k])
+	}
+
+	return strings.Join(parts, "|")
+}
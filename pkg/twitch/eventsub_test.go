@@ -0,0 +1,64 @@
+package twitch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+func TestEventSubClient(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	subs := []twitch.EventSubSubscription{
+		twitch.ChannelUpdateSubscription("1"),
+		twitch.StreamOfflineSubscription("1"),
+		twitch.StreamOnlineSubscription("1"),
+	}
+
+	// Failing requests are retried without blocking the others
+	srv.FailNext("/helix/eventsub/subscriptions", http.StatusInternalServerError, http.StatusInternalServerError)
+
+	notifications := make(chan twitch.EventSubNotification, 1)
+	client := twitch.NewEventSubClient(
+		srv.UserAdapter(twitch.WithEventSubURL(srv.EventSubURL())),
+		func(context.Context) ([]twitch.EventSubSubscription, error) { return subs, nil },
+		func(_ context.Context, n twitch.EventSubNotification) { notifications <- n },
+	)
+
+	if err := client.Start(); err != nil {
+		t.Fatalf("starting client: %s", err)
+	}
+	defer client.Stop(context.Background()) //nolint:errcheck // Only stopping the test client
+
+	waitFor(t, "subscriptions to be active", func() bool {
+		for _, sub := range subs {
+			if !client.IsActive(sub) {
+				return false
+			}
+		}
+		return true
+	})
+
+	if n := len(srv.Subscriptions()); n != len(subs) {
+		t.Errorf("expected %d subscriptions, got %d", len(subs), n)
+	}
+
+	if _, err := srv.Notify(subs[0], twitch.ChannelUpdateEvent{BroadcasterUserID: "1", Title: "New title"}); err != nil {
+		t.Fatalf("sending notification: %s", err)
+	}
+
+	select {
+	case n := <-notifications:
+		var evt twitch.ChannelUpdateEvent
+		if err := n.Decode(&evt); err != nil || evt.Title != "New title" {
+			t.Errorf("unexpected notification %+v (%v)", evt, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notification was not passed to the handler")
+	}
+}
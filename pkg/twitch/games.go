@@ -0,0 +1,42 @@
+package twitch
+
+import (
+	"context"
+)
+
+type (
+	// Game contains the details of a game or category
+	Game struct {
+		ID string `json:"id"`
+		// BoxArtURL contains `{width}` and `{height}` placeholders to
+		// be replaced with the desired size
+		BoxArtURL string `json:"box_art_url"`
+		Name      string `json:"name"`
+		IGDBID    string `json:"igdb_id"`
+	}
+
+	// GameListing contains games
+	GameListing struct {
+		Data []Game `json:"data"`
+	}
+)
+
+// GetGamesByID returns the games with the given IDs
+func (t Adapter) GetGamesByID(ctx context.Context, ids ...string) (*GameListing, error) {
+	games, err := collect(lookup[Game](ctx, t, "/helix/games", "id", ids))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GameListing{Data: games}, nil
+}
+
+// GetGamesByName returns the games with the given exact names
+func (t Adapter) GetGamesByName(ctx context.Context, names ...string) (*GameListing, error) {
+	games, err := collect(lookup[Game](ctx, t, "/helix/games", "name", names))
+	if err != nil {
+		return nil, err
+	}
+
+	return &GameListing{Data: games}, nil
+}
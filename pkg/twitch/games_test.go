@@ -0,0 +1,50 @@
+package twitch_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+func TestGetGames(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	adapter := srv.Adapter()
+
+	srv.SetGames(
+		twitch.Game{ID: "1", Name: "Science & Technology"},
+		twitch.Game{ID: "2", Name: "Just Chatting"},
+		twitch.Game{ID: "3", Name: "Software and Game Development"},
+	)
+
+	byID, err := adapter.GetGamesByID(context.Background(), "1", "3", "unknown")
+	if err != nil {
+		t.Fatalf("fetching games by ID: %s", err)
+	}
+	if len(byID.Data) != 2 || byID.Data[0].ID != "1" || byID.Data[1].ID != "3" {
+		t.Errorf("unexpected games by ID %+v", byID.Data)
+	}
+
+	byName, err := adapter.GetGamesByName(context.Background(), "just chatting")
+	if err != nil {
+		t.Fatalf("fetching games by name: %s", err)
+	}
+	if len(byName.Data) != 1 || byName.Data[0].ID != "2" {
+		t.Errorf("unexpected games by name %+v", byName.Data)
+	}
+
+	// Without IDs there is nothing to look up
+	none, err := adapter.GetGamesByID(context.Background())
+	if err != nil {
+		t.Fatalf("fetching no games: %s", err)
+	}
+	if len(none.Data) != 0 {
+		t.Errorf("expected no games, got %+v", none.Data)
+	}
+	if n := srv.Requests("/helix/games"); n != 2 {
+		t.Errorf("expected empty lookup not to be requested, got %d requests", n)
+	}
+}
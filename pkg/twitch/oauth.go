@@ -0,0 +1,265 @@
+package twitch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// userTokenRefreshMargin is the time before expiry a user access
+// token is refreshed
+const userTokenRefreshMargin = 5 * time.Minute
+
+type (
+	// UserToken contains the credentials of a user who authorized the
+	// app using the authorization code flow
+	UserToken struct {
+		AccessToken  string    `json:"access_token"`  //#nosec:G117 // Intended to work with secrets
+		RefreshToken string    `json:"refresh_token"` //#nosec:G117 // Intended to work with secrets
+		ExpiresAt    time.Time `json:"expires_at"`
+		Scopes       []string  `json:"scopes"`
+		UserID       string    `json:"user_id"`
+		UserLogin    string    `json:"user_login"`
+	}
+
+	// UserTokenSource provides the access token of one user, refreshes
+	// it before it expires and persists the refreshed token in the
+	// UserTokenStore
+	UserTokenSource struct {
+		adapter Adapter
+		invalid string
+		lock    sync.Mutex
+		login   string
+		store   UserTokenStore
+	}
+
+	// UserTokenStore persists the tokens of the users who authorized
+	// the app
+	UserTokenStore interface {
+		// GetUserToken returns the token of the user with the given
+		// login or ErrUserTokenNotFound
+		GetUserToken(login string) (*UserToken, error)
+		// SetUserToken stores the token replacing the previous token
+		// of the user
+		SetUserToken(token UserToken) error
+	}
+
+	// tokenResponse is returned by the token endpoint of the
+	// authentication API
+	tokenResponse struct {
+		AccessToken  string   `json:"access_token"`  //#nosec:G117 // Intended to work with secrets
+		RefreshToken string   `json:"refresh_token"` //#nosec:G117 // Intended to work with secrets
+		ExpiresIn    int      `json:"expires_in"`
+		Scope        []string `json:"scope"`
+		TokenType    string   `json:"token_type"`
+	}
+)
+
+// ErrUserTokenNotFound signals the user did not authorize the app
+var ErrUserTokenNotFound = errors.New("user did not authorize the app")
+
+// NewUserTokenSource creates a new UserTokenSource for the user with
+// the given login using the Adapter to refresh the token. The Adapter
+// must be configured with the client ID and secret of the app the
+// user authorized.
+func NewUserTokenSource(adapter *Adapter, store UserTokenStore, login string) *UserTokenSource {
+	return &UserTokenSource{
+		adapter: *adapter,
+		login:   strings.ToLower(login),
+		store:   store,
+	}
+}
+
+// WithUserTokenSource lets the Adapter use the access token of the
+// user provided by the source instead of an app-access-token
+func WithUserTokenSource(src *UserTokenSource) Option {
+	return func(a *Adapter) { a.userTokens = src }
+}
+
+// AuthorizationURL returns the URL to send the user to in order to
+// authorize the app for the given scopes. After authorization Twitch
+// redirects to the redirect URL passing the state and the code to
+// exchange using ExchangeCode.
+func (t Adapter) AuthorizationURL(redirectURL, state string, scopes []string) string {
+	params := make(url.Values)
+	params.Set("client_id", t.clientID)
+	params.Set("force_verify", "true")
+	params.Set("redirect_uri", redirectURL)
+	params.Set("response_type", "code")
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+
+	return t.idBaseURL + "/oauth2/authorize?" + params.Encode()
+}
+
+// ExchangeCode exchanges the code passed to the redirect URL for the
+// token of the user who authorized the app
+func (t Adapter) ExchangeCode(ctx context.Context, code, redirectURL string) (*UserToken, error) {
+	params := make(url.Values)
+	params.Set("client_id", t.clientID)
+	params.Set("client_secret", t.clientSecret)
+	params.Set("code", code)
+	params.Set("grant_type", "authorization_code")
+	params.Set("redirect_uri", redirectURL)
+
+	resp, err := t.requestToken(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("exchanging code: %w", err)
+	}
+
+	var user struct {
+		Login  string `json:"login"`
+		UserID string `json:"user_id"`
+	}
+
+	if err = t.requestIDAPI(ctx, http.MethodGet, "/oauth2/validate", nil, resp.AccessToken, &user); err != nil {
+		return nil, fmt.Errorf("validating token: %w", err)
+	}
+
+	return &UserToken{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+		Scopes:       resp.Scope,
+		UserID:       user.UserID,
+		UserLogin:    strings.ToLower(user.Login),
+	}, nil
+}
+
+// RefreshUserToken uses the refresh token to get a new access token
+// for the user
+func (t Adapter) RefreshUserToken(ctx context.Context, token UserToken) (*UserToken, error) {
+	params := make(url.Values)
+	params.Set("client_id", t.clientID)
+	params.Set("client_secret", t.clientSecret)
+	params.Set("grant_type", "refresh_token")
+	params.Set("refresh_token", token.RefreshToken)
+
+	resp, err := t.requestToken(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("refreshing token: %w", err)
+	}
+
+	token.AccessToken = resp.AccessToken
+	token.RefreshToken = resp.RefreshToken
+	token.ExpiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	token.Scopes = resp.Scope
+
+	return &token, nil
+}
+
+// RevokeUserToken revokes the access token of the user so it can no
+// longer be used
+func (t Adapter) RevokeUserToken(ctx context.Context, token UserToken) error {
+	params := make(url.Values)
+	params.Set("client_id", t.clientID)
+	params.Set("token", token.AccessToken)
+
+	if err := t.requestIDAPI(ctx, http.MethodPost, "/oauth2/revoke", params, "", nil); err != nil {
+		return fmt.Errorf("revoking token: %w", err)
+	}
+
+	return nil
+}
+
+// requestIDAPI executes a request against the authentication API
+// optionally authorized using the given token. The parameters are sent
+// as form body for POST requests to keep secrets out of URLs.
+func (t Adapter) requestIDAPI(ctx context.Context, method, path string, params url.Values, token string, output any) error {
+	var body io.Reader
+	u, _ := url.Parse(t.idBaseURL + path)
+	if method == http.MethodPost {
+		body = strings.NewReader(params.Encode())
+	} else {
+		u.RawQuery = params.Encode()
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "OAuth "+token)
+	}
+
+	resp, err := t.do(req, path)
+	if err != nil {
+		return fmt.Errorf("fetching response: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logrus.WithError(err).Error("closing Twitch response body")
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+
+	if output == nil {
+		return nil
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(output); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+
+	return nil
+}
+
+// requestToken requests a token from the token endpoint of the
+// authentication API
+func (t Adapter) requestToken(ctx context.Context, params url.Values) (*tokenResponse, error) {
+	out := &tokenResponse{}
+	if err := t.requestIDAPI(ctx, http.MethodPost, "/oauth2/token", params, "", out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Invalidate forces a refresh of the token on the next call to Token
+// if the stored token still is the given one
+func (u *UserTokenSource) Invalidate(token string) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.invalid = token
+}
+
+// Token returns the access token of the user and refreshes it when it
+// is about to expire or was invalidated
+func (u *UserTokenSource) Token(ctx context.Context) (string, error) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	token, err := u.store.GetUserToken(u.login)
+	if err != nil {
+		return "", fmt.Errorf("loading token of %s: %w", u.login, err)
+	}
+
+	if token.AccessToken != u.invalid && time.Until(token.ExpiresAt) > userTokenRefreshMargin {
+		return token.AccessToken, nil
+	}
+
+	refreshed, err := u.adapter.RefreshUserToken(ctx, *token)
+	if err != nil {
+		return "", fmt.Errorf("refreshing token of %s: %w", u.login, err)
+	}
+
+	if err = u.store.SetUserToken(*refreshed); err != nil {
+		return "", fmt.Errorf("storing token of %s: %w", u.login, err)
+	}
+
+	u.invalid = ""
+	return refreshed.AccessToken, nil
+}
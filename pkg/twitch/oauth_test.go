@@ -0,0 +1,203 @@
+package twitch_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+const testRedirectURL = "https://bot.example.com/twitch/callback"
+
+type memoryTokenStore struct {
+	lock   sync.Mutex
+	tokens map[string]twitch.UserToken
+	writes int
+}
+
+func (m *memoryTokenStore) GetUserToken(login string) (*twitch.UserToken, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	token, ok := m.tokens[login]
+	if !ok {
+		return nil, twitch.ErrUserTokenNotFound
+	}
+
+	return &token, nil
+}
+
+func (m *memoryTokenStore) SetUserToken(token twitch.UserToken) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.tokens == nil {
+		m.tokens = make(map[string]twitch.UserToken)
+	}
+
+	m.tokens[token.UserLogin] = token
+	m.writes++
+	return nil
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	adapter := srv.Adapter()
+
+	// Without an approving user the authorization is denied
+	if q := authorize(t, srv, adapter); q.Get("error") != "access_denied" || q.Get("code") != "" {
+		t.Errorf("expected authorization to be denied, got %v", q)
+	}
+
+	srv.SetAuthorizingUser(&twitch.User{ID: "1", Login: "Luziferus"})
+
+	q := authorize(t, srv, adapter)
+	if q.Get("state") != "state" {
+		t.Errorf("expected state to be passed back, got %q", q.Get("state"))
+	}
+
+	token, err := adapter.ExchangeCode(context.Background(), q.Get("code"), testRedirectURL)
+	if err != nil {
+		t.Fatalf("exchanging code: %s", err)
+	}
+
+	switch {
+	case token.AccessToken == "", token.RefreshToken == "":
+		t.Errorf("expected access and refresh token, got %+v", token)
+	case token.UserID != "1", token.UserLogin != "luziferus":
+		t.Errorf("expected token of the authorizing user, got %+v", token)
+	case strings.Join(token.Scopes, " ") != "moderator:read:followers":
+		t.Errorf("expected granted scopes, got %v", token.Scopes)
+	case time.Until(token.ExpiresAt) < 59*time.Minute:
+		t.Errorf("expected expiry from token lifetime, got %s", token.ExpiresAt)
+	}
+
+	// Codes can only be exchanged once
+	if _, err = adapter.ExchangeCode(context.Background(), q.Get("code"), testRedirectURL); err == nil {
+		t.Error("expected code to be rejected on second exchange")
+	}
+
+	// The token can be used for user requests and revoked
+	user := srv.Adapter(twitch.WithToken(token.AccessToken))
+	if _, err = user.GetUserByUsername(context.Background(), "luziferus"); err != nil {
+		t.Fatalf("using user token: %s", err)
+	}
+
+	if err = adapter.RevokeUserToken(context.Background(), *token); err != nil {
+		t.Fatalf("revoking token: %s", err)
+	}
+	if _, err = user.GetUserByUsername(context.Background(), "luziferus"); err == nil {
+		t.Error("expected revoked token to be rejected")
+	}
+}
+
+func TestUserTokenSource(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus"})
+	srv.SetAuthorizingUser(&twitch.User{ID: "1", Login: "luziferus"})
+
+	var (
+		adapter = srv.Adapter()
+		store   = &memoryTokenStore{}
+		src     = twitch.NewUserTokenSource(adapter, store, "Luziferus")
+		user    = srv.Adapter(twitch.WithUserTokenSource(src))
+	)
+
+	if _, err := src.Token(context.Background()); !errors.Is(err, twitch.ErrUserTokenNotFound) {
+		t.Errorf("expected missing authorization to be reported, got %v", err)
+	}
+
+	token, err := adapter.ExchangeCode(context.Background(), authorize(t, srv, adapter).Get("code"), testRedirectURL)
+	if err != nil {
+		t.Fatalf("exchanging code: %s", err)
+	}
+	if err = store.SetUserToken(*token); err != nil {
+		t.Fatalf("storing token: %s", err)
+	}
+
+	fetchUser := func() {
+		t.Helper()
+
+		if _, err := user.GetUserByUsername(context.Background(), "luziferus"); err != nil {
+			t.Fatalf("fetching user: %s", err)
+		}
+	}
+
+	// A valid token is used as stored
+	fetchUser()
+	if store.writes != 1 {
+		t.Errorf("expected valid token not to be refreshed, got %d writes", store.writes)
+	}
+
+	// A token about to expire is refreshed and the new refresh token
+	// stored as the old one can no longer be used
+	expiring := *token
+	expiring.ExpiresAt = time.Now().Add(time.Minute)
+	if err = store.SetUserToken(expiring); err != nil {
+		t.Fatalf("storing token: %s", err)
+	}
+
+	fetchUser()
+	fetchUser()
+
+	refreshed, err := store.GetUserToken("luziferus")
+	if err != nil {
+		t.Fatalf("loading token: %s", err)
+	}
+
+	switch {
+	case store.writes != 3:
+		t.Errorf("expected token to be refreshed once, got %d writes", store.writes)
+	case refreshed.AccessToken == token.AccessToken, refreshed.RefreshToken == token.RefreshToken:
+		t.Errorf("expected new tokens to be stored, got %+v", refreshed)
+	case time.Until(refreshed.ExpiresAt) < 59*time.Minute:
+		t.Errorf("expected new expiry to be stored, got %s", refreshed.ExpiresAt)
+	}
+
+	if _, err = adapter.RefreshUserToken(context.Background(), *token); err == nil {
+		t.Error("expected used refresh token to be rejected")
+	}
+
+	// A rejected token is refreshed and the request retried
+	srv.RevokeTokens()
+	fetchUser()
+
+	if store.writes != 4 {
+		t.Errorf("expected rejected token to be refreshed, got %d writes", store.writes)
+	}
+}
+
+// authorize sends the user to the authorization URL and returns the
+// parameters passed to the redirect URL
+func authorize(t *testing.T, srv *twitchtest.Server, adapter *twitch.Adapter) url.Values {
+	t.Helper()
+
+	client := &http.Client{
+		Transport:     srv.Client().Transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+
+	resp, err := client.Get(adapter.AuthorizationURL(testRedirectURL, "state", []string{"moderator:read:followers"}))
+	if err != nil {
+		t.Fatalf("requesting authorization: %s", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck // Body is not read
+
+	redirect, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(redirect.String(), testRedirectURL) {
+		t.Fatalf("expected redirect to the app, got %q (%v)", resp.Header.Get("Location"), err)
+	}
+
+	return redirect.Query()
+}
@@ -0,0 +1,141 @@
+package twitch_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+func TestScheduleSegmentsPagination(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	adapter := srv.Adapter()
+
+	segments := make([]twitch.ScheduleSegment, 60)
+	for i := range segments {
+		start := time.Now().Add(time.Duration(i+1) * time.Hour)
+		segments[i] = twitch.ScheduleSegment{ID: fmt.Sprintf("seg-%d", i), StartTime: &start}
+	}
+	srv.SetSchedule("1", segments...)
+
+	schedule, err := adapter.GetChannelStreamSchedule(context.Background(), "1", nil)
+	if err != nil {
+		t.Fatalf("fetching schedule: %s", err)
+	}
+	if n := len(schedule.Data.Segments); n != len(segments) {
+		t.Errorf("expected %d segments from all pages, got %d", len(segments), n)
+	}
+	if schedule.Pagination.Cursor != "" {
+		t.Errorf("expected no cursor in the collected schedule, got %q", schedule.Pagination.Cursor)
+	}
+	if n := srv.Requests("/helix/schedule"); n != 3 {
+		t.Errorf("expected 3 pages to be fetched, got %d requests", n)
+	}
+
+	var ids []string
+	for seg, err := range adapter.ScheduleSegments(context.Background(), "1", nil) {
+		if err != nil {
+			t.Fatalf("iterating segments: %s", err)
+		}
+
+		ids = append(ids, seg.ID)
+		if len(ids) == 30 {
+			break
+		}
+	}
+
+	if ids[0] != "seg-0" || ids[29] != "seg-29" {
+		t.Errorf("expected segments in order, got %v", ids)
+	}
+	if n := srv.Requests("/helix/schedule"); n != 5 {
+		t.Errorf("expected stopped iteration to fetch only 2 pages, got %d requests", n-3)
+	}
+}
+
+func TestStreamsBatchingAndPagination(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	adapter := srv.Adapter()
+
+	var (
+		logins  = make([]string, 250)
+		streams []twitch.Stream
+	)
+
+	for i := range logins {
+		logins[i] = fmt.Sprintf("user%d", i)
+		streams = append(streams, twitch.Stream{ID: fmt.Sprintf("stream%d", i), UserID: fmt.Sprint(i), UserLogin: logins[i]})
+
+		if i < 100 {
+			// A second stream for each user of the first batch does not
+			// fit into one page and forces following the cursor
+			streams = append(streams, twitch.Stream{ID: fmt.Sprintf("stream%d-2", i), UserID: fmt.Sprint(i), UserLogin: logins[i]})
+		}
+	}
+	srv.SetStreams(streams...)
+
+	seen := make(map[string]struct{})
+	for stream, err := range adapter.Streams(context.Background(), logins...) {
+		if err != nil {
+			t.Fatalf("iterating streams: %s", err)
+		}
+		seen[stream.ID] = struct{}{}
+	}
+
+	if len(seen) != len(streams) {
+		t.Errorf("expected %d streams, got %d", len(streams), len(seen))
+	}
+
+	// Batches of 100, 100 and 50 users, the first one having two pages
+	if n := srv.Requests("/helix/streams"); n != 4 {
+		t.Errorf("expected 4 stream requests, got %d", n)
+	}
+}
+
+func TestUsersBatching(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	adapter := srv.Adapter()
+
+	var (
+		logins = make([]string, 250)
+		users  = make([]twitch.User, len(logins))
+	)
+
+	for i := range logins {
+		logins[i] = fmt.Sprintf("user%d", i)
+		users[i] = twitch.User{ID: fmt.Sprint(i), Login: logins[i]}
+	}
+	srv.SetUsers(users...)
+
+	var found int
+	for _, err := range adapter.Users(context.Background(), logins...) {
+		if err != nil {
+			t.Fatalf("iterating users: %s", err)
+		}
+		found++
+	}
+
+	if found != len(users) {
+		t.Errorf("expected %d users, got %d", len(users), found)
+	}
+	if n := srv.Requests("/helix/users"); n != 3 {
+		t.Errorf("expected 3 batches, got %d requests", n)
+	}
+
+	// Stopping the iteration does not fetch the remaining batches
+	for range adapter.Users(context.Background(), logins...) {
+		break
+	}
+
+	if n := srv.Requests("/helix/users"); n != 4 {
+		t.Errorf("expected stopped iteration to fetch one batch, got %d requests", n-3)
+	}
+}
@@ -0,0 +1,88 @@
+package twitch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// rateLimiter tracks the rate limit bucket reported by the Helix
+	// API for one token and is shared between all Adapters using that
+	// token
+	rateLimiter struct {
+		lock      sync.Mutex
+		remaining int
+		reset     time.Time
+	}
+)
+
+var (
+	rateLimiters     = make(map[string]*rateLimiter)
+	rateLimitersLock sync.Mutex
+)
+
+// getRateLimiter returns the shared rate limiter for the token (or
+// the app-access-token when empty) of the client ID at the given API
+func getRateLimiter(apiBaseURL, clientID, token string) *rateLimiter {
+	rateLimitersLock.Lock()
+	defer rateLimitersLock.Unlock()
+
+	key := strings.Join([]string{apiBaseURL, clientID, token}, "|")
+	if l, ok := rateLimiters[key]; ok {
+		return l
+	}
+
+	l := &rateLimiter{}
+	rateLimiters[key] = l
+	return l
+}
+
+// Update stores the state of the bucket from the `Ratelimit-Remaining`
+// and `Ratelimit-Reset` headers of the response, responses without
+// those headers are ignored
+func (r *rateLimiter) Update(header http.Header) {
+	remaining, err := strconv.Atoi(header.Get("Ratelimit-Remaining"))
+	if err != nil {
+		return
+	}
+
+	reset, err := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.remaining = remaining
+	r.reset = time.Unix(reset, 0)
+}
+
+// Wait blocks until the bucket has points left or was refilled and
+// takes one point from it. Without known state the request is not
+// delayed.
+func (r *rateLimiter) Wait(ctx context.Context) error {
+	for {
+		r.lock.Lock()
+		if r.remaining > 0 || !time.Now().Before(r.reset) {
+			if r.remaining > 0 {
+				r.remaining--
+			}
+			r.lock.Unlock()
+			return nil
+		}
+		wait := time.Until(r.reset)
+		r.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for rate limit reset: %w", ctx.Err())
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package twitch_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+func TestRetries(t *testing.T) {
+	for name, tc := range map[string]struct {
+		failures []int
+		retries  int
+		success  bool
+		requests int
+	}{
+		"temporary failures":   {failures: []int{http.StatusInternalServerError, http.StatusTooManyRequests}, retries: 2, success: true, requests: 3},
+		"permanent failure":    {failures: []int{http.StatusBadRequest}, retries: 2, success: false, requests: 1},
+		"retry limit":          {failures: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, retries: 1, success: false, requests: 2},
+		"retries disabled":     {failures: []int{http.StatusServiceUnavailable}, retries: 0, success: false, requests: 1},
+		"no failure, no retry": {retries: 2, success: true, requests: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := twitchtest.NewServer()
+			defer srv.Close()
+
+			srv.SetStreams(twitch.Stream{ID: "1", UserID: "1", UserLogin: "luziferus"})
+			srv.FailNext("/helix/streams", tc.failures...)
+
+			streams, err := srv.Adapter(twitch.WithRetries(tc.retries)).GetStreamsForUser(context.Background(), "luziferus")
+			if tc.success && (err != nil || len(streams.Data) != 1) {
+				t.Errorf("expected stream to be fetched, got %v (%v)", streams, err)
+			}
+
+			var apiErr twitch.APIError
+			if !tc.success && !errors.As(err, &apiErr) {
+				t.Errorf("expected APIError, got %v", err)
+			}
+
+			if n := srv.Requests("/helix/streams"); n != tc.requests {
+				t.Errorf("expected %d requests, got %d", tc.requests, n)
+			}
+		})
+	}
+}
+
+func TestRetryRevokedAppToken(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus"})
+	adapter := srv.Adapter()
+
+	if _, err := adapter.GetUserByUsername(context.Background(), "luziferus"); err != nil {
+		t.Fatalf("fetching user: %s", err)
+	}
+
+	srv.RevokeTokens()
+
+	users, err := adapter.GetUserByUsername(context.Background(), "luziferus")
+	if err != nil || len(users.Data) != 1 {
+		t.Fatalf("expected user to be fetched with a new token, got %v (%v)", users, err)
+	}
+
+	if n := srv.Requests("/oauth2/token"); n != 2 {
+		t.Errorf("expected a new token to be requested, got %d token requests", n)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus"})
+	srv.SetRateLimit(2, time.Second)
+
+	// Retries would hide requests answered with 429
+	adapter := srv.Adapter(twitch.WithRetries(0))
+
+	start := time.Now()
+	for i := range 3 {
+		if _, err := adapter.GetUserByUsername(context.Background(), "luziferus"); err != nil {
+			t.Fatalf("request %d: %s", i, err)
+		}
+	}
+
+	if n := srv.Requests("/helix/users"); n != 3 {
+		t.Errorf("expected 3 requests, got %d", n)
+	}
+
+	if time.Since(start) < 500*time.Millisecond {
+		t.Error("expected third request to wait for the bucket to be refilled")
+	}
+
+	// Waiting is cancelled with the context
+	srv.SetRateLimit(1, time.Minute)
+	if _, err := adapter.GetUserByUsername(context.Background(), "luziferus"); err != nil {
+		t.Fatalf("request: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if _, err := adapter.GetUserByUsername(ctx, "luziferus"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected waiting for the rate limit to be cancelled, got %v", err)
+	}
+}
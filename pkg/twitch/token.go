@@ -0,0 +1,108 @@
+package twitch
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// appTokenMinRefreshMargin is the minimum time before expiry a
+	// cached app-access-token is refreshed
+	appTokenMinRefreshMargin = time.Minute
+	// appTokenRefreshFraction defines the fraction of the token
+	// lifetime after which the token is refreshed proactively
+	appTokenRefreshFraction = 0.9
+)
+
+type (
+	// appTokenFetcher requests a new app-access-token and returns it
+	// together with its lifetime
+	appTokenFetcher func(ctx context.Context) (string, time.Duration, error)
+
+	// appTokenSource caches the app-access-token of one client ID and
+	// is shared between all Adapters using that client ID
+	appTokenSource struct {
+		clientSecret string
+		lock         sync.Mutex
+		refreshAt    time.Time
+		token        string
+	}
+)
+
+var (
+	appTokenSources     = make(map[string]*appTokenSource)
+	appTokenSourcesLock sync.Mutex
+
+	tokenFetchStatus struct {
+		lastErr   error
+		lastFetch time.Time
+		lock      sync.RWMutex
+	}
+)
+
+// TokenFetchStatus returns the time and the result of the last
+// app-access-token fetch of any Adapter. The time is zero when no
+// token was fetched yet.
+func TokenFetchStatus() (time.Time, error) {
+	tokenFetchStatus.lock.RLock()
+	defer tokenFetchStatus.lock.RUnlock()
+
+	return tokenFetchStatus.lastFetch, tokenFetchStatus.lastErr
+}
+
+// getAppTokenSource returns the shared token source for the client ID
+// at the given authentication API, a changed client secret replaces
+// the source
+func getAppTokenSource(idBaseURL, clientID, clientSecret string) *appTokenSource {
+	appTokenSourcesLock.Lock()
+	defer appTokenSourcesLock.Unlock()
+
+	key := strings.Join([]string{idBaseURL, clientID}, "|")
+	if s, ok := appTokenSources[key]; ok && s.clientSecret == clientSecret {
+		return s
+	}
+
+	s := &appTokenSource{clientSecret: clientSecret}
+	appTokenSources[key] = s
+	return s
+}
+
+// Invalidate drops the cached token if it still is the given one so
+// the next call to Token fetches a new token
+func (a *appTokenSource) Invalidate(token string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.token == token {
+		a.token = ""
+	}
+}
+
+// Token returns the cached token or fetches a new one when there is
+// no token or it is about to expire. Concurrent callers wait for a
+// running fetch instead of fetching a token on their own.
+func (a *appTokenSource) Token(ctx context.Context, fetch appTokenFetcher) (string, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.token != "" && time.Now().Before(a.refreshAt) {
+		return a.token, nil
+	}
+
+	token, expiresIn, err := fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	refreshIn := time.Duration(float64(expiresIn) * appTokenRefreshFraction)
+	if expiresIn-refreshIn < appTokenMinRefreshMargin {
+		refreshIn = expiresIn - appTokenMinRefreshMargin
+	}
+
+	a.refreshAt = time.Now().Add(refreshIn)
+	a.token = token
+
+	return token, nil
+}
@@ -0,0 +1,120 @@
+package twitch_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+const tokenPath = "/oauth2/token"
+
+func TestAppTokenCaching(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus"})
+
+	// Adapters with the same client ID share their token
+	for _, adapter := range []*twitch.Adapter{srv.Adapter(), srv.Adapter(), srv.Adapter(twitch.WithRetries(1))} {
+		if _, err := adapter.GetUserByUsername(context.Background(), "luziferus"); err != nil {
+			t.Fatalf("fetching user: %s", err)
+		}
+	}
+
+	if n := srv.Requests(tokenPath); n != 1 {
+		t.Errorf("expected token to be fetched once, got %d token requests", n)
+	}
+
+	// Another client ID must not use the cached token
+	other := twitch.New("other", twitchtest.ClientSecret, "",
+		twitch.WithAPIBaseURL(srv.URL),
+		twitch.WithHTTPClient(srv.Client()),
+		twitch.WithIDBaseURL(srv.URL),
+		twitch.WithRetries(0),
+	)
+	if _, err := other.GetUserByUsername(context.Background(), "luziferus"); err == nil {
+		t.Error("expected unknown client ID to be rejected")
+	}
+
+	if n := srv.Requests(tokenPath); n != 2 {
+		t.Errorf("expected other client ID to fetch its own token, got %d token requests", n)
+	}
+}
+
+func TestAppTokenInvalidation(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus"})
+	adapter := srv.Adapter()
+
+	if _, err := adapter.GetUserByUsername(context.Background(), "luziferus"); err != nil {
+		t.Fatalf("fetching user: %s", err)
+	}
+
+	// The 401 for the revoked token must cause a new token to be
+	// fetched and the request to be retried with it
+	srv.RevokeTokens()
+
+	users, err := adapter.GetUserByUsername(context.Background(), "luziferus")
+	if err != nil {
+		t.Fatalf("fetching user after revocation: %s", err)
+	}
+	if len(users.Data) != 1 {
+		t.Errorf("unexpected users %+v", users.Data)
+	}
+
+	if n := srv.Requests(tokenPath); n != 2 {
+		t.Errorf("expected token to be fetched again after revocation, got %d token requests", n)
+	}
+	if n := srv.Requests("/helix/users"); n != 3 {
+		t.Errorf("expected rejected request to be retried, got %d user requests", n)
+	}
+
+	// The new token is cached again
+	if _, err = adapter.GetUserByUsername(context.Background(), "luziferus"); err != nil {
+		t.Fatalf("fetching user: %s", err)
+	}
+	if n := srv.Requests(tokenPath); n != 2 {
+		t.Errorf("expected new token to be cached, got %d token requests", n)
+	}
+}
+
+func TestAppTokenRefreshBeforeExpiry(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus"})
+	adapter := srv.Adapter()
+
+	fetchUser := func() {
+		t.Helper()
+
+		if _, err := adapter.GetUserByUsername(context.Background(), "luziferus"); err != nil {
+			t.Fatalf("fetching user: %s", err)
+		}
+	}
+
+	// Tokens expiring within the refresh margin are never reused
+	srv.SetTokenLifetime(time.Minute)
+
+	fetchUser()
+	fetchUser()
+
+	if n := srv.Requests(tokenPath); n != 2 {
+		t.Errorf("expected token about to expire to be refreshed, got %d token requests", n)
+	}
+
+	// Tokens with a longer lifetime are cached again
+	srv.SetTokenLifetime(time.Hour)
+
+	fetchUser()
+	fetchUser()
+
+	if n := srv.Requests(tokenPath); n != 3 {
+		t.Errorf("expected long-living token to be cached, got %d token requests", n)
+	}
+}
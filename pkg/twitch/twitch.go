@@ -4,48 +4,117 @@ package twitch
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
+	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/Luzifer/go_helpers/backoff"
 	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/metrics"
 )
 
 const (
-	twitchAPIRequestLimit   = 5
-	twitchAPIRequestTimeout = 2 * time.Second
+	// DefaultAPIBaseURL is the base URL of the Helix API
+	DefaultAPIBaseURL = "https://api.twitch.tv"
+	// DefaultEventSubURL is the URL of the EventSub WebSocket server
+	DefaultEventSubURL = "wss://eventsub.wss.twitch.tv/ws"
+	// DefaultIDBaseURL is the base URL of the Twitch authentication API
+	DefaultIDBaseURL = "https://id.twitch.tv"
+	// DefaultRequestTimeout is the timeout of a single request
+	DefaultRequestTimeout = 2 * time.Second
+	// DefaultRetries is the number of retries of failed requests
+	DefaultRetries = 4
+
+	// maxBatchSize is the maximum number of items to look up in one
+	// request and the maximum page size of most endpoints
+	maxBatchSize = 100
+	// maxSchedulePageSize is the maximum page size of the schedule
+	// endpoint
+	maxSchedulePageSize = 25
 )
 
 type (
 	// Adapter contains a simplified Twitch API client
 	Adapter struct {
-		clientID     string
-		clientSecret string
-		token        string
+		apiBaseURL     string
+		client         *http.Client
+		clientID       string
+		clientSecret   string
+		eventSubURL    string
+		idBaseURL      string
+		requestTimeout time.Duration
+		retries        int
+		token          string
+		userTokens     *UserTokenSource
+	}
+
+	// APIError is returned when the Twitch API answers a request with
+	// an unsuccessful status
+	APIError struct {
+		StatusCode int
+		Body       string
+	}
+
+	// listing contains one page of items returned by the Helix API
+	listing[T any] struct {
+		Data       []T `json:"data"`
+		Pagination struct {
+			Cursor string `json:"cursor"`
+		} `json:"pagination"`
+	}
+
+	// Option configures an Adapter
+	Option func(*Adapter)
+
+	// ScheduleCategory contains the category of a schedule segment
+	ScheduleCategory struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+
+	// ScheduleSegment contains a single entry of a stream schedule
+	ScheduleSegment struct {
+		ID            string            `json:"id"`
+		StartTime     *time.Time        `json:"start_time"`
+		EndTime       *time.Time        `json:"end_time"`
+		Title         string            `json:"title"`
+		CanceledUntil *time.Time        `json:"canceled_until"`
+		Category      *ScheduleCategory `json:"category"`
+		IsRecurring   bool              `json:"is_recurring"`
+	}
+
+	// Stream contains the details of a live stream
+	Stream struct {
+		ID           string    `json:"id"`
+		UserID       string    `json:"user_id"`
+		UserLogin    string    `json:"user_login"`
+		UserName     string    `json:"user_name"`
+		GameID       string    `json:"game_id"`
+		GameName     string    `json:"game_name"`
+		Type         string    `json:"type"`
+		Title        string    `json:"title"`
+		ViewerCount  int64     `json:"viewer_count"`
+		StartedAt    time.Time `json:"started_at"`
+		Language     string    `json:"language"`
+		ThumbnailURL string    `json:"thumbnail_url"`
+		TagIDs       []string  `json:"tag_ids"`
+		Tags         []string  `json:"tags"`
+		IsMature     bool      `json:"is_mature"`
 	}
 
 	// StreamListing contains streams
 	StreamListing struct {
-		Data []struct {
-			ID           string    `json:"id"`
-			UserID       string    `json:"user_id"`
-			UserLogin    string    `json:"user_login"`
-			UserName     string    `json:"user_name"`
-			GameID       string    `json:"game_id"`
-			GameName     string    `json:"game_name"`
-			Type         string    `json:"type"`
-			Title        string    `json:"title"`
-			ViewerCount  int64     `json:"viewer_count"`
-			StartedAt    time.Time `json:"started_at"`
-			Language     string    `json:"language"`
-			ThumbnailURL string    `json:"thumbnail_url"`
-			TagIDs       []string  `json:"tag_ids"`
-			IsMature     bool      `json:"is_mature"`
-		} `json:"data"`
+		Data       []Stream `json:"data"`
 		Pagination struct {
 			Cursor string `json:"cursor"`
 		} `json:"pagination"`
@@ -54,21 +123,10 @@ type (
 	// StreamSchedule contains stream schedule segments
 	StreamSchedule struct {
 		Data struct {
-			Segments []struct {
-				ID            string     `json:"id"`
-				StartTime     *time.Time `json:"start_time"`
-				EndTime       *time.Time `json:"end_time"`
-				Title         string     `json:"title"`
-				CanceledUntil *time.Time `json:"canceled_until"`
-				Category      *struct {
-					ID   string `json:"id"`
-					Name string `json:"name"`
-				} `json:"category"`
-				IsRecurring bool `json:"is_recurring"`
-			} `json:"segments"`
-			BroadcasterID    string `json:"broadcaster_id"`
-			BroadcasterName  string `json:"broadcaster_name"`
-			BroadcasterLogin string `json:"broadcaster_login"`
+			Segments         []ScheduleSegment `json:"segments"`
+			BroadcasterID    string            `json:"broadcaster_id"`
+			BroadcasterName  string            `json:"broadcaster_name"`
+			BroadcasterLogin string            `json:"broadcaster_login"`
 			Vacation         *struct {
 				StartTime *time.Time `json:"start_time"`
 				EndTime   *time.Time `json:"end_time"`
@@ -79,141 +137,355 @@ type (
 		} `json:"pagination"`
 	}
 
+	// User contains the details of a Twitch user
+	User struct {
+		ID              string    `json:"id"`
+		Login           string    `json:"login"`
+		DisplayName     string    `json:"display_name"`
+		Type            string    `json:"type"`
+		BroadcasterType string    `json:"broadcaster_type"`
+		Description     string    `json:"description"`
+		ProfileImageURL string    `json:"profile_image_url"`
+		OfflineImageURL string    `json:"offline_image_url"`
+		ViewCount       int64     `json:"view_count"`
+		Email           string    `json:"email"`
+		CreatedAt       time.Time `json:"created_at"`
+	}
+
 	// UserListing contains users
 	UserListing struct {
-		Data []struct {
-			ID              string    `json:"id"`
-			Login           string    `json:"login"`
-			DisplayName     string    `json:"display_name"`
-			Type            string    `json:"type"`
-			BroadcasterType string    `json:"broadcaster_type"`
-			Description     string    `json:"description"`
-			ProfileImageURL string    `json:"profile_image_url"`
-			OfflineImageURL string    `json:"offline_image_url"`
-			ViewCount       int64     `json:"view_count"`
-			Email           string    `json:"email"`
-			CreatedAt       time.Time `json:"created_at"`
-		} `json:"data"`
+		Data []User `json:"data"`
 	}
 )
 
 // New creates a new Twitch client
-func New(clientID, clientSecret, token string) *Adapter {
-	return &Adapter{
+func New(clientID, clientSecret, token string, opts ...Option) *Adapter {
+	a := &Adapter{
+		apiBaseURL:   DefaultAPIBaseURL,
+		client:       http.DefaultClient,
 		clientID:     clientID,
 		clientSecret: clientSecret,
+		eventSubURL:  DefaultEventSubURL,
+		idBaseURL:    DefaultIDBaseURL,
 		token:        token,
+
+		requestTimeout: DefaultRequestTimeout,
+		retries:        DefaultRetries,
 	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
 }
 
-// GetChannelStreamSchedule retrieves a schedule for the given broadcaster
-func (t Adapter) GetChannelStreamSchedule(ctx context.Context, broadcasterID string, startTime *time.Time) (*StreamSchedule, error) {
-	out := &StreamSchedule{}
+// WithAPIBaseURL sets the base URL of the Helix API
+func WithAPIBaseURL(baseURL string) Option {
+	return func(a *Adapter) { a.apiBaseURL = strings.TrimRight(baseURL, "/") }
+}
 
-	params := make(url.Values)
-	params.Set("broadcaster_id", broadcasterID)
-	if startTime != nil {
-		params.Set("start_time", startTime.Format(time.RFC3339))
+// WithEventSubURL sets the URL of the EventSub WebSocket server
+func WithEventSubURL(u string) Option {
+	return func(a *Adapter) { a.eventSubURL = u }
+}
+
+// WithHTTPClient sets the client used to execute the requests
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *Adapter) { a.client = client }
+}
+
+// WithIDBaseURL sets the base URL of the authentication API
+func WithIDBaseURL(baseURL string) Option {
+	return func(a *Adapter) { a.idBaseURL = strings.TrimRight(baseURL, "/") }
+}
+
+// WithRequestTimeout sets the timeout of a single request, waiting
+// for the rate limit to reset is not included
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(a *Adapter) { a.requestTimeout = timeout }
+}
+
+// WithRetries sets how often a request failing with a retryable error
+// is retried, zero disables retries
+func WithRetries(retries int) Option {
+	return func(a *Adapter) { a.retries = max(retries, 0) }
+}
+
+// WithToken sets a static user access token to use instead of the
+// app-access-token
+func WithToken(token string) Option {
+	return func(a *Adapter) { a.token = token }
+}
+
+// IsRetryable reports whether the error is temporary and the request
+// might succeed when retried: the API answered with 429 Too Many
+// Requests or a server error or the request failed on the network
+// (including timeouts)
+func IsRetryable(err error) bool {
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
 	}
 
-	if err := backoff.NewBackoff().
-		WithMaxIterations(twitchAPIRequestLimit).
-		Retry(func() (err error) {
-			return t.request(ctx, http.MethodGet, "/helix/schedule", params, nil, out)
-		}); err != nil {
-		return nil, fmt.Errorf("getting schedule: %w", err)
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Error implements the error interface
+func (e APIError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// Retryable reports whether the status is temporary (429 Too Many
+// Requests and server errors)
+func (e APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// GetChannelStreamSchedule retrieves a schedule for the given
+// broadcaster containing the segments of all pages
+func (t Adapter) GetChannelStreamSchedule(ctx context.Context, broadcasterID string, startTime *time.Time) (*StreamSchedule, error) {
+	out, err := t.getStreamSchedulePage(ctx, broadcasterID, startTime, "")
+	if err != nil {
+		return nil, err
 	}
 
+	for cursor := out.Pagination.Cursor; cursor != ""; {
+		page, err := t.getStreamSchedulePage(ctx, broadcasterID, startTime, cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.Data.Segments) == 0 {
+			break
+		}
+
+		out.Data.Segments = append(out.Data.Segments, page.Data.Segments...)
+		cursor = page.Pagination.Cursor
+	}
+
+	out.Pagination.Cursor = ""
 	return out, nil
 }
 
 // GetStreamsForUser returns the streams for the given users
 func (t Adapter) GetStreamsForUser(ctx context.Context, userNames ...string) (*StreamListing, error) {
-	out := &StreamListing{}
-
-	params := make(url.Values)
-	params.Set("first", "100")
-	params["user_login"] = userNames
-
-	if err := backoff.NewBackoff().
-		WithMaxIterations(twitchAPIRequestLimit).
-		Retry(func() error {
-			return t.request(ctx, http.MethodGet, "/helix/streams", params, nil, out)
-		}); err != nil {
-		return nil, fmt.Errorf("getting streams: %w", err)
+	streams, err := collect(t.Streams(ctx, userNames...))
+	if err != nil {
+		return nil, err
 	}
 
-	return out, nil
+	return &StreamListing{Data: streams}, nil
 }
 
 // GetUserByUsername returns the user objects for the given usernames
 func (t Adapter) GetUserByUsername(ctx context.Context, userNames ...string) (*UserListing, error) {
-	out := &UserListing{}
+	users, err := collect(t.Users(ctx, userNames...))
+	if err != nil {
+		return nil, err
+	}
 
-	params := make(url.Values)
-	params.Set("first", "100")
-	params["login"] = userNames
+	return &UserListing{Data: users}, nil
+}
 
-	if err := backoff.NewBackoff().
-		WithMaxIterations(twitchAPIRequestLimit).
-		Retry(func() error {
-			return t.request(ctx, http.MethodGet, "/helix/users", params, nil, out)
-		}); err != nil {
-		return nil, fmt.Errorf("getting user: %w", err)
+// ScheduleSegments iterates over the schedule segments of the given
+// broadcaster and fetches the next page when reaching the end of the
+// current one. Stopping the iteration stops fetching pages. An error
+// ends the iteration.
+func (t Adapter) ScheduleSegments(ctx context.Context, broadcasterID string, startTime *time.Time) iter.Seq2[ScheduleSegment, error] {
+	return paginate(func(cursor string) ([]ScheduleSegment, string, error) {
+		page, err := t.getStreamSchedulePage(ctx, broadcasterID, startTime, cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return page.Data.Segments, page.Pagination.Cursor, nil
+	})
+}
+
+// Streams iterates over the live streams of the given users. The users
+// are looked up in batches of 100 and all pages of each batch are
+// fetched. Stopping the iteration stops fetching pages. An error ends
+// the iteration. Without users nothing is returned.
+func (t Adapter) Streams(ctx context.Context, userNames ...string) iter.Seq2[Stream, error] {
+	return func(yield func(Stream, error) bool) {
+		for batch := range slices.Chunk(userNames, maxBatchSize) {
+			for stream, err := range getPaginated[Stream](ctx, t, "/helix/streams", url.Values{"user_login": batch}, maxBatchSize) {
+				if !yield(stream, err) || err != nil {
+					return
+				}
+			}
+		}
 	}
+}
 
-	return out, nil
+// Users iterates over the users with the given usernames. The users
+// are looked up in batches of 100. Stopping the iteration stops
+// fetching batches. An error ends the iteration. Without usernames
+// nothing is returned.
+func (t Adapter) Users(ctx context.Context, userNames ...string) iter.Seq2[User, error] {
+	return lookup[User](ctx, t, "/helix/users", "login", userNames)
 }
 
-func (t Adapter) getAppAccessToken(ctx context.Context) (string, error) {
-	var rData struct {
-		AccessToken  string `json:"access_token"`  //#nosec:G117 // Intended to work with secrets
-		RefreshToken string `json:"refresh_token"` //#nosec:G117 // Intended to work with secrets
-		ExpiresIn    int    `json:"expires_in"`
-		Scope        []any  `json:"scope"`
-		TokenType    string `json:"token_type"`
+// With returns a copy of the Adapter with the options applied, the
+// copy shares cached tokens and rate limits with all Adapters using
+// the same credentials
+func (t Adapter) With(opts ...Option) *Adapter {
+	for _, opt := range opts {
+		opt(&t)
 	}
 
+	return &t
+}
+
+// accessToken returns the static token, the token of the user or the
+// app-access-token in this order
+func (t Adapter) accessToken(ctx context.Context) (string, error) {
+	switch {
+	case t.token != "":
+		return t.token, nil
+
+	case t.userTokens != nil:
+		token, err := t.userTokens.Token(ctx)
+		if err != nil {
+			return "", fmt.Errorf("fetching user access token: %w", err)
+		}
+		return token, nil
+
+	default:
+		token, err := t.appTokenSource().Token(ctx, t.fetchAppAccessToken)
+		if err != nil {
+			return "", fmt.Errorf("fetching app-access-token: %w", err)
+		}
+		return token, nil
+	}
+}
+
+// appTokenSource returns the token source shared by all Adapters
+// using the same client ID and authentication API
+func (t Adapter) appTokenSource() *appTokenSource {
+	return getAppTokenSource(t.idBaseURL, t.clientID, t.clientSecret)
+}
+
+// do executes the request and records its metrics using the given
+// endpoint as label
+func (t Adapter) do(req *http.Request, endpoint string) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.client.Do(req)
+	metrics.TwitchRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	metrics.TwitchRequests.WithLabelValues(endpoint, status).Inc()
+
+	return resp, err //nolint:wrapcheck // Wrapped by the caller
+}
+
+// fetchAppAccessToken requests a new app-access-token and returns it
+// together with its lifetime
+func (t Adapter) fetchAppAccessToken(ctx context.Context) (token string, expiresIn time.Duration, err error) {
+	defer func() {
+		tokenFetchStatus.lock.Lock()
+		defer tokenFetchStatus.lock.Unlock()
+
+		tokenFetchStatus.lastErr = err
+		tokenFetchStatus.lastFetch = time.Now()
+	}()
+
 	params := make(url.Values)
 	params.Set("client_id", t.clientID)
 	params.Set("client_secret", t.clientSecret)
 	params.Set("grant_type", "client_credentials")
 
-	u, _ := url.Parse("https://id.twitch.tv/oauth2/token")
-	u.RawQuery = params.Encode()
-
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := t.requestToken(ctx, params)
 	if err != nil {
-		return "", fmt.Errorf("fetching response: %w", err)
+		return "", 0, err
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			logrus.WithError(err).Error("closing Twitch response body")
-		}
-	}()
 
-	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", fmt.Errorf("unexpected status %d and cannot read body: %w", resp.StatusCode, err)
-		}
-		return "", fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	return resp.AccessToken, time.Duration(resp.ExpiresIn) * time.Second, nil
+}
+
+// getStreamSchedulePage fetches the page of the schedule identified
+// by the cursor (or the first page for an empty cursor)
+func (t Adapter) getStreamSchedulePage(ctx context.Context, broadcasterID string, startTime *time.Time, cursor string) (*StreamSchedule, error) {
+	out := &StreamSchedule{}
+
+	params := make(url.Values)
+	params.Set("broadcaster_id", broadcasterID)
+	params.Set("first", strconv.Itoa(maxSchedulePageSize))
+	if startTime != nil {
+		params.Set("start_time", startTime.Format(time.RFC3339))
+	}
+	if cursor != "" {
+		params.Set("after", cursor)
+	}
+
+	if err := t.retry(ctx, "/helix/schedule", func() error {
+		return t.request(ctx, http.MethodGet, "/helix/schedule", params, nil, out)
+	}); err != nil {
+		return nil, fmt.Errorf("getting schedule: %w", err)
+	}
+
+	return out, nil
+}
+
+// hasUserToken reports whether requests are made using a user access
+// token
+func (t Adapter) hasUserToken() bool {
+	return t.token != "" || t.userTokens != nil
+}
+
+// invalidateToken drops the given token from the cache of the user or
+// app-access-token so it is not used again
+func (t Adapter) invalidateToken(token string) {
+	switch {
+	case t.token != "":
+		// Static tokens cannot be replaced
+
+	case t.userTokens != nil:
+		t.userTokens.Invalidate(token)
+
+	default:
+		t.appTokenSource().Invalidate(token)
 	}
+}
+
+// isRetryable extends IsRetryable by 401 Unauthorized responses to
+// requests not using a static token as the next attempt uses a new
+// token
+func (t Adapter) isRetryable(err error) bool {
+	var apiErr APIError
+	if t.token == "" && errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+
+	return IsRetryable(err)
+}
 
-	if err = json.NewDecoder(resp.Body).Decode(&rData); err != nil {
-		return "", fmt.Errorf("decoding response: %w", err)
+// rateLimiter returns the rate limiter shared by all Adapters using
+// the same token
+func (t Adapter) rateLimiter() *rateLimiter {
+	if t.userTokens != nil {
+		return getRateLimiter(t.apiBaseURL, t.clientID, "user:"+t.userTokens.login)
 	}
 
-	return rData.AccessToken, nil
+	return getRateLimiter(t.apiBaseURL, t.clientID, t.token)
 }
 
 func (t Adapter) request(ctx context.Context, method, path string, params url.Values, body io.Reader, output any) error {
-	ctxTimed, cancel := context.WithTimeout(ctx, twitchAPIRequestTimeout)
+	limiter := t.rateLimiter()
+	if err := limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	ctxTimed, cancel := context.WithTimeout(ctx, t.requestTimeout)
 	defer cancel()
 
 	u, _ := url.Parse(strings.Join([]string{
-		"https://api.twitch.tv",
+		t.apiBaseURL,
 		strings.TrimLeft(path, "/"),
 	}, "/"))
 
@@ -222,18 +494,18 @@ func (t Adapter) request(ctx context.Context, method, path string, params url.Va
 	}
 
 	req, _ := http.NewRequestWithContext(ctxTimed, method, u.String(), body)
-	req.Header.Set("Authorization", strings.Join([]string{"Bearer", t.token}, " "))
 	req.Header.Set("Client-Id", t.clientID)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
-	if t.token == "" {
-		accessToken, err := t.getAppAccessToken(ctx)
-		if err != nil {
-			return fmt.Errorf("fetching app-access-token: %w", err)
-		}
-		req.Header.Set("Authorization", strings.Join([]string{"Bearer", accessToken}, " "))
+	accessToken, err := t.accessToken(ctx)
+	if err != nil {
+		return err
 	}
+	req.Header.Set("Authorization", strings.Join([]string{"Bearer", accessToken}, " "))
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := t.do(req, path)
 	if err != nil {
 		return fmt.Errorf("fetching response: %w", err)
 	}
@@ -243,12 +515,16 @@ func (t Adapter) request(ctx context.Context, method, path string, params url.Va
 		}
 	}()
 
-	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("unexpected status %d and cannot read body: %w", resp.StatusCode, err)
-		}
-		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
+	limiter.Update(resp.Header)
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Token was revoked or expired early, next try will fetch a
+		// new one (if not using a static token)
+		t.invalidateToken(accessToken)
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return newAPIError(resp)
 	}
 
 	if output == nil {
@@ -261,3 +537,126 @@ func (t Adapter) request(ctx context.Context, method, path string, params url.Va
 
 	return nil
 }
+
+// retry executes the function until it succeeds, fails with an error
+// which is not retryable or the retry limit is reached and counts the
+// retries for the given endpoint
+func (t Adapter) retry(ctx context.Context, endpoint string, fn func() error) error {
+	var attempt int
+
+	err := backoff.NewBackoff().
+		WithMaxIterations(uint64(t.retries) + 1). //#nosec:G115 // Retries are never negative
+		Retry(func() error {
+			if attempt > 0 {
+				metrics.TwitchRetries.WithLabelValues(endpoint).Inc()
+			}
+			attempt++
+
+			err := fn()
+			if err != nil && (ctx.Err() != nil || !t.isRetryable(err)) {
+				return backoff.NewErrCannotRetry(err)
+			}
+
+			return err
+		})
+
+	return err //nolint:wrapcheck // Wrapped by the caller
+}
+
+// collect gathers all items of the iterator and stops at the first
+// error
+func collect[T any](seq iter.Seq2[T, error]) ([]T, error) {
+	var out []T
+	for item, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, item)
+	}
+
+	return out, nil
+}
+
+// getPaginated iterates over the items of all pages of the endpoint
+// using the given page size
+func getPaginated[T any](ctx context.Context, t Adapter, path string, params url.Values, pageSize int) iter.Seq2[T, error] {
+	return paginate(func(cursor string) ([]T, string, error) {
+		pageParams := make(url.Values)
+		maps.Copy(pageParams, params)
+		pageParams.Set("first", strconv.Itoa(pageSize))
+		if cursor != "" {
+			pageParams.Set("after", cursor)
+		}
+
+		var page listing[T]
+		if err := t.retry(ctx, path, func() error {
+			return t.request(ctx, http.MethodGet, path, pageParams, nil, &page)
+		}); err != nil {
+			return nil, "", fmt.Errorf("getting %s: %w", path, err)
+		}
+
+		return page.Data, page.Pagination.Cursor, nil
+	})
+}
+
+// lookup iterates over the items identified by the values of the
+// parameter which are looked up in batches of 100
+func lookup[T any](ctx context.Context, t Adapter, path, param string, values []string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for batch := range slices.Chunk(values, maxBatchSize) {
+			var page listing[T]
+			if err := t.retry(ctx, path, func() error {
+				return t.request(ctx, http.MethodGet, path, url.Values{param: batch}, nil, &page)
+			}); err != nil {
+				var zero T
+				yield(zero, fmt.Errorf("getting %s: %w", path, err))
+				return
+			}
+
+			for _, item := range page.Data {
+				if !yield(item, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// newAPIError creates an APIError from the response
+func newAPIError(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unexpected status %d and cannot read body: %w", resp.StatusCode, err)
+	}
+
+	return APIError{StatusCode: resp.StatusCode, Body: string(body)}
+}
+
+// paginate iterates over the items of the pages returned by fetch,
+// starting with an empty cursor and following the returned cursors
+// until no cursor or no items are returned
+func paginate[T any](fetch func(cursor string) ([]T, string, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var cursor string
+		for {
+			items, next, err := fetch(cursor)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+
+			if next == "" || len(items) == 0 {
+				// Twitch might return a cursor with the last (empty) page
+				return
+			}
+			cursor = next
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package twitch_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+func TestConfigurableEndpoints(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus"})
+
+	// Trailing slashes must not end up in the request paths
+	adapter := twitch.New(twitchtest.ClientID, twitchtest.ClientSecret, "",
+		twitch.WithAPIBaseURL(srv.URL+"/"),
+		twitch.WithHTTPClient(srv.Client()),
+		twitch.WithIDBaseURL(srv.URL+"/"),
+	)
+
+	users, err := adapter.GetUserByUsername(context.Background(), "luziferus")
+	if err != nil {
+		t.Fatalf("fetching user: %s", err)
+	}
+	if len(users.Data) != 1 || users.Data[0].ID != "1" {
+		t.Errorf("unexpected users %+v", users.Data)
+	}
+
+	for path, expected := range map[string]int{"/oauth2/token": 1, "/helix/users": 1} {
+		if n := srv.Requests(path); n != expected {
+			t.Errorf("expected %d requests to %s, got %d", expected, path, n)
+		}
+	}
+}
+
+func TestFakeServerCredentials(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	srv.SetUsers(twitch.User{ID: "1", Login: "luziferus"})
+
+	for name, creds := range map[string][3]string{
+		"unknown client": {"other", twitchtest.ClientSecret, ""},
+		"wrong secret":   {twitchtest.ClientID, "wrong", ""},
+		"unissued token": {twitchtest.ClientID, twitchtest.ClientSecret, "made-up"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			adapter := twitch.New(creds[0], creds[1], creds[2],
+				twitch.WithAPIBaseURL(srv.URL),
+				twitch.WithHTTPClient(srv.Client()),
+				twitch.WithIDBaseURL(srv.URL),
+			)
+
+			if _, err := adapter.GetUserByUsername(context.Background(), "luziferus"); err == nil {
+				t.Error("expected request to be rejected")
+			}
+		})
+	}
+}
+
+func TestFakeServerFixtures(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	adapter := srv.Adapter()
+
+	srv.SetStreams(
+		twitch.Stream{ID: "10", UserID: "1", UserLogin: "luziferus"},
+		twitch.Stream{ID: "20", UserID: "2", UserLogin: "other"},
+	)
+
+	streams, err := adapter.GetStreamsForUser(context.Background(), "LUZIFERUS")
+	if err != nil {
+		t.Fatalf("fetching streams: %s", err)
+	}
+	if len(streams.Data) != 1 || streams.Data[0].ID != "10" {
+		t.Errorf("expected stream to be matched by login ignoring case, got %+v", streams.Data)
+	}
+
+	var (
+		now  = time.Now().Truncate(time.Second)
+		past = now.Add(-time.Hour)
+		next = now.Add(time.Hour)
+	)
+
+	srv.SetSchedule("1",
+		twitch.ScheduleSegment{ID: "past", StartTime: &past},
+		twitch.ScheduleSegment{ID: "next", StartTime: &next},
+	)
+
+	schedule, err := adapter.GetChannelStreamSchedule(context.Background(), "1", &now)
+	if err != nil {
+		t.Fatalf("fetching schedule: %s", err)
+	}
+	if segs := schedule.Data.Segments; len(segs) != 1 || segs[0].ID != "next" {
+		t.Errorf("expected only segments after the start time, got %+v", segs)
+	}
+
+	if _, err = adapter.GetChannelStreamSchedule(context.Background(), "2", nil); err == nil {
+		t.Error("expected error for broadcaster without schedule")
+	}
+}
@@ -0,0 +1,134 @@
+package twitchtest
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+// SetChannels replaces the list of known channels which are also
+// used to answer searches
+func (s *Server) SetChannels(channels ...twitch.ChannelInformation) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.channels = channels
+}
+
+// SetFollowers replaces the followers of the broadcaster, newest first.
+// The followers are only listed for requests using the UserToken, the
+// total is reported to all.
+func (s *Server) SetFollowers(broadcasterID string, followers ...twitch.ChannelFollower) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.followers[broadcasterID] = followers
+}
+
+func (s *Server) handleChannels(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		ids = r.URL.Query()["broadcaster_id"]
+		out = twitch.ChannelInformationListing{Data: []twitch.ChannelInformation{}}
+	)
+
+	if len(ids) == 0 || len(ids) > maxLookupSize {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	for _, channel := range s.channels {
+		if slices.Contains(ids, channel.BroadcasterID) {
+			out.Data = append(out.Data, channel)
+		}
+	}
+
+	s.writeJSON(w, out)
+}
+
+func (s *Server) handleFollowers(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		followers = s.followers[r.URL.Query().Get("broadcaster_id")]
+		out       = twitch.ChannelFollowerListing{Total: int64(len(followers)), Data: []twitch.ChannelFollower{}}
+		ok        bool
+	)
+
+	if r.URL.Query().Get("broadcaster_id") == "" {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+UserToken {
+		// Apps only get to know the total
+		s.writeJSON(w, out)
+		return
+	}
+
+	if out.Data, out.Pagination.Cursor, ok = paginate(r, followers, maxPageSize); !ok {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.writeJSON(w, out)
+}
+
+func (s *Server) handleSearchChannels(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		query    = strings.ToLower(r.URL.Query().Get("query"))
+		liveOnly = r.URL.Query().Get("live_only") == "true"
+		out      twitch.SearchChannelListing
+		ok       bool
+		results  = []twitch.SearchChannel{}
+	)
+
+	if query == "" {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	for _, channel := range s.channels {
+		if !strings.Contains(strings.ToLower(channel.BroadcasterLogin), query) && !strings.Contains(strings.ToLower(channel.BroadcasterName), query) {
+			continue
+		}
+
+		result := twitch.SearchChannel{
+			ID:                  channel.BroadcasterID,
+			BroadcasterLogin:    channel.BroadcasterLogin,
+			DisplayName:         channel.BroadcasterName,
+			BroadcasterLanguage: channel.BroadcasterLanguage,
+			GameID:              channel.GameID,
+			GameName:            channel.GameName,
+			Tags:                channel.Tags,
+			Title:               channel.Title,
+		}
+
+		if idx := slices.IndexFunc(s.streams, func(st twitch.Stream) bool { return st.UserID == channel.BroadcasterID }); idx >= 0 {
+			result.IsLive = true
+			result.StartedAt = s.streams[idx].StartedAt.Format(time.RFC3339)
+		}
+
+		if liveOnly && !result.IsLive {
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	if out.Data, out.Pagination.Cursor, ok = paginate(r, results, maxPageSize); !ok {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.writeJSON(w, out)
+}
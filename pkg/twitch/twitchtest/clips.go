@@ -0,0 +1,68 @@
+package twitchtest
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+// SetClips replaces the list of known clips, they are returned in the
+// given order
+func (s *Server) SetClips(clips ...twitch.Clip) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.clips = clips
+}
+
+func (s *Server) handleClips(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		broadcasterID = r.URL.Query().Get("broadcaster_id")
+		ids           = r.URL.Query()["id"]
+		out           twitch.ClipListing
+		ok            bool
+		clips         = []twitch.Clip{}
+
+		startedAt, endedAt time.Time
+	)
+
+	if (broadcasterID == "") == (len(ids) == 0) || len(ids) > maxLookupSize {
+		// Exactly one of the filters is required
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	for param, t := range map[string]*time.Time{"started_at": &startedAt, "ended_at": &endedAt} {
+		if v := r.URL.Query().Get(param); v != "" {
+			var err error
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				s.writeError(w, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	for _, clip := range s.clips {
+		switch {
+		case broadcasterID != "" && clip.BroadcasterID != broadcasterID,
+			len(ids) > 0 && !slices.Contains(ids, clip.ID),
+			!startedAt.IsZero() && clip.CreatedAt.Before(startedAt),
+			!endedAt.IsZero() && clip.CreatedAt.After(endedAt):
+			continue
+		}
+
+		clips = append(clips, clip)
+	}
+
+	if out.Data, out.Pagination.Cursor, ok = paginate(r, clips, maxPageSize); !ok {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.writeJSON(w, out)
+}
@@ -0,0 +1,216 @@
+package twitchtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+const (
+	// UserToken is a user access token accepted by the fake server,
+	// required to create EventSub subscriptions
+	UserToken = "twitchtest-user-token" //#nosec:G101 // Fake credential for tests
+
+	eventSubKeepaliveSeconds = 10
+	eventSubPath             = "/eventsub/ws"
+)
+
+type (
+	eventSubSession struct {
+		conn          *websocket.Conn
+		subscriptions []twitch.EventSubSubscription
+	}
+)
+
+// EventSubURL returns the URL of the fake EventSub WebSocket server
+func (s *Server) EventSubURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http") + eventSubPath
+}
+
+// Notify sends a notification with the given event to all sessions
+// and enabled webhook subscriptions matching the subscription and
+// returns the number of notifications sent
+func (s *Server) Notify(sub twitch.EventSubSubscription, event any) (int, error) {
+	eventData, err := json.Marshal(event)
+	if err != nil {
+		return 0, fmt.Errorf("encoding event: %w", err)
+	}
+
+	sent, err := s.notifySessions(sub, eventData)
+	if err != nil {
+		return sent, err
+	}
+
+	for _, ws := range s.matchingWebhooks(sub, webhookStatusEnabled) {
+		if _, err = s.sendWebhookMessage(ws, "notification", map[string]any{
+			"subscription": ws.payload(),
+			"event":        json.RawMessage(eventData),
+		}); err != nil {
+			return sent, fmt.Errorf("sending webhook notification: %w", err)
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// Subscriptions returns the subscriptions of all connected EventSub
+// sessions
+func (s *Server) Subscriptions() []twitch.EventSubSubscription {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var out []twitch.EventSubSubscription
+	for _, sess := range s.eventSubSessions {
+		out = append(out, sess.subscriptions...)
+	}
+
+	return out
+}
+
+// UserAdapter creates a twitch.Adapter using the fake server and the
+// UserToken, the given options are applied afterwards
+func (s *Server) UserAdapter(opts ...twitch.Option) *twitch.Adapter {
+	return twitch.New(ClientID, ClientSecret, UserToken, append(s.adapterOptions(), opts...)...)
+}
+
+func (s *Server) handleEventSubSubscription(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		twitch.EventSubSubscription
+		Transport struct {
+			Method    string `json:"method"`
+			SessionID string `json:"session_id"`
+			Callback  string `json:"callback"`
+			Secret    string `json:"secret"`
+		} `json:"transport"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	if req.Transport.Method == "webhook" {
+		s.createWebhookSubscription(w, req.EventSubSubscription, req.Transport.Callback, req.Transport.Secret)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if req.Transport.Method != "websocket" {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	sess, ok := s.eventSubSessions[req.Transport.SessionID]
+	if !ok {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	sess.subscriptions = append(sess.subscriptions, req.EventSubSubscription)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	s.writeJSON(w, map[string]any{"data": []any{req.EventSubSubscription}})
+}
+
+func (s *Server) handleEventSubWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		logrus.WithError(err).Error("upgrading fake EventSub connection")
+		return
+	}
+
+	s.lock.Lock()
+	s.messageCounter++
+	sessionID := "session-" + strconv.Itoa(s.messageCounter)
+	s.eventSubSessions[sessionID] = &eventSubSession{conn: conn}
+
+	err = conn.WriteJSON(map[string]any{
+		"metadata": map[string]any{
+			"message_id":        strconv.Itoa(s.messageCounter),
+			"message_type":      "session_welcome",
+			"message_timestamp": time.Now(),
+		},
+		"payload": map[string]any{
+			"session": map[string]any{
+				"id":                        sessionID,
+				"status":                    "connected",
+				"keepalive_timeout_seconds": eventSubKeepaliveSeconds,
+			},
+		},
+	})
+	s.lock.Unlock()
+
+	if err != nil {
+		logrus.WithError(err).Error("sending fake EventSub welcome")
+	}
+
+	// Read until the client disconnects to process control messages
+	for {
+		if _, _, err = conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	s.lock.Lock()
+	delete(s.eventSubSessions, sessionID)
+	s.lock.Unlock()
+
+	if err = conn.Close(); err != nil {
+		logrus.WithError(err).Debug("closing fake EventSub connection")
+	}
+}
+
+// notifySessions sends the notification to all sessions having a
+// matching subscription
+func (s *Server) notifySessions(sub twitch.EventSubSubscription, eventData []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var sent int
+	for _, sess := range s.eventSubSessions {
+		for _, active := range sess.subscriptions {
+			if !subscriptionEqual(active, sub) {
+				continue
+			}
+
+			s.messageCounter++
+			if err := sess.conn.WriteJSON(map[string]any{
+				"metadata": map[string]any{
+					"message_id":           strconv.Itoa(s.messageCounter),
+					"message_type":         "notification",
+					"message_timestamp":    time.Now(),
+					"subscription_type":    sub.Type,
+					"subscription_version": sub.Version,
+				},
+				"payload": map[string]any{
+					"subscription": sub,
+					"event":        json.RawMessage(eventData),
+				},
+			}); err != nil {
+				return sent, fmt.Errorf("sending notification: %w", err)
+			}
+
+			sent++
+			break
+		}
+	}
+
+	return sent, nil
+}
+
+func subscriptionEqual(a, b twitch.EventSubSubscription) bool {
+	return a.Type == b.Type && a.Version == b.Version && maps.Equal(a.Condition, b.Condition)
+}
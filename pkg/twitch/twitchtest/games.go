@@ -0,0 +1,40 @@
+package twitchtest
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+// SetGames replaces the list of known games
+func (s *Server) SetGames(games ...twitch.Game) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.games = games
+}
+
+func (s *Server) handleGames(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		ids   = r.URL.Query()["id"]
+		names = r.URL.Query()["name"]
+		out   = twitch.GameListing{Data: []twitch.Game{}}
+	)
+
+	if len(ids)+len(names) == 0 || len(ids)+len(names) > maxLookupSize {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	for _, game := range s.games {
+		if slices.Contains(ids, game.ID) || containsFold(names, game.Name) {
+			out.Data = append(out.Data, game)
+		}
+	}
+
+	s.writeJSON(w, out)
+}
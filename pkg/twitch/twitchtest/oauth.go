@@ -0,0 +1,143 @@
+package twitchtest
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+type (
+	userGrant struct {
+		redirectURL string
+		scopes      []string
+		user        twitch.User
+	}
+)
+
+// SetAuthorizingUser sets the user who approves the authorization
+// requests sent to the authorize endpoint, without user the requests
+// are denied
+func (s *Server) SetAuthorizingUser(user *twitch.User) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.authorizingUser = user
+}
+
+// handleAuthorize simulates the user approving the authorization and
+// redirects back to the app
+func (s *Server) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	q := r.URL.Query()
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || !redirect.IsAbs() || q.Get("client_id") != ClientID || q.Get("response_type") != "code" {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	params := redirect.Query()
+	params.Set("state", q.Get("state"))
+
+	if s.authorizingUser == nil {
+		params.Set("error", "access_denied")
+	} else {
+		s.messageCounter++
+		code := "code-" + strconv.Itoa(s.messageCounter)
+		s.authCodes[code] = userGrant{
+			redirectURL: redirect.String(),
+			scopes:      strings.Fields(q.Get("scope")),
+			user:        *s.authorizingUser,
+		}
+		params.Set("code", code)
+		params.Set("scope", q.Get("scope"))
+	}
+
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r.FormValue("client_id") != ClientID {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	delete(s.issuedTokens, r.FormValue("token"))
+	delete(s.userGrants, r.FormValue("token"))
+}
+
+// handleUserToken issues user access tokens for authorization codes
+// and refresh tokens, the lock must be held by the caller
+func (s *Server) handleUserToken(w http.ResponseWriter, r *http.Request) {
+	var (
+		grant userGrant
+		ok    bool
+	)
+
+	switch r.FormValue("grant_type") {
+	case "authorization_code":
+		if grant, ok = s.authCodes[r.FormValue("code")]; !ok || grant.redirectURL != r.FormValue("redirect_uri") {
+			s.writeError(w, http.StatusBadRequest)
+			return
+		}
+		delete(s.authCodes, r.FormValue("code"))
+
+	case "refresh_token":
+		if grant, ok = s.refreshTokens[r.FormValue("refresh_token")]; !ok {
+			s.writeError(w, http.StatusBadRequest)
+			return
+		}
+		delete(s.refreshTokens, r.FormValue("refresh_token"))
+
+	default:
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.messageCounter++
+	var (
+		token   = "user-token-" + strconv.Itoa(s.messageCounter)
+		refresh = "refresh-token-" + strconv.Itoa(s.messageCounter)
+	)
+
+	s.issuedTokens[token] = struct{}{}
+	s.userGrants[token] = grant
+	s.refreshTokens[refresh] = grant
+
+	s.writeJSON(w, map[string]any{
+		"access_token":  token,
+		"refresh_token": refresh,
+		"expires_in":    int(s.tokenLifetime / time.Second),
+		"scope":         grant.scopes,
+		"token_type":    "bearer",
+	})
+}
+
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "OAuth ")
+	grant, ok := s.userGrants[token]
+	if _, valid := s.issuedTokens[token]; !ok || !valid {
+		s.writeError(w, http.StatusUnauthorized)
+		return
+	}
+
+	s.writeJSON(w, map[string]any{
+		"client_id":  ClientID,
+		"expires_in": int(s.tokenLifetime / time.Second),
+		"login":      grant.user.Login,
+		"scopes":     grant.scopes,
+		"user_id":    grant.user.ID,
+	})
+}
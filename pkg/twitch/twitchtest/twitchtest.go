@@ -0,0 +1,437 @@
+// Package twitchtest provides an in-process fake of the Twitch Helix
+// and authentication APIs to test code using the twitch package
+// without network access.
+package twitchtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+const (
+	// ClientID is the client ID accepted by the fake server
+	ClientID = "twitchtest-client"
+	// ClientSecret is the client secret accepted by the fake server
+	ClientSecret = "twitchtest-secret" //#nosec:G101 // Fake credential for tests
+
+	defaultTokenLifetime = time.Hour
+
+	defaultPageSize     = 20
+	maxLookupSize       = 100
+	maxPageSize         = 100
+	maxSchedulePageSize = 25
+)
+
+type (
+	rateLimitBucket struct {
+		limit     int
+		remaining int
+		reset     time.Time
+		window    time.Duration
+	}
+
+	// Server is a fake Twitch API serving the users, streams,
+	// schedules, channels, followers, games, clips and videos
+	// configured through its fixture methods and EventSub through
+	// WebSocket sessions and webhook callbacks. Requests need to be
+	// authenticated using ClientID and a token issued by the fake
+	// token endpoint (app tokens and user tokens of the user set
+	// through SetAuthorizingUser) or the UserToken.
+	Server struct {
+		*httptest.Server
+
+		authCodes        map[string]userGrant
+		authorizingUser  *twitch.User
+		channels         []twitch.ChannelInformation
+		clips            []twitch.Clip
+		eventSubSessions map[string]*eventSubSession
+		failures         map[string][]int
+		followers        map[string][]twitch.ChannelFollower
+		games            []twitch.Game
+		issuedTokens     map[string]struct{}
+		lock             sync.Mutex
+		messageCounter   int
+		rateLimit        rateLimitBucket
+		refreshTokens    map[string]userGrant
+		requests         map[string]int
+		schedules        map[string][]twitch.ScheduleSegment
+		streams          []twitch.Stream
+		tokenLifetime    time.Duration
+		userGrants       map[string]userGrant
+		users            []twitch.User
+		videos           []twitch.Video
+		webhookSubs      []*webhookSubscription
+	}
+)
+
+// NewServer starts a new fake Twitch API without any fixtures. The
+// server must be closed using Close after use.
+func NewServer() *Server {
+	s := &Server{
+		authCodes:        make(map[string]userGrant),
+		eventSubSessions: make(map[string]*eventSubSession),
+		failures:         make(map[string][]int),
+		followers:        make(map[string][]twitch.ChannelFollower),
+		issuedTokens:     map[string]struct{}{UserToken: {}},
+		refreshTokens:    make(map[string]userGrant),
+		requests:         make(map[string]int),
+		schedules:        make(map[string][]twitch.ScheduleSegment),
+		tokenLifetime:    defaultTokenLifetime,
+		userGrants:       make(map[string]userGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+eventSubPath, s.handleEventSubWebSocket)
+	mux.HandleFunc("GET /oauth2/authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /oauth2/revoke", s.handleRevoke)
+	mux.HandleFunc("POST /oauth2/token", s.handleToken)
+	mux.HandleFunc("GET /oauth2/validate", s.handleValidate)
+	mux.HandleFunc("GET /helix/channels", s.requireToken(s.handleChannels))
+	mux.HandleFunc("GET /helix/channels/followers", s.requireToken(s.handleFollowers))
+	mux.HandleFunc("GET /helix/clips", s.requireToken(s.handleClips))
+	mux.HandleFunc("DELETE /helix/eventsub/subscriptions", s.requireToken(s.handleDeleteEventSubSubscription))
+	mux.HandleFunc("GET /helix/eventsub/subscriptions", s.requireToken(s.handleListEventSubSubscriptions))
+	mux.HandleFunc("POST /helix/eventsub/subscriptions", s.requireToken(s.handleEventSubSubscription))
+	mux.HandleFunc("GET /helix/games", s.requireToken(s.handleGames))
+	mux.HandleFunc("GET /helix/schedule", s.requireToken(s.handleSchedule))
+	mux.HandleFunc("GET /helix/search/channels", s.requireToken(s.handleSearchChannels))
+	mux.HandleFunc("GET /helix/streams", s.requireToken(s.handleStreams))
+	mux.HandleFunc("GET /helix/users", s.requireToken(s.handleUsers))
+	mux.HandleFunc("GET /helix/videos", s.requireToken(s.handleVideos))
+
+	s.Server = httptest.NewServer(s.countRequests(mux))
+
+	return s
+}
+
+// Adapter creates a twitch.Adapter using the fake server and its
+// credentials, the given options are applied afterwards
+func (s *Server) Adapter(opts ...twitch.Option) *twitch.Adapter {
+	return twitch.New(ClientID, ClientSecret, "", append(s.adapterOptions(), opts...)...)
+}
+
+// FailNext lets the next requests to the given path (e.g.
+// `/helix/streams`) fail with the given status codes, one status per
+// request in the given order
+func (s *Server) FailNext(path string, statuses ...int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.failures[path] = append(s.failures[path], statuses...)
+}
+
+// Requests returns how many requests were made to the given path
+func (s *Server) Requests(path string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.requests[path]
+}
+
+// RevokeTokens invalidates all issued tokens so the next requests are
+// answered with 401 Unauthorized
+func (s *Server) RevokeTokens() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.issuedTokens = make(map[string]struct{})
+}
+
+// SetRateLimit enables a rate limit bucket shared by all Helix
+// requests which allows limit requests per window and is reported
+// through the `Ratelimit-*` headers. Exceeding requests are answered
+// with 429 Too Many Requests. A limit of zero disables the bucket.
+func (s *Server) SetRateLimit(limit int, window time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rateLimit = rateLimitBucket{limit: limit, remaining: limit, reset: time.Now().Add(window), window: window}
+}
+
+// SetSchedule replaces the schedule segments of the broadcaster
+func (s *Server) SetSchedule(broadcasterID string, segments ...twitch.ScheduleSegment) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.schedules[broadcasterID] = segments
+}
+
+// SetStreams replaces the list of live streams
+func (s *Server) SetStreams(streams ...twitch.Stream) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.streams = streams
+}
+
+// SetTokenLifetime sets the lifetime reported for tokens issued
+// after the call
+func (s *Server) SetTokenLifetime(lifetime time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.tokenLifetime = lifetime
+}
+
+// SetUsers replaces the list of known users
+func (s *Server) SetUsers(users ...twitch.User) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.users = users
+}
+
+func (s *Server) adapterOptions() []twitch.Option {
+	return []twitch.Option{
+		twitch.WithAPIBaseURL(s.URL),
+		twitch.WithEventSubURL(s.EventSubURL()),
+		twitch.WithHTTPClient(s.Client()),
+		twitch.WithIDBaseURL(s.URL),
+	}
+}
+
+// countRequests counts the requests per path and answers with the
+// failures scripted through FailNext
+func (s *Server) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		s.requests[r.URL.Path]++
+
+		var failStatus int
+		if f := s.failures[r.URL.Path]; len(f) > 0 {
+			failStatus, s.failures[r.URL.Path] = f[0], f[1:]
+		}
+
+		if strings.HasPrefix(r.URL.Path, "/helix/") && !s.rateLimit.take(w.Header()) && failStatus == 0 {
+			failStatus = http.StatusTooManyRequests
+		}
+		s.lock.Unlock()
+
+		if failStatus > 0 {
+			s.writeError(w, failStatus)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	broadcasterID := r.URL.Query().Get("broadcaster_id")
+	segments, ok := s.schedules[broadcasterID]
+	if !ok {
+		s.writeError(w, http.StatusNotFound)
+		return
+	}
+
+	if st := r.URL.Query().Get("start_time"); st != "" {
+		start, err := time.Parse(time.RFC3339, st)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest)
+			return
+		}
+
+		segments = slices.DeleteFunc(slices.Clone(segments), func(seg twitch.ScheduleSegment) bool {
+			return seg.StartTime != nil && seg.StartTime.Before(start)
+		})
+	}
+
+	var out twitch.StreamSchedule
+	out.Data.BroadcasterID = broadcasterID
+	if out.Data.Segments, out.Pagination.Cursor, ok = paginate(r, segments, maxSchedulePageSize); !ok {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.writeJSON(w, out)
+}
+
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		logins  = r.URL.Query()["user_login"]
+		ids     = r.URL.Query()["user_id"]
+		out     twitch.StreamListing
+		ok      bool
+		streams = []twitch.Stream{}
+	)
+
+	if len(logins)+len(ids) > maxLookupSize {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	for _, stream := range s.streams {
+		if len(logins)+len(ids) > 0 && !containsFold(logins, stream.UserLogin) && !slices.Contains(ids, stream.UserID) {
+			continue
+		}
+		streams = append(streams, stream)
+	}
+
+	if out.Data, out.Pagination.Cursor, ok = paginate(r, streams, maxPageSize); !ok {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.writeJSON(w, out)
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("client_id") != ClientID || r.FormValue("client_secret") != ClientSecret {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if r.FormValue("grant_type") != "client_credentials" {
+		s.handleUserToken(w, r)
+		return
+	}
+
+	token := "token-" + strconv.Itoa(len(s.issuedTokens)+s.requests[r.URL.Path])
+	s.issuedTokens[token] = struct{}{}
+
+	s.writeJSON(w, map[string]any{
+		"access_token": token,
+		"expires_in":   int(s.tokenLifetime / time.Second),
+		"token_type":   "bearer",
+	})
+}
+
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		logins = r.URL.Query()["login"]
+		ids    = r.URL.Query()["id"]
+		out    = twitch.UserListing{Data: []twitch.User{}}
+	)
+
+	if len(logins)+len(ids) > maxLookupSize {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	for _, user := range s.users {
+		if !containsFold(logins, user.Login) && !slices.Contains(ids, user.ID) {
+			continue
+		}
+		out.Data = append(out.Data, user)
+	}
+
+	s.writeJSON(w, out)
+}
+
+func (s *Server) requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.lock.Lock()
+		_, valid := s.issuedTokens[token]
+		s.lock.Unlock()
+
+		if r.Header.Get("Client-Id") != ClientID || !valid {
+			s.writeError(w, http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (*Server) writeError(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"error":   http.StatusText(status),
+		"status":  status,
+		"message": "twitchtest: " + http.StatusText(status),
+	}); err != nil {
+		logrus.WithError(err).Error("encoding fake Twitch error")
+	}
+}
+
+func (*Server) writeJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		logrus.WithError(err).Error("encoding fake Twitch response")
+	}
+}
+
+// take removes one point from the bucket, refilling it when the
+// window passed, and sets the rate limit headers. It returns false
+// when the bucket is empty.
+func (b *rateLimitBucket) take(header http.Header) bool {
+	if b.limit == 0 {
+		return true
+	}
+
+	if !time.Now().Before(b.reset) {
+		b.remaining = b.limit
+		b.reset = time.Now().Add(b.window)
+	}
+
+	ok := b.remaining > 0
+	if ok {
+		b.remaining--
+	}
+
+	header.Set("Ratelimit-Limit", strconv.Itoa(b.limit))
+	header.Set("Ratelimit-Remaining", strconv.Itoa(b.remaining))
+	// Round up as the header only has a precision of seconds
+	header.Set("Ratelimit-Reset", strconv.FormatInt(b.reset.Add(time.Second-1).Unix(), 10))
+
+	return ok
+}
+
+func containsFold(list []string, s string) bool {
+	return slices.ContainsFunc(list, func(e string) bool { return strings.EqualFold(e, s) })
+}
+
+// paginate returns the page of the items requested through the `first`
+// and `after` parameters and the cursor of the next page or false for
+// invalid parameters
+func paginate[T any](r *http.Request, items []T, maxPageSize int) (page []T, cursor string, ok bool) {
+	size := defaultPageSize
+	if first := r.URL.Query().Get("first"); first != "" {
+		var err error
+		if size, err = strconv.Atoi(first); err != nil || size < 1 || size > maxPageSize {
+			return nil, "", false
+		}
+	}
+
+	var offset int
+	if after := r.URL.Query().Get("after"); after != "" {
+		var err error
+		if offset, err = strconv.Atoi(strings.TrimPrefix(after, "cursor-")); err != nil || offset < 0 || offset > len(items) {
+			return nil, "", false
+		}
+	}
+
+	end := min(offset+size, len(items))
+	if end < len(items) {
+		cursor = "cursor-" + strconv.Itoa(end)
+	}
+
+	return append([]T{}, items[offset:end]...), cursor, true
+}
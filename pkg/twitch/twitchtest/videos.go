@@ -0,0 +1,55 @@
+package twitchtest
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+// SetVideos replaces the list of known videos, they are returned in
+// the given order
+func (s *Server) SetVideos(videos ...twitch.Video) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.videos = videos
+}
+
+func (s *Server) handleVideos(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var (
+		ids       = r.URL.Query()["id"]
+		userID    = r.URL.Query().Get("user_id")
+		videoType = r.URL.Query().Get("type")
+		out       twitch.VideoListing
+		ok        bool
+		videos    = []twitch.Video{}
+	)
+
+	if (userID == "") == (len(ids) == 0) || len(ids) > maxLookupSize {
+		// Exactly one of the filters is required
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	for _, video := range s.videos {
+		switch {
+		case userID != "" && video.UserID != userID,
+			len(ids) > 0 && !slices.Contains(ids, video.ID),
+			videoType != "" && videoType != twitch.VideoTypeAll && video.Type != videoType:
+			continue
+		}
+
+		videos = append(videos, video)
+	}
+
+	if out.Data, out.Pagination.Cursor, ok = paginate(r, videos, maxPageSize); !ok {
+		s.writeError(w, http.StatusBadRequest)
+		return
+	}
+
+	s.writeJSON(w, out)
+}
@@ -0,0 +1,244 @@
+package twitchtest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+const (
+	webhookStatusEnabled             = "enabled"
+	webhookStatusVerificationFailed  = "webhook_callback_verification_failed"
+	webhookStatusVerificationPending = "webhook_callback_verification_pending"
+)
+
+type (
+	webhookSubscription struct {
+		twitch.EventSubSubscription
+		Callback string
+		ID       string
+		Secret   string
+		Status   string
+	}
+)
+
+// Revoke sends a revocation with the given status (e.g.
+// `user_removed`) for the matching webhook subscriptions and removes
+// them
+func (s *Server) Revoke(sub twitch.EventSubSubscription, status string) error {
+	revoked := s.matchingWebhooks(sub, "")
+
+	s.lock.Lock()
+	s.webhookSubs = slices.DeleteFunc(s.webhookSubs, func(ws *webhookSubscription) bool {
+		return subscriptionEqual(ws.EventSubSubscription, sub)
+	})
+	s.lock.Unlock()
+
+	for _, ws := range revoked {
+		ws.Status = status
+
+		if _, err := s.sendWebhookMessage(ws, "revocation", map[string]any{"subscription": ws.payload()}); err != nil {
+			return fmt.Errorf("sending revocation: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// SetWebhookSubscriptionStatus changes the status of the matching
+// webhook subscriptions (e.g. to `notification_failures_exceeded`)
+// without notifying the callback
+func (s *Server) SetWebhookSubscriptionStatus(sub twitch.EventSubSubscription, status string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, ws := range s.webhookSubs {
+		if subscriptionEqual(ws.EventSubSubscription, sub) {
+			ws.Status = status
+		}
+	}
+}
+
+// WebhookSubscriptions returns the webhook subscriptions with the
+// given status or all when the status is empty
+func (s *Server) WebhookSubscriptions(status string) []twitch.EventSubSubscription {
+	var out []twitch.EventSubSubscription
+	for _, ws := range s.matchingWebhooks(twitch.EventSubSubscription{}, status) {
+		out = append(out, ws.EventSubSubscription)
+	}
+
+	return out
+}
+
+// createWebhookSubscription stores the subscription and verifies the
+// callback in the background as Twitch does
+func (s *Server) createWebhookSubscription(w http.ResponseWriter, sub twitch.EventSubSubscription, callback, secret string) {
+	s.lock.Lock()
+	s.messageCounter++
+	ws := &webhookSubscription{
+		EventSubSubscription: sub,
+		Callback:             callback,
+		ID:                   "subscription-" + strconv.Itoa(s.messageCounter),
+		Secret:               secret,
+		Status:               webhookStatusVerificationPending,
+	}
+	s.webhookSubs = append(s.webhookSubs, ws)
+	payload := ws.payload()
+	verify := *ws
+	s.lock.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	s.writeJSON(w, map[string]any{"data": []any{payload}})
+
+	go func() {
+		challenge := "challenge-" + verify.ID
+
+		status := webhookStatusVerificationFailed
+		if body, err := s.sendWebhookMessage(verify, "webhook_callback_verification", map[string]any{
+			"challenge":    challenge,
+			"subscription": payload,
+		}); err == nil && body == challenge {
+			status = webhookStatusEnabled
+		}
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		ws.Status = status
+	}()
+}
+
+func (s *Server) handleDeleteEventSubSubscription(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	id := r.URL.Query().Get("id")
+	idx := slices.IndexFunc(s.webhookSubs, func(ws *webhookSubscription) bool { return ws.ID == id })
+	if idx < 0 {
+		s.writeError(w, http.StatusNotFound)
+		return
+	}
+
+	s.webhookSubs = slices.Delete(s.webhookSubs, idx, idx+1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleListEventSubSubscriptions(w http.ResponseWriter, _ *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	data := make([]any, 0, len(s.webhookSubs))
+	for _, ws := range s.webhookSubs {
+		data = append(data, ws.payload())
+	}
+
+	s.writeJSON(w, map[string]any{
+		"data":       data,
+		"total":      len(data),
+		"pagination": map[string]any{},
+	})
+}
+
+// matchingWebhooks returns copies of the webhook subscriptions
+// matching the subscription (or all for an empty subscription) having
+// the given status (or any for an empty status)
+func (s *Server) matchingWebhooks(sub twitch.EventSubSubscription, status string) []webhookSubscription {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var out []webhookSubscription
+	for _, ws := range s.webhookSubs {
+		if sub.Type != "" && !subscriptionEqual(ws.EventSubSubscription, sub) {
+			continue
+		}
+
+		if status != "" && ws.Status != status {
+			continue
+		}
+
+		out = append(out, *ws)
+	}
+
+	return out
+}
+
+// sendWebhookMessage sends a signed message to the callback of the
+// subscription and returns the response body
+func (s *Server) sendWebhookMessage(ws webhookSubscription, msgType string, payload any) (string, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("encoding message: %w", err)
+	}
+
+	s.lock.Lock()
+	s.messageCounter++
+	msgID := strconv.Itoa(s.messageCounter)
+	s.lock.Unlock()
+
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	mac := hmac.New(sha256.New, []byte(ws.Secret))
+	mac.Write(slices.Concat([]byte(msgID), []byte(timestamp), body))
+
+	req, err := http.NewRequest(http.MethodPost, ws.Callback, bytes.NewReader(body)) //nolint:noctx // Fake server without request context
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Twitch-Eventsub-Message-Id", msgID)
+	req.Header.Set("Twitch-Eventsub-Message-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("Twitch-Eventsub-Message-Timestamp", timestamp)
+	req.Header.Set("Twitch-Eventsub-Message-Type", msgType)
+	req.Header.Set("Twitch-Eventsub-Subscription-Type", ws.Type)
+	req.Header.Set("Twitch-Eventsub-Subscription-Version", ws.Version)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("executing request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logrus.WithError(err).Error("closing fake webhook response body")
+		}
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return string(respBody), nil
+}
+
+// payload returns the subscription as sent by Twitch
+func (w webhookSubscription) payload() map[string]any {
+	return map[string]any{
+		"id":        w.ID,
+		"status":    w.Status,
+		"type":      w.Type,
+		"version":   w.Version,
+		"condition": w.Condition,
+		"transport": map[string]any{
+			"method":   "webhook",
+			"callback": w.Callback,
+		},
+	}
+}
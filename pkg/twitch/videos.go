@@ -0,0 +1,77 @@
+package twitch
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"time"
+)
+
+const (
+	// VideoTypeAll selects all types of videos in Videos
+	VideoTypeAll = "all"
+	// VideoTypeArchive selects past broadcasts (VODs)
+	VideoTypeArchive = "archive"
+	// VideoTypeHighlight selects highlights
+	VideoTypeHighlight = "highlight"
+	// VideoTypeUpload selects uploaded videos
+	VideoTypeUpload = "upload"
+)
+
+type (
+	// Video contains the details of a video
+	Video struct {
+		ID string `json:"id"`
+		// StreamID is the ID of the stream the video was created from,
+		// only set for archives
+		StreamID     string    `json:"stream_id"`
+		UserID       string    `json:"user_id"`
+		UserLogin    string    `json:"user_login"`
+		UserName     string    `json:"user_name"`
+		Title        string    `json:"title"`
+		Description  string    `json:"description"`
+		CreatedAt    time.Time `json:"created_at"`
+		PublishedAt  time.Time `json:"published_at"`
+		URL          string    `json:"url"`
+		ThumbnailURL string    `json:"thumbnail_url"`
+		Viewable     string    `json:"viewable"`
+		ViewCount    int64     `json:"view_count"`
+		Language     string    `json:"language"`
+		Type         string    `json:"type"`
+		// Duration is formatted like `3h8m33s`
+		Duration      string `json:"duration"`
+		MutedSegments []struct {
+			Duration int64 `json:"duration"`
+			Offset   int64 `json:"offset"`
+		} `json:"muted_segments"`
+	}
+
+	// VideoListing contains videos
+	VideoListing struct {
+		Data       []Video `json:"data"`
+		Pagination struct {
+			Cursor string `json:"cursor"`
+		} `json:"pagination"`
+	}
+)
+
+// GetVideosByID returns the videos with the given IDs
+func (t Adapter) GetVideosByID(ctx context.Context, ids ...string) (*VideoListing, error) {
+	videos, err := collect(lookup[Video](ctx, t, "/helix/videos", "id", ids))
+	if err != nil {
+		return nil, err
+	}
+
+	return &VideoListing{Data: videos}, nil
+}
+
+// Videos iterates over the videos of the given type (see VideoType*
+// constants) of the user, newest first. Stopping the iteration stops
+// fetching pages. An error ends the iteration.
+func (t Adapter) Videos(ctx context.Context, userID, videoType string) iter.Seq2[Video, error] {
+	params := make(url.Values)
+	params.Set("user_id", userID)
+	params.Set("type", videoType)
+
+	return getPaginated[Video](ctx, t, "/helix/videos", params, maxBatchSize)
+}
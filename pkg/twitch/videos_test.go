@@ -0,0 +1,53 @@
+package twitch_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+func TestVideos(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	adapter := srv.Adapter()
+
+	srv.SetVideos(
+		twitch.Video{ID: "3", UserID: "1", Type: twitch.VideoTypeArchive, StreamID: "30"},
+		twitch.Video{ID: "2", UserID: "1", Type: twitch.VideoTypeHighlight},
+		twitch.Video{ID: "1", UserID: "1", Type: twitch.VideoTypeArchive, StreamID: "10"},
+		twitch.Video{ID: "4", UserID: "2", Type: twitch.VideoTypeArchive},
+	)
+
+	for videoType, expected := range map[string][]string{
+		twitch.VideoTypeAll:       {"3", "2", "1"},
+		twitch.VideoTypeArchive:   {"3", "1"},
+		twitch.VideoTypeHighlight: {"2"},
+		twitch.VideoTypeUpload:    nil,
+	} {
+		t.Run(videoType, func(t *testing.T) {
+			var ids []string
+			for video, err := range adapter.Videos(context.Background(), "1", videoType) {
+				if err != nil {
+					t.Fatalf("iterating videos: %s", err)
+				}
+				ids = append(ids, video.ID)
+			}
+
+			if fmt.Sprint(ids) != fmt.Sprint(expected) {
+				t.Errorf("expected videos %v, got %v", expected, ids)
+			}
+		})
+	}
+
+	byID, err := adapter.GetVideosByID(context.Background(), "1", "4")
+	if err != nil {
+		t.Fatalf("fetching videos by ID: %s", err)
+	}
+	if len(byID.Data) != 2 || byID.Data[0].StreamID != "10" {
+		t.Errorf("unexpected videos by ID %+v", byID.Data)
+	}
+}
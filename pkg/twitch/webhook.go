@@ -0,0 +1,323 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/metrics"
+)
+
+const (
+	eventSubWebhookMaxBodySize   = 1 << 20 // 1 MiB
+	eventSubWebhookMaxMessageAge = 10 * time.Minute
+
+	eventSubStatusEnabled             = "enabled"
+	eventSubStatusVerificationPending = "webhook_callback_verification_pending"
+)
+
+type (
+	// EventSubWebhook receives EventSub notifications through webhook
+	// callbacks and reconciles the webhook subscriptions of the app.
+	// The webhook needs to be served on the public callback URL, for
+	// example using an http.ServeMux.
+	EventSubWebhook struct {
+		adapter     Adapter
+		callbackURL string
+		handler     EventSubHandler
+		secret      string
+
+		active map[string]struct{}
+		lock   sync.Mutex
+		seen   eventSubMessageLog
+	}
+
+	eventSubSubscriptionListing struct {
+		Data []struct {
+			EventSubSubscription
+			ID        string `json:"id"`
+			Status    string `json:"status"`
+			Transport struct {
+				Method   string `json:"method"`
+				Callback string `json:"callback"`
+			} `json:"transport"`
+		} `json:"data"`
+		Pagination struct {
+			Cursor string `json:"cursor"`
+		} `json:"pagination"`
+	}
+
+	eventSubWebhookMessage struct {
+		Challenge    string `json:"challenge"`
+		Subscription struct {
+			EventSubSubscription
+			Status string `json:"status"`
+		} `json:"subscription"`
+		Event json.RawMessage `json:"event"`
+	}
+)
+
+// NewEventSubWebhook creates a new EventSubWebhook using the given
+// Adapter (using an app access token) to manage the subscriptions
+// delivered to the callback URL. The secret (10-100 characters) is
+// used to sign the messages. Notifications are passed to the handler
+// in the background with a context which is not cancelled when the
+// callback request finishes.
+func NewEventSubWebhook(adapter *Adapter, callbackURL, secret string, handler EventSubHandler) *EventSubWebhook {
+	return &EventSubWebhook{
+		adapter:     *adapter,
+		callbackURL: callbackURL,
+		handler:     handler,
+		secret:      secret,
+
+		active: make(map[string]struct{}),
+	}
+}
+
+// IsActive reports whether the subscription is enabled and was not
+// revoked since
+func (e *EventSubWebhook) IsActive(sub EventSubSubscription) bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	_, ok := e.active[sub.key()]
+	return ok
+}
+
+// Reconcile compares the webhook subscriptions for the callback URL
+// with the given subscriptions, deletes subscriptions which are no
+// longer wanted or failed and creates the missing ones
+func (e *EventSubWebhook) Reconcile(ctx context.Context, subs []EventSubSubscription) error {
+	existing, err := e.listSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("listing subscriptions: %w", err)
+	}
+
+	var (
+		active  = make(map[string]struct{})
+		present = make(map[string]struct{})
+		wanted  = make(map[string]struct{})
+	)
+
+	for _, sub := range subs {
+		wanted[sub.key()] = struct{}{}
+	}
+
+	for _, sub := range existing.Data {
+		key := sub.key()
+		_, isWanted := wanted[key]
+		_, isPresent := present[key]
+
+		if isWanted && !isPresent && (sub.Status == eventSubStatusEnabled || sub.Status == eventSubStatusVerificationPending) {
+			present[key] = struct{}{}
+			if sub.Status == eventSubStatusEnabled {
+				active[key] = struct{}{}
+			}
+			continue
+		}
+
+		logrus.WithFields(logrus.Fields{
+			"condition": sub.Condition,
+			"status":    sub.Status,
+			"type":      sub.Type,
+		}).Debug("deleting EventSub webhook subscription")
+
+		if err = e.adapter.deleteEventSubSubscription(ctx, sub.ID); err != nil {
+			return fmt.Errorf("deleting subscription %s: %w", sub.ID, err)
+		}
+	}
+
+	e.lock.Lock()
+	e.active = active
+	e.lock.Unlock()
+
+	for _, sub := range subs {
+		if _, ok := present[sub.key()]; ok {
+			continue
+		}
+
+		// Subscriptions become active when the callback was verified
+		if err = e.createSubscription(ctx, sub); err != nil {
+			// Single subscriptions might fail (e.g. subscription limits
+			// reached) which must not stop the others from working
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"condition": sub.Condition,
+				"type":      sub.Type,
+			}).Error("creating EventSub webhook subscription")
+		}
+	}
+
+	return nil
+}
+
+// ServeHTTP handles the callback requests sent by Twitch
+func (e *EventSubWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, eventSubWebhookMaxBodySize))
+	if err != nil {
+		http.Error(w, "reading body", http.StatusBadRequest)
+		return
+	}
+
+	if !e.verifySignature(r.Header, body) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	msgType := r.Header.Get("Twitch-Eventsub-Message-Type")
+	metrics.TwitchEventSubMessages.WithLabelValues(msgType).Inc()
+
+	if e.seen.seenBefore(r.Header.Get("Twitch-Eventsub-Message-Id")) {
+		// Redelivery of a message we already handled
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	var msg eventSubWebhookMessage
+	if err = json.Unmarshal(body, &msg); err != nil {
+		http.Error(w, "decoding body", http.StatusBadRequest)
+		return
+	}
+
+	switch msgType {
+	case "notification":
+		n := EventSubNotification{
+			Subscription: msg.Subscription.EventSubSubscription,
+			Event:        msg.Event,
+		}
+		go e.handler(context.WithoutCancel(r.Context()), n)
+
+	case "revocation":
+		logrus.WithFields(logrus.Fields{
+			"condition": msg.Subscription.Condition,
+			"status":    msg.Subscription.Status,
+			"type":      msg.Subscription.Type,
+		}).Warn("EventSub webhook subscription revoked")
+
+		e.lock.Lock()
+		delete(e.active, msg.Subscription.key())
+		e.lock.Unlock()
+
+	case "webhook_callback_verification":
+		e.lock.Lock()
+		e.active[msg.Subscription.key()] = struct{}{}
+		e.lock.Unlock()
+
+		w.Header().Set("Content-Type", "text/plain")
+		if _, err = w.Write([]byte(msg.Challenge)); err != nil {
+			logrus.WithError(err).Error("writing EventSub challenge")
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createSubscription creates the subscription delivered to the
+// callback URL
+func (e *EventSubWebhook) createSubscription(ctx context.Context, sub EventSubSubscription) error {
+	type transport struct {
+		Method   string `json:"method"`
+		Callback string `json:"callback"`
+		Secret   string `json:"secret"`
+	}
+
+	payload, err := json.Marshal(struct {
+		EventSubSubscription
+		Transport transport `json:"transport"`
+	}{
+		EventSubSubscription: sub,
+		Transport:            transport{Method: "webhook", Callback: e.callbackURL, Secret: e.secret},
+	})
+	if err != nil {
+		return fmt.Errorf("encoding subscription: %w", err)
+	}
+
+	if err = e.adapter.retry(ctx, "/helix/eventsub/subscriptions", func() error {
+		return e.adapter.request(ctx, http.MethodPost, "/helix/eventsub/subscriptions", nil, bytes.NewReader(payload), nil)
+	}); err != nil {
+		return fmt.Errorf("creating subscription: %w", err)
+	}
+
+	return nil
+}
+
+// listSubscriptions returns all webhook subscriptions of the app
+// delivered to the callback URL
+func (e *EventSubWebhook) listSubscriptions(ctx context.Context) (*eventSubSubscriptionListing, error) {
+	out := &eventSubSubscriptionListing{}
+
+	var cursor string
+	for {
+		params := make(url.Values)
+		if cursor != "" {
+			params.Set("after", cursor)
+		}
+
+		var page eventSubSubscriptionListing
+		if err := e.adapter.retry(ctx, "/helix/eventsub/subscriptions", func() error {
+			return e.adapter.request(ctx, http.MethodGet, "/helix/eventsub/subscriptions", params, nil, &page)
+		}); err != nil {
+			return nil, fmt.Errorf("fetching subscriptions: %w", err)
+		}
+
+		for _, sub := range page.Data {
+			if sub.Transport.Method == "webhook" && sub.Transport.Callback == e.callbackURL {
+				out.Data = append(out.Data, sub)
+			}
+		}
+
+		if cursor = page.Pagination.Cursor; cursor == "" {
+			return out, nil
+		}
+	}
+}
+
+// verifySignature checks the HMAC signature and the age of the message
+func (e *EventSubWebhook) verifySignature(header http.Header, body []byte) bool {
+	var (
+		id        = header.Get("Twitch-Eventsub-Message-Id")
+		signature = header.Get("Twitch-Eventsub-Message-Signature")
+		timestamp = header.Get("Twitch-Eventsub-Message-Timestamp")
+	)
+
+	if id == "" || signature == "" {
+		return false
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil || time.Since(ts) > eventSubWebhookMaxMessageAge {
+		// Old messages might be replay attacks
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(e.secret))
+	mac.Write(slices.Concat([]byte(id), []byte(timestamp), body))
+
+	return hmac.Equal([]byte(signature), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+}
+
+// deleteEventSubSubscription deletes the subscription with the given ID
+func (t Adapter) deleteEventSubSubscription(ctx context.Context, id string) error {
+	params := make(url.Values)
+	params.Set("id", id)
+
+	if err := t.retry(ctx, "/helix/eventsub/subscriptions", func() error {
+		return t.request(ctx, http.MethodDelete, "/helix/eventsub/subscriptions", params, nil, nil)
+	}); err != nil {
+		return fmt.Errorf("deleting subscription: %w", err)
+	}
+
+	return nil
+}
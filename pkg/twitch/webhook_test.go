@@ -0,0 +1,187 @@
+package twitch_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Luzifer/discord-community/pkg/twitch"
+	"github.com/Luzifer/discord-community/pkg/twitch/twitchtest"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+func TestEventSubWebhookReconcileAndNotify(t *testing.T) {
+	srv := twitchtest.NewServer()
+	defer srv.Close()
+
+	notifications := make(chan twitch.EventSubNotification, 1)
+
+	var webhook *twitch.EventSubWebhook
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { webhook.ServeHTTP(w, r) }))
+	defer callback.Close()
+
+	webhook = twitch.NewEventSubWebhook(srv.Adapter(), callback.URL, testWebhookSecret, func(_ context.Context, n twitch.EventSubNotification) {
+		notifications <- n
+	})
+
+	online := twitch.StreamOnlineSubscription("1")
+	if err := webhook.Reconcile(context.Background(), []twitch.EventSubSubscription{online}); err != nil {
+		t.Fatalf("reconciling: %s", err)
+	}
+
+	waitFor(t, "subscription to be verified", func() bool { return webhook.IsActive(online) })
+
+	if _, err := srv.Notify(online, map[string]string{"broadcaster_user_login": "luziferus"}); err != nil {
+		t.Fatalf("sending notification: %s", err)
+	}
+
+	select {
+	case n := <-notifications:
+		if n.Subscription.Type != twitch.EventSubTypeStreamOnline {
+			t.Errorf("unexpected notification type %q", n.Subscription.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notification was not passed to the handler")
+	}
+
+	// Subscriptions no longer wanted are removed
+	offline := twitch.StreamOfflineSubscription("1")
+	if err := webhook.Reconcile(context.Background(), []twitch.EventSubSubscription{offline}); err != nil {
+		t.Fatalf("reconciling: %s", err)
+	}
+
+	waitFor(t, "new subscription to be verified", func() bool { return webhook.IsActive(offline) })
+
+	if webhook.IsActive(online) {
+		t.Error("expected removed subscription to be inactive")
+	}
+	if subs := srv.WebhookSubscriptions(""); len(subs) != 1 || subs[0].Type != twitch.EventSubTypeStreamOffline {
+		t.Errorf("expected only the offline subscription to remain, got %v", subs)
+	}
+
+	if err := srv.Revoke(offline, "user_removed"); err != nil {
+		t.Fatalf("revoking: %s", err)
+	}
+	if webhook.IsActive(offline) {
+		t.Error("expected revoked subscription to be inactive")
+	}
+}
+
+func TestEventSubWebhookVerification(t *testing.T) {
+	const body = `{"subscription":{"type":"stream.online","version":"1","condition":{"broadcaster_user_id":"1"}},"event":{}}`
+
+	now := time.Now().UTC()
+
+	for name, tc := range map[string]struct {
+		id        string
+		secret    string
+		timestamp time.Time
+		status    int
+	}{
+		"valid":          {id: "msg-1", secret: testWebhookSecret, timestamp: now, status: http.StatusNoContent},
+		"wrong secret":   {id: "msg-2", secret: "other-secret", timestamp: now, status: http.StatusForbidden},
+		"missing id":     {id: "", secret: testWebhookSecret, timestamp: now, status: http.StatusForbidden},
+		"too old":        {id: "msg-3", secret: testWebhookSecret, timestamp: now.Add(-11 * time.Minute), status: http.StatusForbidden},
+		"slightly older": {id: "msg-4", secret: testWebhookSecret, timestamp: now.Add(-9 * time.Minute), status: http.StatusNoContent},
+	} {
+		t.Run(name, func(t *testing.T) {
+			called := make(chan struct{}, 1)
+			webhook := twitch.NewEventSubWebhook(twitch.New("id", "secret", ""), "https://example.com/", testWebhookSecret, func(context.Context, twitch.EventSubNotification) {
+				called <- struct{}{}
+			})
+
+			w := httptest.NewRecorder()
+			webhook.ServeHTTP(w, signedWebhookRequest(tc.secret, tc.id, tc.timestamp, "notification", body))
+
+			if w.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, w.Code)
+			}
+
+			if tc.status == http.StatusNoContent {
+				select {
+				case <-called:
+				case <-time.After(time.Second):
+					t.Fatal("notification was not passed to the handler")
+				}
+			}
+		})
+	}
+}
+
+func TestEventSubWebhookDeduplication(t *testing.T) {
+	const body = `{"subscription":{"type":"stream.online","version":"1","condition":{"broadcaster_user_id":"1"}},"event":{}}`
+
+	called := make(chan struct{}, 2)
+	webhook := twitch.NewEventSubWebhook(twitch.New("id", "secret", ""), "https://example.com/", testWebhookSecret, func(context.Context, twitch.EventSubNotification) {
+		called <- struct{}{}
+	})
+
+	for range 2 {
+		w := httptest.NewRecorder()
+		webhook.ServeHTTP(w, signedWebhookRequest(testWebhookSecret, "msg-1", time.Now(), "notification", body))
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("expected redelivery to be acknowledged, got status %d", w.Code)
+		}
+	}
+
+	<-called
+	select {
+	case <-called:
+		t.Error("redelivered notification was passed to the handler")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestEventSubWebhookChallenge(t *testing.T) {
+	const body = `{"challenge":"pogchamp","subscription":{"type":"stream.online","version":"1","condition":{"broadcaster_user_id":"1"}}}`
+
+	webhook := twitch.NewEventSubWebhook(twitch.New("id", "secret", ""), "https://example.com/", testWebhookSecret, nil)
+
+	w := httptest.NewRecorder()
+	webhook.ServeHTTP(w, signedWebhookRequest(testWebhookSecret, "msg-1", time.Now(), "webhook_callback_verification", body))
+
+	if w.Code != http.StatusOK || w.Body.String() != "pogchamp" {
+		t.Errorf("expected challenge to be echoed, got status %d and body %q", w.Code, w.Body.String())
+	}
+
+	if !webhook.IsActive(twitch.StreamOnlineSubscription("1")) {
+		t.Error("expected verified subscription to be active")
+	}
+}
+
+func signedWebhookRequest(secret, id string, ts time.Time, msgType, body string) *http.Request {
+	timestamp := ts.UTC().Format(time.RFC3339Nano)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(slices.Concat([]byte(id), []byte(timestamp), []byte(body)))
+
+	r := httptest.NewRequest(http.MethodPost, "/twitch/eventsub", strings.NewReader(body))
+	r.Header.Set("Twitch-Eventsub-Message-Id", id)
+	r.Header.Set("Twitch-Eventsub-Message-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	r.Header.Set("Twitch-Eventsub-Message-Timestamp", timestamp)
+	r.Header.Set("Twitch-Eventsub-Message-Type", msgType)
+
+	return r
+}
+
+// waitFor polls the condition until it is met or a second passed
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+
+	t.Fatalf("timeout waiting for %s", what)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/modules"
+)
+
+// configReloadDebounce defines how long to wait for further changes
+// of the config file before reloading it: editors tend to write files
+// in multiple steps
+const configReloadDebounce = time.Second
+
+// configReloader applies changes of the config file to the running
+// bot and keeps the config currently in effect
+type configReloader struct {
+	api     *adminAPI
+	current *config.File
+	lock    sync.Mutex
+	mgr     *modules.Manager
+}
+
+func newConfigReloader(current *config.File, mgr *modules.Manager, api *adminAPI) *configReloader {
+	return &configReloader{api: api, current: current, mgr: mgr}
+}
+
+func (c *configReloader) reload(ctx context.Context) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	newConf, err := config.NewFromFile(cfg.Config)
+	if err != nil {
+		logrus.WithError(err).Error("loading config file for reload, keeping current config")
+		return
+	}
+
+	cur := c.current
+	if newConf.BotToken != cur.BotToken || newConf.GuildID != cur.GuildID || newConf.StoreLocation != cur.StoreLocation ||
+		!reflect.DeepEqual(newConf.Twitch, cur.Twitch) || newConf.TwitchAuth != cur.TwitchAuth || newConf.TwitchEventSub != cur.TwitchEventSub ||
+		newConf.Kick != cur.Kick || newConf.YouTube != cur.YouTube {
+		logrus.Warn("changes to bot_token, guild_id, store_location, twitch, twitch_auth, twitch_eventsub, kick or youtube require a restart and are ignored")
+		newConf.BotToken = cur.BotToken
+		newConf.GuildID = cur.GuildID
+		newConf.StoreLocation = cur.StoreLocation
+		newConf.Twitch = cur.Twitch
+		newConf.TwitchAuth = cur.TwitchAuth
+		newConf.TwitchEventSub = cur.TwitchEventSub
+		newConf.Kick = cur.Kick
+		newConf.YouTube = cur.YouTube
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err = c.mgr.Apply(ctx, newConf); err != nil {
+		logrus.WithError(err).Error("applying reloaded config, affected modules are disabled")
+	}
+
+	if err = c.mgr.Setup(ctx); err != nil {
+		logrus.WithError(err).Error("running setup for reloaded modules, affected modules are disabled")
+	}
+
+	c.api.setToken(newConf.AdminToken)
+
+	c.current = newConf
+	logrus.Info("config reloaded")
+}
+
+func handleSIGHUP(ctx context.Context, fn func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-sigs:
+			logrus.Info("received SIGHUP, reloading config")
+			fn()
+		}
+	}
+}
+
+func watchConfig(ctx context.Context, filename string, fn func()) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		logrus.WithError(err).Error("resolving config path, config will not be watched")
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logrus.WithError(err).Error("creating config watcher, config will not be watched")
+		return
+	}
+	defer func() {
+		if err := watcher.Close(); err != nil {
+			logrus.WithError(err).Error("closing config watcher")
+		}
+	}()
+
+	// Watch the directory instead of the file: editors and config
+	// management replace the file which would end a watch on it
+	if err = watcher.Add(filepath.Dir(filename)); err != nil {
+		logrus.WithError(err).Error("watching config directory, config will not be watched")
+		return
+	}
+
+	debounce := time.AfterFunc(time.Hour, fn)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case evt, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(evt.Name) != filename || evt.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			logrus.WithField("op", evt.Op.String()).Debug("config file changed")
+			debounce.Reset(configReloadDebounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			logrus.WithError(err).Error("watching config file")
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/attributestore"
+	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/modules"
+	"github.com/Luzifer/discord-community/pkg/storage"
+)
+
+const (
+	// storeExportFileMode keeps the export, containing the tokens of
+	// the Twitch users, readable only for the owner
+	storeExportFileMode = 0o600
+	storeRedacted       = "<redacted>"
+
+	storeUsage = `Usage: discord-community [options] store <command>
+
+  list                           List module IDs having stored data
+  get <module-id> [key]          Print stored data of a module as JSON
+                                 (Twitch user tokens are redacted)
+  set <module-id> <key> <value>  Store a string value for a module
+  delete <module-id> [key]       Delete one or all keys of a module
+  export [file]                  Write all data as JSON (default: stdout)
+  import [file]                  Read data from JSON (default: stdin)
+`
+)
+
+type storeCommand struct {
+	args  []string
+	in    io.Reader
+	out   io.Writer
+	store *modules.MetaStore
+}
+
+var errStoreUsage = errors.New("invalid store command")
+
+// runStoreCommand opens the store configured in the given config file
+// and executes the store sub-command given in args
+func runStoreCommand(in io.Reader, out io.Writer, configFile string, args []string) (err error) {
+	if len(args) == 0 {
+		return errStoreUsage
+	}
+
+	cf, err := config.NewFromFile(configFile)
+	if err != nil {
+		return fmt.Errorf("loading config file: %w", err)
+	}
+
+	if cf.StoreLocation == "" {
+		return errors.New("config contains no store location")
+	}
+
+	ms, err := modules.NewMetaStore(cf.StoreLocation)
+	if err != nil {
+		return fmt.Errorf("loading store: %w", err)
+	}
+	defer func() {
+		if cErr := ms.Close(); cErr != nil {
+			err = errors.Join(err, fmt.Errorf("closing store: %w", cErr))
+		}
+	}()
+
+	cmd := storeCommand{args: args[1:], in: in, out: out, store: ms}
+
+	switch args[0] {
+	case "delete":
+		return cmd.delete()
+	case "export":
+		return cmd.export()
+	case "get":
+		return cmd.get()
+	case "import":
+		return cmd.importDump()
+	case "list":
+		return cmd.list()
+	case "set":
+		return cmd.set()
+	}
+
+	return errStoreUsage
+}
+
+func (s storeCommand) delete() error {
+	switch len(s.args) {
+	case 1:
+		return s.store.DeleteModule(s.args[0]) //nolint:wrapcheck // Already wrapped by MetaStore
+	case 2: //nolint:mnd // Module ID and key
+		return s.store.Delete(s.args[0], s.args[1]) //nolint:wrapcheck // Already wrapped by MetaStore
+	}
+
+	return errStoreUsage
+}
+
+func (s storeCommand) export() (err error) {
+	if len(s.args) > 1 {
+		return errStoreUsage
+	}
+
+	d, err := s.store.Export()
+	if err != nil {
+		return err //nolint:wrapcheck // Already wrapped by MetaStore
+	}
+
+	out := s.out
+	if len(s.args) == 1 && s.args[0] != "-" {
+		var f *os.File
+		if f, err = os.OpenFile(s.args[0], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, storeExportFileMode); err != nil { //#nosec:G304 // Intended to write to given file
+			return fmt.Errorf("creating export file: %w", err)
+		}
+		defer func() {
+			if cErr := f.Close(); cErr != nil {
+				err = errors.Join(err, fmt.Errorf("closing export file: %w", cErr))
+			}
+		}()
+
+		// The mode is only applied to new files
+		if err = f.Chmod(storeExportFileMode); err != nil {
+			return fmt.Errorf("restricting export file permissions: %w", err)
+		}
+		out = f
+	}
+
+	return s.writeJSON(out, d)
+}
+
+func (s storeCommand) get() error {
+	if len(s.args) < 1 || len(s.args) > 2 {
+		return errStoreUsage
+	}
+
+	// The access and refresh tokens of the Twitch users must not end
+	// up in terminals or logs, the export still contains them
+	redact := s.args[0] == modules.TwitchUserTokenStoreID
+
+	var data any
+	if err := s.store.ReadWithLock(s.args[0], func(a attributestore.ModuleAttributeStore) error {
+		if len(s.args) == 1 {
+			if redact {
+				redacted := make(map[string]string, len(a))
+				for key := range a {
+					redacted[key] = storeRedacted
+				}
+				data = redacted
+				return nil
+			}
+
+			data = a
+			return nil
+		}
+
+		v, ok := a[s.args[1]]
+		if !ok {
+			return fmt.Errorf("key %q: %w", s.args[1], attributestore.ErrValueNotSet)
+		}
+
+		data = v
+		if redact {
+			data = storeRedacted
+		}
+
+		return nil
+	}); err != nil {
+		return fmt.Errorf("reading store: %w", err)
+	}
+
+	return s.writeJSON(s.out, data)
+}
+
+func (s storeCommand) importDump() (err error) {
+	if len(s.args) > 1 {
+		return errStoreUsage
+	}
+
+	in := s.in
+	if len(s.args) == 1 && s.args[0] != "-" {
+		f, err := os.Open(s.args[0]) //#nosec:G304 // Intended to read given file
+		if err != nil {
+			return fmt.Errorf("opening import file: %w", err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				logrus.WithError(err).Error("closing import file")
+			}
+		}()
+		in = f
+	}
+
+	var d storage.Dump
+	if err = json.NewDecoder(in).Decode(&d); err != nil {
+		return fmt.Errorf("decoding import: %w", err)
+	}
+
+	return s.store.Import(d) //nolint:wrapcheck // Already wrapped by MetaStore
+}
+
+func (s storeCommand) list() error {
+	if len(s.args) > 0 {
+		return errStoreUsage
+	}
+
+	ids, err := s.store.Modules()
+	if err != nil {
+		return err //nolint:wrapcheck // Already wrapped by MetaStore
+	}
+
+	slices.Sort(ids)
+	for _, id := range ids {
+		if _, err = fmt.Fprintln(s.out, id); err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s storeCommand) set() error {
+	if len(s.args) != 3 { //nolint:mnd // Module ID, key and value
+		return errStoreUsage
+	}
+
+	return s.store.Set(s.args[0], s.args[1], s.args[2]) //nolint:wrapcheck // Already wrapped by MetaStore
+}
+
+func (storeCommand) writeJSON(w io.Writer, data any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	if err := enc.Encode(data); err != nil {
+		return fmt.Errorf("encoding output: %w", err)
+	}
+
+	return nil
+}
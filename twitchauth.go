@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/Luzifer/discord-community/pkg/modules"
+	"github.com/Luzifer/discord-community/pkg/twitch"
+)
+
+const (
+	// twitchAuthCallbackPath is the path Twitch redirects the users to
+	// after authorizing the app
+	twitchAuthCallbackPath = "/twitch/auth/callback"
+	// twitchAuthStateLifetime is how long an authorization link can be
+	// used after it was created
+	twitchAuthStateLifetime = time.Hour
+)
+
+type (
+	// twitchAuth implements the authorization code flow letting Twitch
+	// users authorize the app so modules can make requests using their
+	// tokens. Authorization links are created through the admin API,
+	// the tokens are stored in the MetaStore.
+	twitchAuth struct {
+		adapter     *twitch.Adapter
+		redirectURL string
+		store       *modules.MetaStore
+
+		lock   sync.Mutex
+		states map[string]time.Time
+	}
+
+	twitchAuthTokenInfo struct {
+		ExpiresAt time.Time `json:"expires_at"`
+		Login     string    `json:"login"`
+		Scopes    []string  `json:"scopes"`
+		UserID    string    `json:"user_id"`
+	}
+)
+
+func newTwitchAuth(adapter *twitch.Adapter, redirectURL string, store *modules.MetaStore) *twitchAuth {
+	return &twitchAuth{
+		adapter:     adapter,
+		redirectURL: redirectURL,
+		store:       store,
+
+		states: make(map[string]time.Time),
+	}
+}
+
+// handleCallback exchanges the code passed by Twitch for the token of
+// the user and stores it
+func (t *twitchAuth) handleCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if !t.useState(q.Get("state")) {
+		http.Error(w, "authorization link is invalid or expired, please request a new one", http.StatusBadRequest)
+		return
+	}
+
+	if e := q.Get("error"); e != "" {
+		http.Error(w, fmt.Sprintf("authorization was not granted: %s", e), http.StatusBadRequest)
+		return
+	}
+
+	token, err := t.adapter.ExchangeCode(r.Context(), q.Get("code"), t.redirectURL)
+	if err != nil {
+		logrus.WithError(err).Error("exchanging Twitch authorization code")
+		http.Error(w, "exchanging authorization code failed", http.StatusInternalServerError)
+		return
+	}
+
+	if err = t.store.SetUserToken(*token); err != nil {
+		logrus.WithError(err).Error("storing Twitch user token")
+		http.Error(w, "storing authorization failed", http.StatusInternalServerError)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"login":  token.UserLogin,
+		"scopes": token.Scopes,
+	}).Info("Twitch user authorized the app")
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Authorization for %s was stored, you can close this page now.\n", token.UserLogin)
+}
+
+// handleCreateAuthorization creates an authorization link for the
+// requested scopes to be sent to the user
+func (t *twitchAuth) handleCreateAuthorization(api *adminAPI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Scopes []string `json:"scopes"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			api.writeError(w, http.StatusBadRequest, "invalid request body")
+			return
+		}
+
+		state := rand.Text()
+
+		t.lock.Lock()
+		t.states[state] = time.Now().Add(twitchAuthStateLifetime)
+		t.lock.Unlock()
+
+		api.writeJSON(w, http.StatusCreated, map[string]any{
+			"expires_at": time.Now().Add(twitchAuthStateLifetime),
+			"url":        t.adapter.AuthorizationURL(t.redirectURL, state, req.Scopes),
+		})
+	}
+}
+
+// handleDeleteToken revokes and removes the token of the user
+func (t *twitchAuth) handleDeleteToken(api *adminAPI) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := t.store.GetUserToken(r.PathValue("login"))
+		switch {
+		case errors.Is(err, twitch.ErrUserTokenNotFound):
+			api.writeError(w, http.StatusNotFound, err.Error())
+			return
+
+		case err != nil:
+			logrus.WithError(err).Error("reading Twitch user token for API")
+			api.writeError(w, http.StatusInternalServerError, "reading token failed")
+			return
+		}
+
+		if err = t.adapter.RevokeUserToken(r.Context(), *token); err != nil {
+			// Token might already be revoked by the user, it is
+			// removed anyway
+			logrus.WithError(err).WithField("login", token.UserLogin).Warn("revoking Twitch user token")
+		}
+
+		if err = t.store.DeleteUserToken(token.UserLogin); err != nil {
+			logrus.WithError(err).Error("deleting Twitch user token for API")
+			api.writeError(w, http.StatusInternalServerError, "deleting token failed")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleListTokens lists the users who authorized the app without
+// exposing their tokens
+func (t *twitchAuth) handleListTokens(api *adminAPI) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		tokens, err := t.store.UserTokens()
+		if err != nil {
+			logrus.WithError(err).Error("listing Twitch user tokens for API")
+			api.writeError(w, http.StatusInternalServerError, "listing tokens failed")
+			return
+		}
+
+		out := make([]twitchAuthTokenInfo, 0, len(tokens))
+		for _, token := range tokens {
+			out = append(out, twitchAuthTokenInfo{
+				ExpiresAt: token.ExpiresAt,
+				Login:     token.UserLogin,
+				Scopes:    token.Scopes,
+				UserID:    token.UserID,
+			})
+		}
+
+		api.writeJSON(w, http.StatusOK, out)
+	}
+}
+
+// register adds the callback and the admin API routes to the given mux
+func (t *twitchAuth) register(mux *http.ServeMux, api *adminAPI) {
+	mux.HandleFunc("GET "+twitchAuthCallbackPath, t.handleCallback)
+
+	mux.Handle("POST /api/twitch/authorize", api.requireToken(t.handleCreateAuthorization(api)))
+	mux.Handle("GET /api/twitch/tokens", api.requireToken(t.handleListTokens(api)))
+	mux.Handle("DELETE /api/twitch/tokens/{login}", api.requireToken(t.handleDeleteToken(api)))
+}
+
+// useState checks the state was issued and is not expired and removes
+// it so it can only be used once. Expired states are purged.
+func (t *twitchAuth) useState(state string) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for s, expiry := range t.states {
+		if time.Now().After(expiry) {
+			delete(t.states, s)
+		}
+	}
+
+	if _, ok := t.states[state]; !ok || state == "" {
+		return false
+	}
+
+	delete(t.states, state)
+	return true
+}
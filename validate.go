@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Luzifer/discord-community/pkg/config"
+	"github.com/Luzifer/discord-community/pkg/modules"
+	"github.com/Luzifer/discord-community/pkg/storage"
+)
+
+const (
+	twitchEventSubMaxSecretLen = 100
+	twitchEventSubMinSecretLen = 10
+)
+
+// validateConfigFile renders and parses the given config file, checks
+// all module configurations against their schema and writes a report
+// to the given writer. It returns whether the config is valid.
+func validateConfigFile(w io.Writer, filename string) (bool, error) {
+	var (
+		problems int
+		report   = new(strings.Builder)
+		seenIDs  []string
+	)
+
+	cf, err := config.NewFromFile(filename)
+	if err != nil {
+		if _, err = fmt.Fprintf(w, "%s: %s\n", filename, err); err != nil {
+			return false, fmt.Errorf("writing report: %w", err)
+		}
+		return false, nil
+	}
+
+	for _, setting := range [][2]string{
+		{"bot_token", cf.BotToken},
+		{"guild_id", cf.GuildID},
+		{"store_location", cf.StoreLocation},
+	} {
+		if setting[1] == "" {
+			fmt.Fprintf(report, "  - missing required setting %q\n", setting[0])
+			problems++
+		}
+	}
+
+	if cf.StoreLocation != "" {
+		if err = storage.ValidateLocation(cf.StoreLocation); err != nil {
+			fmt.Fprintf(report, "  - invalid setting \"store_location\": %s\n", err)
+			problems++
+		}
+	}
+
+	for _, problem := range twitchProblems(cf.Twitch) {
+		fmt.Fprintf(report, "  - invalid setting \"twitch\": %s\n", problem)
+		problems++
+	}
+
+	if (cf.Kick.ClientID == "") != (cf.Kick.ClientSecret == "") {
+		fmt.Fprintln(report, `  - invalid setting "kick": client_id and client_secret must be set together`)
+		problems++
+	}
+
+	if cf.TwitchAuth.RedirectURL != "" {
+		for _, problem := range twitchAuthProblems(cf) {
+			fmt.Fprintf(report, "  - invalid setting \"twitch_auth\": %s\n", problem)
+			problems++
+		}
+	}
+
+	if cf.TwitchEventSub.CallbackURL != "" {
+		for _, problem := range twitchEventSubProblems(cf) {
+			fmt.Fprintf(report, "  - invalid setting \"twitch_eventsub\": %s\n", problem)
+			problems++
+		}
+	}
+
+	for i, mc := range cf.ModuleConfigs {
+		var errs []error
+
+		switch {
+		case mc.ID == "":
+			errs = append(errs, errors.New("module has no ID"))
+		case slices.Contains(seenIDs, mc.ID):
+			errs = append(errs, errors.New("duplicate module ID"))
+		}
+		seenIDs = append(seenIDs, mc.ID)
+
+		errs = append(errs, modules.ValidateModuleConfig(mc)...)
+		if err := modules.ValidateTwitchCredentials(cf.Twitch, mc); err != nil {
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(report, "  module #%d %q (%s):\n", i, mc.ID, mc.Type)
+		for _, err := range errs {
+			fmt.Fprintf(report, "    - %s\n", err)
+		}
+		problems += len(errs)
+	}
+
+	if problems == 0 {
+		_, err = fmt.Fprintf(w, "%s: config is valid (%d modules)\n", filename, len(cf.ModuleConfigs))
+	} else {
+		_, err = fmt.Fprintf(w, "%s: %d problem(s) found\n%s", filename, problems, report)
+	}
+	if err != nil {
+		return false, fmt.Errorf("writing report: %w", err)
+	}
+
+	return problems == 0, nil
+}
+
+// twitchAuthProblems checks the settings of the authorization flow
+// against the requirements of Twitch
+func twitchAuthProblems(cf *config.File) (problems []string) {
+	u, err := url.Parse(cf.TwitchAuth.RedirectURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && (u.Scheme != "http" || u.Hostname() != "localhost")) {
+		problems = append(problems, "redirect_url must be an HTTPS URL (or HTTP on localhost)")
+	}
+
+	if problem := twitchCredentialProblem(cf.Twitch, cf.TwitchAuth.ClientID, cf.TwitchAuth.ClientSecret); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	if cf.AdminToken == "" {
+		problems = append(problems, "admin_token is required to create authorization links")
+	}
+
+	return problems
+}
+
+// twitchCredentialProblem checks the credentials of a Twitch feature
+// are complete or can be taken from the top-level twitch section
+func twitchCredentialProblem(tc config.TwitchConfig, clientID, clientSecret string) string {
+	switch {
+	case (clientID == "") != (clientSecret == ""):
+		return "client_id and client_secret must be set together"
+
+	case clientID == "" && tc.ClientID == "":
+		return "client_id and client_secret are required (here or in the twitch section)"
+
+	default:
+		return ""
+	}
+}
+
+// twitchEventSubProblems checks the webhook settings against the
+// requirements of Twitch
+func twitchEventSubProblems(cf *config.File) (problems []string) {
+	es := cf.TwitchEventSub
+
+	if u, err := url.Parse(es.CallbackURL); err != nil || u.Scheme != "https" || u.Host == "" || (u.Port() != "" && u.Port() != "443") {
+		problems = append(problems, "callback_url must be an HTTPS URL on port 443")
+	}
+
+	if problem := twitchCredentialProblem(cf.Twitch, es.ClientID, es.ClientSecret); problem != "" {
+		problems = append(problems, problem)
+	}
+
+	if len(es.Secret) < twitchEventSubMinSecretLen || len(es.Secret) > twitchEventSubMaxSecretLen {
+		problems = append(problems, fmt.Sprintf("secret must have %d-%d characters", twitchEventSubMinSecretLen, twitchEventSubMaxSecretLen))
+	}
+
+	return problems
+}
+
+// twitchProblems checks the settings of the Twitch client shared by
+// the modules
+func twitchProblems(tc config.TwitchConfig) (problems []string) {
+	if (tc.ClientID == "") != (tc.ClientSecret == "") {
+		problems = append(problems, "client_id and client_secret must be set together")
+	}
+
+	if tc.RequestTimeout < 0 {
+		problems = append(problems, "request_timeout must not be negative")
+	}
+
+	if tc.Retries != nil && *tc.Retries < 0 {
+		problems = append(problems, "retries must not be negative")
+	}
+
+	return problems
+}